@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/calibrate"
+	"github.com/sherine-k/leases/pkg/chart"
+	"github.com/spf13/cobra"
+)
+
+var (
+	calibrateColumn string
+	calibrateSeeds  int
+	calibrateSeed   int64
+	calibrateWarmup time.Duration
+)
+
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate <usage.csv|usage.json>",
+	Short: "Compare the simulation with the real lease usage",
+	Long: `Load a time series of the leases really in use, simulate the configuration
+over the same window and report how far the simulation is from reality: the
+RMSE and bias of the simulated usage, and the differences in peak usage, time
+at capacity and lease-hours. Adjustments of the job durations and of the
+release interval closing the lease-hours gap are suggested.
+
+The series is a CSV file with a time (RFC 3339 or Unix timestamp) and a lease
+count per row, optionally preceded by a header, or a JSON file holding either
+an array of {"time": ..., "value": ...} objects or a Prometheus range query
+response, whose series are summed.
+
+Release controller triggers are random, so the simulation is run with several
+seeds and the simulated usage is averaged over them.`,
+	Example: `  leases calibrate -c config.yaml boskos-usage.csv --column leased
+  curl -s 'http://prometheus:9090/api/v1/query_range?query=sum(boskos_resources{state="leased"})&start=...&end=...&step=300' > usage.json
+  leases calibrate -c config.yaml usage.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCalibrate,
+}
+
+func init() {
+	calibrateCmd.Flags().StringVar(&calibrateColumn, "column", "", "Header name of the CSV column holding the lease count (default the second column)")
+	calibrateCmd.Flags().IntVar(&calibrateSeeds, "seeds", 5, "Number of seeded runs to average")
+	calibrateCmd.Flags().Int64Var(&calibrateSeed, "seed", 1, "First random seed")
+	calibrateCmd.Flags().DurationVar(&calibrateWarmup, "warmup", 12*time.Hour, "Time simulated before the first sample, for the jobs running when the series starts")
+
+	rootCmd.AddCommand(calibrateCmd)
+}
+
+func runCalibrate(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	samples, err := calibrate.LoadSeries(args[0], calibrateColumn)
+	if err != nil {
+		return fmt.Errorf("failed to load lease usage: %w", err)
+	}
+
+	fmt.Printf("Loaded configuration from %s\n", configFile)
+	fmt.Printf("  - Comparing with %d samples from %s\n", len(samples), args[0])
+
+	report, err := calibrate.Calibrate(cmd.Context(), cfg, samples, calibrate.Options{
+		Seeds:    calibrateSeeds,
+		BaseSeed: calibrateSeed,
+		Warmup:   calibrateWarmup,
+	})
+	if err != nil {
+		return fmt.Errorf("calibration failed: %w", err)
+	}
+
+	chartGen := chart.NewGenerator()
+	fmt.Println(chartGen.GenerateCalibration(report))
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/chart"
+	"github.com/sherine-k/leases/pkg/simulation"
+	"github.com/spf13/cobra"
+)
+
+var (
+	compareSeed      int64
+	compareShowChart bool
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare CONFIG CONFIG...",
+	Short: "Compare the simulation of several configurations",
+	Long: `Simulate each configuration with the same random seed and start time, and
+print a side-by-side table of the key metrics, highlighting the configuration
+that wins on each metric, followed by a chart overlaying their lease usage.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runCompare,
+}
+
+func init() {
+	compareCmd.Flags().Int64Var(&compareSeed, "seed", 1, "Random seed shared by all simulations")
+	compareCmd.Flags().BoolVar(&compareShowChart, "chart", true, "Show the overlaid lease usage chart")
+
+	rootCmd.AddCommand(compareCmd)
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	start := simulation.LastMonday(time.Now())
+	scenarios := []chart.Scenario{}
+
+	for _, file := range args {
+		cfg, err := loadConfig(file)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration %s: %w", file, err)
+		}
+
+		sim := simulation.NewSimulator(cfg, simulation.WithStartTime(start), simulation.WithSeed(compareSeed), simulation.WithLogger(logger))
+		result, err := sim.Run(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("simulation of %s failed: %w", file, err)
+		}
+
+		scenarios = append(scenarios, chart.Scenario{
+			Name:       filepath.Base(file),
+			MaxLeases:  cfg.MaxActiveLeases,
+			Metrics:    result.Metrics,
+			TimePoints: result.TimePoints,
+		})
+	}
+
+	chartGen := chart.NewGenerator()
+	fmt.Println(chartGen.GenerateComparisonTable(scenarios))
+
+	if compareShowChart {
+		fmt.Println(chartGen.GenerateComparisonChart(scenarios))
+	}
+
+	return nil
+}
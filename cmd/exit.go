@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"errors"
+)
+
+// Exit codes of the leases command
+const (
+	// ExitOK reports success
+	ExitOK = 0
+	// ExitCheckFailed reports that a check failed: a --fail-on condition or
+	// threshold of the simulation, or problems found by validate or lint
+	ExitCheckFailed = 1
+	// ExitError reports that the command could not run, e.g. because of an
+	// invalid flag or configuration
+	ExitError = 2
+)
+
+// CheckError is returned when the command ran but a check failed
+type CheckError struct {
+	Err error
+}
+
+// Error returns the message of the failed check
+func (e *CheckError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code for the error returned by Execute
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var checkErr *CheckError
+	if errors.As(err, &checkErr) {
+		return ExitCheckFailed
+	}
+	return ExitError
+}
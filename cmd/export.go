@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/export"
+	"github.com/sherine-k/leases/pkg/simulation"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportOutput string
+	exportStart  string
+	exportSeed   int64
+	exportStep   time.Duration
+	exportLabels map[string]string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the simulated lease usage as OpenMetrics",
+	Long: `Simulate the configuration and write the lease usage over time as OpenMetrics
+text with timestamps: the active leases and waiting jobs in total, per pool and
+per version, and the maximum number of leases. The output can be backfilled
+into Prometheus to overlay the simulation with the real lease usage in Grafana:
+
+  promtool tsdb create-blocks-from openmetrics simulated.om ./data
+
+Prometheus only looks back 5 minutes for a sample by default, so the samples
+are a minute apart unless --step is set.`,
+	Example: `  leases export -c config.yaml --start 2026-06-01T00:00:00Z --label scenario=proposal -o simulated.om`,
+	Args:    cobra.NoArgs,
+	RunE:    runExport,
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write the metrics to a file instead of standard output")
+	exportCmd.Flags().StringVar(&exportStart, "start", "", "Start of the simulation as an RFC 3339 time (default the last Monday)")
+	exportCmd.Flags().Int64Var(&exportSeed, "seed", 0, "Random seed of the simulation (default random)")
+	exportCmd.Flags().DurationVar(&exportStep, "step", time.Minute, "Interval between the samples")
+	exportCmd.Flags().StringToStringVar(&exportLabels, "label", nil, "Label added to every series, e.g. scenario=proposal (repeatable)")
+
+	rootCmd.AddCommand(exportCmd)
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	opts := []simulation.Option{simulation.WithLogger(logger), simulation.WithSampleInterval(exportStep)}
+	if exportStart != "" {
+		start, err := time.Parse(time.RFC3339, exportStart)
+		if err != nil {
+			return fmt.Errorf("invalid start %q, expected an RFC 3339 time such as 2026-06-01T00:00:00Z", exportStart)
+		}
+		opts = append(opts, simulation.WithStartTime(start))
+	}
+	if exportSeed != 0 {
+		opts = append(opts, simulation.WithSeed(exportSeed))
+	}
+
+	result, err := simulation.NewSimulator(cfg, opts...).Run(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("simulation failed: %w", err)
+	}
+
+	if exportOutput == "" {
+		if err := export.WriteOpenMetrics(os.Stdout, cfg, result.TimePoints, exportLabels); err != nil {
+			return fmt.Errorf("failed to export metrics: %w", err)
+		}
+		return nil
+	}
+
+	file, err := os.Create(exportOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	if err := export.WriteOpenMetrics(file, cfg, result.TimePoints, exportLabels); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to export metrics: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	fmt.Printf("Exported %d samples per series to %s\n", len(result.TimePoints), exportOutput)
+
+	return nil
+}
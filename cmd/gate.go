@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/chart"
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// Conditions accepted by --fail-on
+const (
+	failOnWait        = "wait"
+	failOnTimeout     = "timeout"
+	failOnMaxExceeded = "max-exceeded"
+	failOnNone        = "none"
+)
+
+var (
+	failOn          []string
+	maxWaitTimeouts int
+	maxP95Wait      time.Duration
+)
+
+// validateGate checks the --fail-on conditions before running the simulation
+func validateGate() error {
+	for _, condition := range failOn {
+		switch condition {
+		case failOnWait, failOnTimeout, failOnMaxExceeded:
+		case failOnNone:
+			if len(failOn) > 1 {
+				return fmt.Errorf("--fail-on %s cannot be combined with other conditions", failOnNone)
+			}
+		default:
+			return fmt.Errorf("--fail-on: unknown condition %q (expected %s, %s, %s or %s)",
+				condition, failOnWait, failOnTimeout, failOnMaxExceeded, failOnNone)
+		}
+	}
+	return nil
+}
+
+// checkGate returns the --fail-on conditions and thresholds failed by a simulation
+func checkGate(metrics simulation.Metrics) []string {
+	failures := []string{}
+
+	for _, condition := range failOn {
+		switch condition {
+		case failOnWait:
+			if metrics.WaitedJobs > 0 {
+				failures = append(failures, fmt.Sprintf("%d jobs waited for a lease (--fail-on %s)", metrics.WaitedJobs, failOnWait))
+			}
+		case failOnTimeout:
+			if timeouts := metrics.WaitTimeouts + metrics.ExecTimeouts; timeouts > 0 {
+				failures = append(failures, fmt.Sprintf("%d jobs timed out: %d waiting for a lease, %d running (--fail-on %s)",
+					timeouts, metrics.WaitTimeouts, metrics.ExecTimeouts, failOnTimeout))
+			}
+		case failOnMaxExceeded:
+			if metrics.MaxExceeded > 0 {
+				failures = append(failures, fmt.Sprintf("max active leases exceeded %d times (--fail-on %s)", metrics.MaxExceeded, failOnMaxExceeded))
+			}
+		}
+	}
+
+	if maxWaitTimeouts >= 0 && metrics.WaitTimeouts > maxWaitTimeouts {
+		failures = append(failures, fmt.Sprintf("%d lease wait timeouts, above --max-wait-timeouts %d", metrics.WaitTimeouts, maxWaitTimeouts))
+	}
+	if maxP95Wait > 0 && metrics.P95Wait > maxP95Wait {
+		failures = append(failures, fmt.Sprintf("95th percentile lease wait %s, above --max-p95-wait %s",
+			chart.FormatDuration(metrics.P95Wait), chart.FormatDuration(maxP95Wait)))
+	}
+
+	return failures
+}
+
+// gateError returns the error reporting failed checks, or nil if none failed
+func gateError(failures []string) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	summary := "1 check failed"
+	if len(failures) > 1 {
+		summary = fmt.Sprintf("%d checks failed", len(failures))
+	}
+	return &CheckError{Err: fmt.Errorf("%s:\n  - %s", summary, strings.Join(failures, "\n  - "))}
+}
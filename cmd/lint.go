@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/lint"
+	"github.com/sherine-k/leases/pkg/simulation"
+	"github.com/spf13/cobra"
+)
+
+var lintDisabledRules []string
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [CONFIG...]",
+	Short: "Flag suspicious patterns in configuration files",
+	Long: `Validate configuration files and flag suspicious patterns that are valid
+but likely mistakes. When no file is given, the file of --config is linted.
+
+Rules:
+` + lintRulesHelp(),
+	SilenceUsage: true,
+	RunE:         runLint,
+}
+
+func init() {
+	lintCmd.Flags().StringSliceVar(&lintDisabledRules, "disable", nil, "Rules to skip (repeatable or comma-separated)")
+
+	rootCmd.AddCommand(lintCmd)
+}
+
+// lintRulesHelp lists the lint rules for the command help
+func lintRulesHelp() string {
+	help := ""
+	for _, rule := range lint.Rules {
+		help += fmt.Sprintf("  %-28s %s\n", rule.Name, rule.Description)
+	}
+	return help
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	files := args
+	if len(files) == 0 {
+		files = []string{configFile}
+	}
+
+	disabled := make(map[string]bool)
+	for _, name := range lintDisabledRules {
+		known := false
+		for _, rule := range lint.Rules {
+			known = known || rule.Name == name
+		}
+		if !known {
+			return fmt.Errorf("unknown lint rule %q", name)
+		}
+		disabled[name] = true
+	}
+
+	start := simulation.LastMonday(time.Now())
+	failed := 0
+	for _, file := range files {
+		cfg, err := loadConfig(file)
+		if err != nil {
+			failed++
+			fmt.Printf("%s: %v\n", file, err)
+			continue
+		}
+
+		findings := []lint.Finding{}
+		for _, finding := range lint.Run(cfg, start) {
+			if !disabled[finding.Rule] {
+				findings = append(findings, finding)
+			}
+		}
+
+		printFindings(file, cfg, findings)
+		if len(findings) > 0 {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return &CheckError{Err: fmt.Errorf("%d of %d configurations have problems", failed, len(files))}
+	}
+
+	return nil
+}
+
+// printFindings prints the lint findings of a configuration file
+func printFindings(file string, cfg *config.Config, findings []lint.Finding) {
+	for _, finding := range findings {
+		fmt.Println(finding)
+	}
+	fmt.Printf("%s: %d jobs, %d findings\n", file, len(cfg.Jobs), len(findings))
+}
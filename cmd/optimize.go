@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/chart"
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/optimize"
+	"github.com/sherine-k/leases/pkg/simulation"
+	"github.com/spf13/cobra"
+)
+
+var (
+	optimizeOutput     string
+	optimizeLeases     int
+	optimizeSeeds      int
+	optimizeSeed       int64
+	optimizeRounds     int
+	optimizeMinuteStep int
+)
+
+var optimizeCmd = &cobra.Command{
+	Use:   "optimize",
+	Short: "Search cron schedules that stagger the movable periodic jobs",
+	Long: `Search alternative cron schedules for the jobs marked "movable: true" to
+minimise lease wait timeouts, wait time and peak concurrency under a fixed
+lease count.
+
+Movable jobs keep their frequency and weekdays; only their hours and minute
+change, within the optional "allowedHours" of the job. The proposed
+configuration is written next to the original one and a diff is printed.`,
+	RunE: runOptimize,
+}
+
+func init() {
+	optimizeCmd.Flags().StringVarP(&optimizeOutput, "output", "o", "", "Path of the proposed configuration (default <config>.optimized.yaml)")
+	optimizeCmd.Flags().IntVar(&optimizeLeases, "leases", 0, "Lease count to optimize for (default the configured maxActiveLeases)")
+	optimizeCmd.Flags().IntVar(&optimizeSeeds, "seeds", 3, "Number of seeded runs per candidate schedule")
+	optimizeCmd.Flags().Int64Var(&optimizeSeed, "seed", 1, "First random seed")
+	optimizeCmd.Flags().IntVar(&optimizeRounds, "rounds", 3, "Maximum number of passes over the movable jobs")
+	optimizeCmd.Flags().IntVar(&optimizeMinuteStep, "minute-step", 30, "Granularity in minutes of the candidate start minutes")
+
+	rootCmd.AddCommand(optimizeCmd)
+}
+
+func runOptimize(cmd *cobra.Command, args []string) error {
+	// The proposed configuration and its diff are written from the
+	// configuration file, which does not have the schedule overrides
+	if overrides.SetsJobField("triggerType", "cronSchedule", "movable", "allowedHours") {
+		return fmt.Errorf("--set cannot change triggerType, cronSchedule, movable or allowedHours of jobs: the proposed schedules are written to a copy of the configuration file")
+	}
+
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if optimizeLeases > 0 {
+		cfg.MaxActiveLeases = optimizeLeases
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("--leases %d: %w", optimizeLeases, err)
+		}
+	}
+
+	fmt.Printf("Loaded configuration from %s\n", configFile)
+	fmt.Printf("  - Optimizing for %d leases with %d seeds\n", cfg.MaxActiveLeases, optimizeSeeds)
+
+	result, err := optimize.Optimize(cmd.Context(), cfg, optimize.Options{
+		Seeds:      optimizeSeeds,
+		BaseSeed:   optimizeSeed,
+		StartTime:  simulation.LastMonday(time.Now()),
+		Rounds:     optimizeRounds,
+		MinuteStep: optimizeMinuteStep,
+	})
+	if err != nil {
+		return fmt.Errorf("optimization failed: %w", err)
+	}
+
+	chartGen := chart.NewGenerator()
+	fmt.Println(chartGen.GenerateOptimization(result, optimizeSeeds))
+
+	if len(result.Changes) == 0 {
+		return nil
+	}
+
+	original, err := os.ReadFile(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	schedules := make(map[string]string)
+	for _, change := range result.Changes {
+		schedules[change.JobName] = change.NewSchedule
+	}
+	proposed, err := config.SetCronSchedules(original, schedules)
+	if err != nil {
+		return fmt.Errorf("failed to write proposed schedules: %w", err)
+	}
+
+	output := optimizeOutput
+	if output == "" {
+		ext := filepath.Ext(configFile)
+		output = strings.TrimSuffix(configFile, ext) + ".optimized" + ext
+	}
+	if err := os.WriteFile(output, proposed, 0644); err != nil {
+		return fmt.Errorf("failed to write proposed configuration: %w", err)
+	}
+
+	fmt.Println(optimize.Diff(configFile, output, original, proposed))
+	fmt.Printf("Proposed configuration written to %s\n", output)
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/capacity"
+	"github.com/sherine-k/leases/pkg/chart"
+	"github.com/sherine-k/leases/pkg/simulation"
+	"github.com/spf13/cobra"
+)
+
+var (
+	recommendMinLeases       int
+	recommendMaxLeases       int
+	recommendSeeds           int
+	recommendSeed            int64
+	recommendMaxWaitTimeouts int
+	recommendMaxP95Wait      time.Duration
+	recommendMinUtilization  float64
+)
+
+var recommendCmd = &cobra.Command{
+	Use:   "recommend",
+	Short: "Recommend the minimum number of leases meeting the targets",
+	Long: `Re-run the simulation across a range of maxActiveLeases values and report
+the minimum lease capacity that meets the targets.
+
+Release controller triggers are random, so every capacity is simulated with
+several seeds and the worst result across the seeds is compared against the
+targets. The search starts above reservedLeases, and the command exits with
+status 1 when no lease count meets the targets.`,
+	RunE: runRecommend,
+}
+
+func init() {
+	recommendCmd.Flags().IntVar(&recommendMinLeases, "min", 1, "Lowest lease count to try")
+	recommendCmd.Flags().IntVar(&recommendMaxLeases, "max", 0, "Highest lease count to try (default twice the configured maxActiveLeases)")
+	recommendCmd.Flags().IntVar(&recommendSeeds, "seeds", 5, "Number of seeded runs per lease count")
+	recommendCmd.Flags().Int64Var(&recommendSeed, "seed", 1, "First random seed")
+	recommendCmd.Flags().IntVar(&recommendMaxWaitTimeouts, "max-wait-timeouts", 0, "Maximum number of lease wait timeouts allowed")
+	recommendCmd.Flags().DurationVar(&recommendMaxP95Wait, "max-p95-wait", 0, "Maximum 95th percentile lease wait time (0 disables)")
+	recommendCmd.Flags().Float64Var(&recommendMinUtilization, "min-utilization", 0, "Minimum lease utilisation as a fraction, e.g. 0.6 (0 disables)")
+
+	rootCmd.AddCommand(recommendCmd)
+}
+
+func runRecommend(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	maxLeases := recommendMaxLeases
+	if maxLeases == 0 {
+		maxLeases = 2 * cfg.MaxActiveLeases
+	}
+
+	fmt.Printf("Loaded configuration from %s\n", configFile)
+	fmt.Printf("  - Searching maxActiveLeases %d to %d with %d seeds\n", capacity.MinLeases(cfg, recommendMinLeases), maxLeases, recommendSeeds)
+
+	rec, err := capacity.Recommend(cmd.Context(), cfg, capacity.Options{
+		MinLeases: recommendMinLeases,
+		MaxLeases: maxLeases,
+		Seeds:     recommendSeeds,
+		BaseSeed:  recommendSeed,
+		StartTime: simulation.LastMonday(time.Now()),
+		Targets: capacity.Targets{
+			MaxWaitTimeouts: recommendMaxWaitTimeouts,
+			MaxP95Wait:      recommendMaxP95Wait,
+			MinUtilization:  recommendMinUtilization,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("capacity search failed: %w", err)
+	}
+
+	chartGen := chart.NewGenerator()
+	fmt.Println(chartGen.GenerateRecommendation(rec))
+
+	if rec.Recommended == 0 {
+		return &CheckError{Err: fmt.Errorf("no lease count up to %d meets the targets", maxLeases)}
+	}
+	return nil
+}
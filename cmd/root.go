@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"log/slog"
+	"os"
+	"time"
 
 	"github.com/sherine-k/leases/pkg/chart"
 	"github.com/sherine-k/leases/pkg/config"
@@ -13,7 +17,12 @@ var (
 	configFile       string
 	showTimeline     bool
 	timelineLimit    int
+	warningsLimit    int
 	showEventSummary bool
+	showInstances    bool
+	minSlip          time.Duration
+	eventsFile       string
+	overrides        config.Overrides
 )
 
 var rootCmd = &cobra.Command{
@@ -23,8 +32,14 @@ var rootCmd = &cobra.Command{
 
 This tool reads a configuration file containing CI jobs with their schedules,
 simulates their execution, and generates a visual chart showing lease usage
-over time along with warnings for potential issues.`,
-	RunE: runSimulation,
+over time along with warnings for potential issues.
+
+The command exits with status 1 when a --fail-on condition or threshold fails,
+and with status 2 when it cannot run, so that it can gate CI changes.`,
+	// Errors are printed once by main, which also sets the exit code
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE:          runSimulation,
 }
 
 // Execute runs the root command
@@ -33,58 +48,174 @@ func Execute() error {
 }
 
 func init() {
-	rootCmd.Flags().StringVarP(&configFile, "config", "c", "config.yaml", "Path to configuration file")
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "config.yaml", "Path to configuration file")
+	rootCmd.PersistentFlags().StringArrayVar(&overrides.Set, "set", nil, "Override a configuration value, e.g. maxActiveLeases=14 or jobs[name=ocp-4.19-*].duration=6h (repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&overrides.DisableJobs, "disable-job", nil, "Remove the jobs whose name matches the glob pattern (repeatable)")
+	rootCmd.PersistentFlags().StringSliceVar(&overrides.OnlyVersions, "only-version", nil, "Keep only the jobs of these versions (repeatable or comma-separated)")
 	rootCmd.Flags().BoolVarP(&showTimeline, "timeline", "t", false, "Show detailed timeline of events")
 	rootCmd.Flags().IntVarP(&timelineLimit, "timeline-limit", "l", 50, "Limit number of timeline events to display")
+	rootCmd.Flags().IntVar(&warningsLimit, "warnings-limit", 50, "Limit number of warnings to display, 0 for all")
 	rootCmd.Flags().BoolVarP(&showEventSummary, "summary", "s", true, "Show event summary")
+	rootCmd.Flags().StringVar(&eventsFile, "events", "", "Write every simulation event to a file as JSON lines")
+	rootCmd.Flags().BoolVar(&showInstances, "runs", false, "Show every job run with its schedule slip and outcome")
+	rootCmd.Flags().DurationVar(&minSlip, "min-slip", 0, "Only show the job runs that started at least this late (with --runs)")
+	rootCmd.Flags().StringSliceVar(&failOn, "fail-on", []string{failOnWait, failOnTimeout, failOnMaxExceeded}, "Exit with status 1 when jobs wait for a lease (wait), time out (timeout) or exceed the max active leases (max-exceeded); none disables")
+	rootCmd.Flags().IntVar(&maxWaitTimeouts, "max-wait-timeouts", -1, "Exit with status 1 when more jobs time out waiting for a lease (-1 disables)")
+	rootCmd.Flags().BoolVarP(&watch, "watch", "w", false, "Re-run the simulation whenever the configuration or its includes change")
+	rootCmd.Flags().DurationVar(&maxP95Wait, "max-p95-wait", 0, "Exit with status 1 when the 95th percentile lease wait time is longer (0 disables)")
+}
+
+// logger reports the problems met while simulating
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// loadConfig loads a configuration file with the what-if overrides of the command line applied
+func loadConfig(filename string) (*config.Config, error) {
+	return config.LoadConfigWithOverrides(filename, overrides)
 }
 
 func runSimulation(cmd *cobra.Command, args []string) error {
+	if err := validateGate(); err != nil {
+		return err
+	}
+	if watch {
+		return watchSimulation(cmd)
+	}
+
 	// Load configuration
-	cfg, err := config.LoadConfig(configFile)
+	cfg, err := loadConfig(configFile)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
+	result, err := simulate(cmd, cfg, simulation.WithLogger(logger))
+	if err != nil {
+		return err
+	}
+
+	return gateError(checkGate(result.Metrics))
+}
+
+// simulate runs the simulation of a configuration and prints its outputs
+func simulate(cmd *cobra.Command, cfg *config.Config, opts ...simulation.Option) (*simulation.Result, error) {
 	fmt.Printf("Loaded configuration from %s\n", configFile)
+	if !overrides.IsEmpty() {
+		fmt.Printf("  - Overrides applied: %d set, %d disabled job patterns, %d versions kept\n", len(overrides.Set), len(overrides.DisableJobs), len(overrides.OnlyVersions))
+	}
 	fmt.Printf("  - Max Active Leases: %d\n", cfg.MaxActiveLeases)
+	if cfg.ReservedLeases > 0 {
+		fmt.Printf("  - Reserved for Release Controller Jobs: %d\n", cfg.ReservedLeases)
+	}
+	if len(cfg.CapacitySchedule) > 0 {
+		fmt.Printf("  - Capacity Changes: %d\n", len(cfg.CapacitySchedule))
+	}
+	if cfg.Preemption.Enabled {
+		fmt.Printf("  - Preemption: enabled, %s preempted jobs\n", preemptAction(cfg))
+	}
 	fmt.Printf("  - Job Timeout: %s\n", cfg.JobTimeoutDuration)
 	fmt.Printf("  - Lease Wait Timeout: %s\n", cfg.LeaseWaitTimeout)
 	fmt.Printf("  - Simulation Duration: %s\n", cfg.SimulationDuration)
 	fmt.Printf("  - Jobs: %d\n\n", len(cfg.Jobs))
 
 	// Create and run simulator
-	sim := simulation.NewSimulator(cfg)
-	if err := sim.Run(); err != nil {
-		return fmt.Errorf("simulation failed: %w", err)
+	// The summary only counts events, the lease chart needs the timeouts and
+	// only the timeline needs every event
+	stats := simulation.NewStatsSink()
+	timeouts := simulation.NewMemorySink()
+	warnings := &simulation.MemorySink{Events: []simulation.Event{}, Limit: warningsLimit}
+	sinks := []simulation.EventSink{
+		stats,
+		simulation.Filter(timeouts, func(event simulation.Event) bool {
+			return event.Type == simulation.EventTypeJobTimeout
+		}),
+		simulation.Filter(warnings, func(event simulation.Event) bool {
+			return event.IsWarning
+		}),
+	}
+	var memory *simulation.MemorySink
+	if showTimeline {
+		memory = simulation.NewMemorySink()
+		sinks = append(sinks, memory)
+	}
+	var eventsWriter *bufio.Writer
+	if eventsFile != "" {
+		file, err := os.Create(eventsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create events file: %w", err)
+		}
+		defer file.Close()
+
+		eventsWriter = bufio.NewWriter(file)
+		sinks = append(sinks, simulation.NewJSONLinesSink(eventsWriter))
+	}
+
+	sim := simulation.NewSimulator(cfg, append(opts, simulation.WithEventSink(simulation.FanOut(sinks...)))...)
+	result, err := sim.Run(cmd.Context())
+	if err != nil {
+		return nil, fmt.Errorf("simulation failed: %w", err)
+	}
+	if eventsWriter != nil {
+		if err := eventsWriter.Flush(); err != nil {
+			return nil, fmt.Errorf("failed to write events file: %w", err)
+		}
 	}
 
 	// Generate and display chart
 	chartGen := chart.NewGenerator()
 
-	timePoints := sim.GetTimePoints()
-	events := sim.GetEvents()
-	warnings := sim.GetWarnings()
+	timePoints := result.TimePoints
 
 	// Display lease chart
-	leaseChart := chartGen.GenerateLeaseChart(timePoints, events, cfg.MaxActiveLeases)
+	leaseChart := chartGen.GenerateLeaseChart(timePoints, timeouts.Events, cfg.MaxActiveLeases, cfg.ReservedLeases)
 	fmt.Println(leaseChart)
 
 	// Display event summary
 	if showEventSummary {
-		eventSummary := chartGen.GenerateEventSummary(events)
+		eventSummary := chartGen.GenerateEventStats(stats)
 		fmt.Println(eventSummary)
 	}
 
 	// Display warnings
-	warningsOutput := chartGen.GenerateWarnings(warnings)
+	warningsOutput := chartGen.GenerateWarnings(warnings.Events, result.WarningCount)
 	fmt.Println(warningsOutput)
 
+	// Display the lease time by phase when jobs set up or tear down
+	if hasLeasePhases(cfg) {
+		fmt.Println(chartGen.GenerateLeasePhases(result.Instances, result.Start, result.End))
+	}
+
+	// Display the preemptions and the lease time they wasted
+	if result.Metrics.Preemptions > 0 {
+		fmt.Println(chartGen.GeneratePreemptionReport(result.Instances, result.Metrics))
+	}
+
+	// Display job runs if requested
+	if showInstances {
+		fmt.Println(chartGen.GenerateInstanceReport(result.Instances, minSlip))
+	}
+
 	// Display detailed timeline if requested
 	if showTimeline {
-		timeline := chartGen.GenerateDetailedTimeline(events, timelineLimit)
+		timeline := chartGen.GenerateDetailedTimeline(memory.Events, timelineLimit)
 		fmt.Println(timeline)
 	}
 
-	return nil
+	return result, nil
+}
+
+// preemptAction returns what happens to preempted jobs, drop by default
+func preemptAction(cfg *config.Config) config.PreemptAction {
+	if cfg.Preemption.OnPreempt == "" {
+		return config.PreemptActionDrop
+	}
+	return cfg.Preemption.OnPreempt
+}
+
+// hasLeasePhases reports whether a job holds its lease for setup or teardown
+func hasLeasePhases(cfg *config.Config) bool {
+	for i := range cfg.Jobs {
+		if setup, teardown := cfg.JobPhases(&cfg.Jobs[i]); setup > 0 || teardown > 0 {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr    string
+	serveOptions = server.DefaultOptions()
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a local HTTP API and web form to simulate configurations",
+	Long: `Serve an HTTP API simulating the configurations posted to it, and a web form
+to paste a configuration, so that configurations can be tried without the CLI.
+
+  GET  /                  form to paste a configuration
+  POST /api/simulate      metrics, time points, warnings and runs as JSON
+  POST /api/chart.svg     lease usage chart as SVG
+  POST /api/report        HTML report with the chart, metrics and warnings
+
+The configuration is the YAML request body or the "config" form field, and
+the optional "seed" and "start" (RFC 3339) parameters make runs reproducible.
+Includes are not supported. Invalid configurations are rejected with status
+422, listing their problems.`,
+	Example: `  leases serve --addr :8080
+  curl --data-binary @config.yaml 'http://localhost:8080/api/simulate?seed=1'`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().Int64Var(&serveOptions.MaxBodyBytes, "max-body-bytes", serveOptions.MaxBodyBytes, "Largest configuration accepted, in bytes")
+	serveCmd.Flags().DurationVar(&serveOptions.MaxSimulationDuration, "max-simulation-duration", serveOptions.MaxSimulationDuration, "Longest simulationDuration accepted")
+	serveCmd.Flags().IntVar(&serveOptions.MaxJobs, "max-jobs", serveOptions.MaxJobs, "Highest number of jobs accepted")
+	serveCmd.Flags().IntVar(&serveOptions.MaxInstances, "max-runs", serveOptions.MaxInstances, "Highest number of job runs a configuration may trigger")
+	serveCmd.Flags().DurationVar(&serveOptions.Timeout, "timeout", serveOptions.Timeout, "Longest time a simulation may run")
+
+	rootCmd.AddCommand(serveCmd)
+}
+
+// shutdownTimeout is how long the requests in progress may take to finish
+// once the server is stopped
+const shutdownTimeout = 5 * time.Second
+
+func runServe(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	opts := serveOptions
+	opts.Logger = logger
+	srv := &http.Server{
+		Addr:              serveAddr,
+		Handler:           server.New(opts),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+	fmt.Printf("Serving on http://%s, press Ctrl-C to stop\n", serveAddr)
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to stop the server: %w", err)
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve: %w", err)
+	}
+	return nil
+}
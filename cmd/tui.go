@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/simulation"
+	"github.com/sherine-k/leases/pkg/tui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var tuiSeed int64
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Explore the simulation in an interactive terminal chart",
+	Long: `Simulate the configuration and explore the lease usage in a scrollable,
+zoomable chart. The cursor shows which jobs hold a lease and which are waiting
+at that instant, and the chart can be filtered by version and scenario.
+
+Keys:
+  ←/→ or h/l     move the cursor one column
+  H/L, PgUp/PgDn move the cursor one screen
+  g/G, Home/End  go to the start or end of the simulation
+  +/- or ]/[     zoom in or out
+  v              cycle the version filter
+  s              cycle the scenario filter
+  q, Esc         quit`,
+	RunE: runTUI,
+}
+
+func init() {
+	tuiCmd.Flags().Int64Var(&tuiSeed, "seed", 0, "Random seed of the simulation (default random)")
+
+	rootCmd.AddCommand(tuiCmd)
+}
+
+// resizePollInterval is how often the terminal size is checked for changes
+const resizePollInterval = 250 * time.Millisecond
+
+func runTUI(cmd *cobra.Command, args []string) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("leases tui needs an interactive terminal")
+	}
+
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	opts := []simulation.Option{simulation.WithLogger(logger)}
+	if tuiSeed != 0 {
+		opts = append(opts, simulation.WithSeed(tuiSeed))
+	}
+	result, err := simulation.NewSimulator(cfg, opts...).Run(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("simulation failed: %w", err)
+	}
+
+	model := tui.NewModel(filepath.Base(configFile), result, cfg.MaxActiveLeases)
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to switch the terminal to raw mode: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	// Use the alternate screen and hide the cursor until the explorer quits
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	input := make(chan []byte)
+	go readInput(input)
+
+	ticker := time.NewTicker(resizePollInterval)
+	defer ticker.Stop()
+
+	width, height := 0, 0
+	redraw := true
+	for {
+		if w, h, err := term.GetSize(fd); err == nil && (w != width || h != height) {
+			width, height = w, h
+			model.SetSize(width, height)
+			redraw = true
+		}
+		if redraw {
+			// Raw mode does not translate newlines into carriage returns
+			fmt.Print("\x1b[H\x1b[2J" + strings.ReplaceAll(model.Render(), "\n", "\r\n"))
+			redraw = false
+		}
+
+		select {
+		case <-cmd.Context().Done():
+			return nil
+		case <-ticker.C:
+		case data, ok := <-input:
+			if !ok {
+				return nil
+			}
+			for _, key := range tui.ParseKeys(data) {
+				if model.Update(key) {
+					return nil
+				}
+			}
+			redraw = true
+		}
+	}
+}
+
+// readInput sends what is typed on the terminal until stdin is closed
+func readInput(input chan<- []byte) {
+	defer close(input)
+	buf := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			input <- append([]byte(nil), buf[:n]...)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
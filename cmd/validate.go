@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [CONFIG...]",
+	Short: "Validate configuration files without running a simulation",
+	Long: `Load and validate configuration files, reporting every problem found with
+its position. When no file is given, the file of --config is validated.`,
+	SilenceUsage: true,
+	RunE:         runValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	files := args
+	if len(files) == 0 {
+		files = []string{configFile}
+	}
+
+	invalid := 0
+	for _, file := range files {
+		cfg, err := loadConfig(file)
+		if err != nil {
+			invalid++
+			fmt.Printf("%s: %v\n", file, err)
+			continue
+		}
+		fmt.Printf("%s: valid (%d jobs)\n", file, len(cfg.Jobs))
+	}
+
+	if invalid > 0 {
+		return &CheckError{Err: fmt.Errorf("%d of %d configurations are invalid", invalid, len(files))}
+	}
+
+	return nil
+}
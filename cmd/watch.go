@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sherine-k/leases/pkg/chart"
+	"github.com/sherine-k/leases/pkg/simulation"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var watch bool
+
+// watchDebounce is how long to wait for more changes after a file changed,
+// since editors often save a file in several writes or with a rename
+const watchDebounce = 200 * time.Millisecond
+
+// watchSimulation runs the simulation, then runs it again every time the
+// configuration files change, printing how the metrics changed. Failed
+// gates and configuration errors are reported without stopping the watch.
+func watchSimulation(cmd *cobra.Command) error {
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	cmd.SetContext(ctx)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch the configuration: %w", err)
+	}
+	defer watcher.Close()
+
+	// The start time and seed are kept across runs, so that the changes
+	// only come from the edited configuration
+	start := simulation.LastMonday(time.Now())
+	seed := time.Now().UnixNano()
+	clearScreen := term.IsTerminal(int(os.Stdout.Fd()))
+
+	files := []string{configFile}
+	var previous *chart.Scenario
+	for {
+		if clearScreen {
+			fmt.Print("\x1b[H\x1b[2J")
+		}
+
+		opts := []simulation.Option{
+			simulation.WithStartTime(start),
+			simulation.WithSeed(seed),
+			simulation.WithLogger(logger),
+		}
+		current, loaded, err := watchRun(cmd, opts)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		if len(loaded) > 0 {
+			files = loaded
+		}
+		if current != nil {
+			if previous != nil {
+				fmt.Println(chart.NewGenerator().GenerateMetricsDiff(*previous, *current))
+			}
+			previous = current
+			previous.Name = "previous"
+		}
+
+		if err := watchFiles(watcher, files); err != nil {
+			return err
+		}
+		if len(files) == 1 {
+			fmt.Printf("Watching %s for changes, press Ctrl-C to stop\n", files[0])
+		} else {
+			fmt.Printf("Watching %s and %d included files for changes, press Ctrl-C to stop\n", files[0], len(files)-1)
+		}
+
+		if err := waitForChange(ctx, watcher, files); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// watchRun loads and simulates the configuration once, returning the
+// outcome to compare with the next run and the files to watch
+func watchRun(cmd *cobra.Command, opts []simulation.Option) (*chart.Scenario, []string, error) {
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	result, err := simulate(cmd, cfg, opts...)
+	if err != nil {
+		return nil, cfg.Files(), err
+	}
+	if err := gateError(checkGate(result.Metrics)); err != nil {
+		fmt.Printf("%v\n", err)
+	}
+
+	scenario := &chart.Scenario{
+		Name:       "current",
+		MaxLeases:  cfg.MaxActiveLeases,
+		Metrics:    result.Metrics,
+		TimePoints: result.TimePoints,
+	}
+	return scenario, cfg.Files(), nil
+}
+
+// watchFiles watches the directories of the files, since editors often
+// replace a file rather than write to it, which ends a watch on the file
+func watchFiles(watcher *fsnotify.Watcher, files []string) error {
+	watched := make(map[string]bool)
+	for _, dir := range watcher.WatchList() {
+		watched[dir] = true
+	}
+
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		if watched[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+		watched[dir] = true
+	}
+	return nil
+}
+
+// waitForChange blocks until one of the files changes and no other change
+// follows within watchDebounce
+func waitForChange(ctx context.Context, watcher *fsnotify.Watcher, files []string) error {
+	names := make(map[string]bool)
+	for _, file := range files {
+		names[filepath.Clean(file)] = true
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-debounce:
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return errors.New("configuration watch closed")
+			}
+			if names[filepath.Clean(event.Name)] && event.Op != fsnotify.Chmod {
+				debounce = time.After(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return errors.New("configuration watch closed")
+			}
+			return fmt.Errorf("failed to watch the configuration: %w", err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package calibrate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// tolerance is the relative lease-hours difference under which no
+// duration or release rate adjustment is suggested
+const tolerance = 0.05
+
+// sampleInterval is the interval between the simulated time points, which
+// are aligned with the samples
+const sampleInterval = 5 * time.Minute
+
+// Options controls the calibration runs
+type Options struct {
+	Seeds    int
+	BaseSeed int64
+	// Warmup is simulated before the first sample, so that jobs started
+	// before the series began hold leases when the comparison starts
+	Warmup time.Duration
+}
+
+// Usage summarises the lease usage over the compared window
+type Usage struct {
+	PeakLeases     float64
+	TimeAtCapacity time.Duration
+	LeaseHours     float64
+}
+
+// Suggestion is a configuration adjustment bringing the simulation closer to
+// the real usage, with the --set override applying it when there is one
+type Suggestion struct {
+	Message string
+	Set     string
+}
+
+// Report compares the real lease usage with the simulation of the same window
+type Report struct {
+	Start     time.Time
+	End       time.Time
+	Samples   int
+	Seeds     int
+	MaxLeases int
+
+	// RMSE and Bias compare every sample with the simulated usage averaged over
+	// the seeds; Bias is positive when the simulation uses more leases
+	RMSE float64
+	Bias float64
+
+	// Real and Simulated are measured at the sample times, the simulated
+	// values being averaged over the seeds
+	Real      Usage
+	Simulated Usage
+
+	// ReleaseControllerLeaseHours is the part of the simulated lease-hours
+	// used by release controller jobs
+	ReleaseControllerLeaseHours float64
+
+	Suggestions []Suggestion
+}
+
+// Calibrate simulates the configuration over the window of the samples with
+// several seeds and compares the simulated lease usage with the samples
+func Calibrate(ctx context.Context, cfg *config.Config, samples []Sample, opts Options) (*Report, error) {
+	if len(samples) < 2 {
+		return nil, fmt.Errorf("at least 2 samples are needed, found %d", len(samples))
+	}
+	if opts.Seeds <= 0 {
+		return nil, fmt.Errorf("number of seeds must be greater than 0")
+	}
+	if opts.Warmup < 0 {
+		return nil, fmt.Errorf("warmup must not be negative, got %s", opts.Warmup)
+	}
+
+	start, end := samples[0].Time, samples[len(samples)-1].Time
+	report := &Report{
+		Start:     start,
+		End:       end,
+		Samples:   len(samples),
+		Seeds:     opts.Seeds,
+		MaxLeases: cfg.MaxActiveLeases,
+	}
+
+	observed := make([]float64, len(samples))
+	for i, sample := range samples {
+		observed[i] = sample.Leases
+	}
+	report.Real = measure(samples, observed, cfg.MaxActiveLeases)
+
+	// The window ends past the last sample, for the jobs due at its time to run
+	runCfg := *cfg
+	runCfg.SimulationDuration = config.Duration{Duration: opts.Warmup + end.Sub(start) + sampleInterval}
+
+	mean := make([]float64, len(samples))
+	for i := 0; i < opts.Seeds; i++ {
+		sim := simulation.NewSimulator(&runCfg,
+			simulation.WithStartTime(start.Add(-opts.Warmup)),
+			simulation.WithSeed(opts.BaseSeed+int64(i)),
+			simulation.WithSampleInterval(sampleInterval))
+		result, err := sim.Run(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("simulation with seed %d failed: %w", opts.BaseSeed+int64(i), err)
+		}
+
+		simulated := align(samples, result.TimePoints)
+		usage := measure(samples, simulated, cfg.MaxActiveLeases)
+		report.Simulated.PeakLeases += usage.PeakLeases / float64(opts.Seeds)
+		report.Simulated.TimeAtCapacity += usage.TimeAtCapacity / time.Duration(opts.Seeds)
+		report.Simulated.LeaseHours += usage.LeaseHours / float64(opts.Seeds)
+		report.ReleaseControllerLeaseHours += releaseControllerLeaseHours(result.Instances, start, end) / float64(opts.Seeds)
+
+		for j, leases := range simulated {
+			mean[j] += leases / float64(opts.Seeds)
+		}
+	}
+
+	var squares float64
+	for i := range samples {
+		diff := mean[i] - observed[i]
+		squares += diff * diff
+		report.Bias += diff / float64(len(samples))
+	}
+	report.RMSE = math.Sqrt(squares / float64(len(samples)))
+
+	report.Suggestions = suggest(cfg, report)
+
+	return report, nil
+}
+
+// align returns the simulated leases in use at every sample time, from the
+// last time point at or before it
+func align(samples []Sample, timePoints []simulation.TimePoint) []float64 {
+	values := make([]float64, len(samples))
+	for i, sample := range samples {
+		j := sort.Search(len(timePoints), func(j int) bool {
+			return timePoints[j].Time.After(sample.Time)
+		})
+		if j > 0 {
+			values[i] = float64(timePoints[j-1].ActiveLeases)
+		}
+	}
+	return values
+}
+
+// measure summarises a usage series, each sample holding until the next one
+func measure(samples []Sample, values []float64, maxLeases int) Usage {
+	var usage Usage
+	for i, leases := range values {
+		usage.PeakLeases = max(usage.PeakLeases, leases)
+		if i == len(samples)-1 {
+			break
+		}
+		held := samples[i+1].Time.Sub(samples[i].Time)
+		usage.LeaseHours += leases * held.Hours()
+		if leases >= float64(maxLeases) {
+			usage.TimeAtCapacity += held
+		}
+	}
+	return usage
+}
+
+// releaseControllerLeaseHours returns the lease-hours used by release
+// controller jobs between start and end
+func releaseControllerLeaseHours(instances []*config.JobInstance, start, end time.Time) float64 {
+	var hours float64
+	for _, instance := range instances {
+		if instance.Job.ReleaseController() {
+			setup, test, teardown := simulation.LeasePhases(instance, start, end)
+			hours += (setup + test + teardown).Hours()
+		}
+	}
+	return hours
+}
+
+// suggest proposes duration and release rate adjustments closing the
+// lease-hours gap, and points out peak differences
+func suggest(cfg *config.Config, report *Report) []Suggestion {
+	suggestions := []Suggestion{}
+	observed, simulated := report.Real, report.Simulated
+
+	switch {
+	case simulated.LeaseHours == 0:
+		suggestions = append(suggestions, Suggestion{
+			Message: "The simulation uses no leases in the window: check that the configured jobs run during it",
+		})
+	case math.Abs(observed.LeaseHours/simulated.LeaseHours-1) <= tolerance:
+		suggestions = append(suggestions, Suggestion{
+			Message: fmt.Sprintf("Simulated lease-hours are within %.0f%% of the real usage: durations and release rate need no adjustment", tolerance*100),
+		})
+	default:
+		scale := observed.LeaseHours / simulated.LeaseHours
+		suggestions = append(suggestions, Suggestion{
+			Message: fmt.Sprintf("Scale the job durations by %.2f to match the real lease-hours (%.1f real, %.1f simulated)", scale, observed.LeaseHours, simulated.LeaseHours),
+		})
+
+		// Release controller jobs run about as many times as releases are
+		// triggered, so their lease-hours scale with the release rate
+		rcHours := report.ReleaseControllerLeaseHours
+		if rate := (rcHours + observed.LeaseHours - simulated.LeaseHours) / rcHours; rcHours > 0 && rate > 0 {
+			minInterval, maxInterval := cfg.ReleaseInterval.Bounds()
+			minInterval = scaleInterval(minInterval, rate)
+			maxInterval = max(scaleInterval(maxInterval, rate), minInterval)
+			suggestions = append(suggestions, Suggestion{
+				Message: fmt.Sprintf("Or trigger releases %.2f times as often, every %s to %s", rate, config.Duration{Duration: minInterval}, config.Duration{Duration: maxInterval}),
+				Set:     fmt.Sprintf("releaseInterval={min: %s, max: %s}", config.Duration{Duration: minInterval}, config.Duration{Duration: maxInterval}),
+			})
+		}
+	}
+
+	if diff := observed.PeakLeases - simulated.PeakLeases; diff >= 1 {
+		suggestions = append(suggestions, Suggestion{
+			Message: fmt.Sprintf("Real usage peaks %.1f leases above the simulation: jobs may be missing from the configuration or overlap more than their schedules suggest", diff),
+		})
+	} else if diff <= -1 {
+		suggestions = append(suggestions, Suggestion{
+			Message: fmt.Sprintf("Real usage peaks %.1f leases below the simulation: some configured jobs may not run or start later than scheduled", -diff),
+		})
+	}
+
+	return suggestions
+}
+
+// scaleInterval divides a release interval by a rate, rounded to the minute
+// and no shorter than the configuration accepts
+func scaleInterval(interval time.Duration, rate float64) time.Duration {
+	return max(time.Duration(float64(interval)/rate).Round(time.Minute), config.MinReleaseInterval)
+}
@@ -0,0 +1,260 @@
+package calibrate
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		column  string
+		want    []float64
+		wantErr string
+	}{
+		{
+			name:  "no header",
+			input: "2024-01-01T00:00:00Z,3\n2024-01-01T00:05:00Z,4\n",
+			want:  []float64{3, 4},
+		},
+		{
+			name:  "unix timestamps out of order",
+			input: "1704067500,4\n1704067200,3\n",
+			want:  []float64{3, 4},
+		},
+		{
+			name:   "header and column",
+			input:  "time,free,leased\n2024-01-01T00:00:00Z,7,3\n2024-01-01T00:05:00Z,6,4\n",
+			column: "leased",
+			want:   []float64{3, 4},
+		},
+		{
+			name:    "unknown column",
+			input:   "time,leased\n2024-01-01T00:00:00Z,3\n2024-01-01T00:05:00Z,4\n",
+			column:  "busy",
+			wantErr: `column "busy" not found`,
+		},
+		{
+			name:    "invalid value",
+			input:   "2024-01-01T00:00:00Z,3\n2024-01-01T00:05:00Z,many\n",
+			wantErr: `line 2: invalid lease count "many"`,
+		},
+		{
+			name:    "duplicate time",
+			input:   "2024-01-01T00:00:00Z,3\n2024-01-01T00:00:00Z,4\n",
+			wantErr: "duplicate sample",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, err := ParseCSV(strings.NewReader(tt.input), tt.column)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseCSV() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCSV() error = %v", err)
+			}
+			assertSamples(t, samples, tt.want)
+		})
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []float64
+	}{
+		{
+			name:  "array",
+			input: `[{"time": "2024-01-01T00:00:00Z", "value": 3}, {"time": 1704067500, "value": 4}]`,
+			want:  []float64{3, 4},
+		},
+		{
+			name: "prometheus range query",
+			input: `{"status": "success", "data": {"resultType": "matrix", "result": [
+				{"metric": {"type": "aws"}, "values": [[1704067200, "2"], [1704067500, "3"]]},
+				{"metric": {"type": "gcp"}, "values": [[1704067200, "1"], [1704067500, "1"]]}
+			]}}`,
+			want: []float64{3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, err := ParseJSON([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("ParseJSON() error = %v", err)
+			}
+			assertSamples(t, samples, tt.want)
+		})
+	}
+}
+
+// assertSamples checks the sample values and that they are 5 minutes apart from testStart
+func assertSamples(t *testing.T, samples []Sample, want []float64) {
+	t.Helper()
+
+	if len(samples) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(samples), len(want))
+	}
+	for i, sample := range samples {
+		wantTime := testStart.Add(time.Duration(i) * 5 * time.Minute)
+		if !sample.Time.Equal(wantTime) || sample.Leases != want[i] {
+			t.Errorf("sample %d = %s %g, want %s %g", i, sample.Time.Format(time.RFC3339), sample.Leases, wantTime.Format(time.RFC3339), want[i])
+		}
+	}
+}
+
+// hourlyConfig returns a configuration with one job holding a lease for the
+// first half of every hour and a release controller job
+func hourlyConfig() *config.Config {
+	return &config.Config{
+		MaxActiveLeases:    2,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: 2 * time.Hour},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		Jobs: []config.Job{
+			{
+				Name:         "hourly",
+				Version:      "4.20",
+				Duration:     config.Duration{Duration: 30 * time.Minute},
+				TriggerType:  config.TriggerTypeCron,
+				CronSchedule: "0 * * * *",
+			},
+			{
+				Name:                "rc-e2e",
+				Version:             "4.20",
+				Duration:            config.Duration{Duration: time.Hour},
+				TriggerType:         config.TriggerTypeReleaseController,
+				IsReleaseController: true,
+			},
+		},
+	}
+}
+
+// hourlySamples returns one sample every 5 minutes over a day, with the
+// leases used by the given number of hourly jobs
+func hourlySamples(jobs float64) []Sample {
+	samples := []Sample{}
+	for t := testStart; !t.After(testStart.Add(24 * time.Hour)); t = t.Add(5 * time.Minute) {
+		leases := 0.0
+		if t.Minute() < 30 {
+			leases = jobs
+		}
+		samples = append(samples, Sample{Time: t, Leases: leases})
+	}
+	return samples
+}
+
+func TestCalibrate(t *testing.T) {
+	cfg := hourlyConfig()
+	cfg.Jobs = cfg.Jobs[:1]
+
+	report, err := Calibrate(context.Background(), cfg, hourlySamples(1), Options{Seeds: 2, BaseSeed: 1, Warmup: time.Hour})
+	if err != nil {
+		t.Fatalf("Calibrate() error = %v", err)
+	}
+
+	if report.RMSE != 0 || report.Bias != 0 {
+		t.Errorf("RMSE = %g, bias = %g, want 0 for a matching series", report.RMSE, report.Bias)
+	}
+	if report.Real != report.Simulated {
+		t.Errorf("real usage %+v, simulated %+v, want equal", report.Real, report.Simulated)
+	}
+	if math.Abs(report.Real.LeaseHours-12) > 1e-9 || report.Real.PeakLeases != 1 || report.Real.TimeAtCapacity != 0 {
+		t.Errorf("real usage = %+v, want 12 lease-hours and a peak of 1", report.Real)
+	}
+	if len(report.Suggestions) != 1 || !strings.Contains(report.Suggestions[0].Message, "no adjustment") {
+		t.Errorf("suggestions = %+v, want no adjustment", report.Suggestions)
+	}
+}
+
+func TestCalibrateSuggestions(t *testing.T) {
+	report, err := Calibrate(context.Background(), hourlyConfig(), hourlySamples(3), Options{Seeds: 3, BaseSeed: 1, Warmup: time.Hour})
+	if err != nil {
+		t.Fatalf("Calibrate() error = %v", err)
+	}
+
+	if report.Real.TimeAtCapacity != 12*time.Hour {
+		t.Errorf("real time at capacity = %s, want 12h", report.Real.TimeAtCapacity)
+	}
+	if report.Bias >= 0 {
+		t.Errorf("bias = %g, want the simulation below the real usage", report.Bias)
+	}
+	if report.ReleaseControllerLeaseHours <= 0 {
+		t.Errorf("release controller lease-hours = %g, want some", report.ReleaseControllerLeaseHours)
+	}
+
+	messages := []string{}
+	set := ""
+	for _, suggestion := range report.Suggestions {
+		messages = append(messages, suggestion.Message)
+		if suggestion.Set != "" {
+			set = suggestion.Set
+		}
+	}
+	joined := strings.Join(messages, "\n")
+	for _, want := range []string{"Scale the job durations by", "Or trigger releases", "Real usage peaks"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("suggestions %q do not contain %q", joined, want)
+		}
+	}
+
+	// The suggested override must be accepted by --set
+	if err := (config.Overrides{Set: []string{set}}).Apply(hourlyConfig()); err != nil {
+		t.Errorf("suggested override %q: %v", set, err)
+	}
+}
+
+func TestSuggestReleaseIntervalFloor(t *testing.T) {
+	// Release controller jobs would have to run 91 times as often, every
+	// minute or two, which is shorter than releaseInterval accepts
+	suggestions := suggest(hourlyConfig(), &Report{
+		Real:                        Usage{LeaseHours: 100},
+		Simulated:                   Usage{LeaseHours: 10},
+		ReleaseControllerLeaseHours: 1,
+	})
+
+	set := ""
+	for _, suggestion := range suggestions {
+		if suggestion.Set != "" {
+			set = suggestion.Set
+		}
+	}
+	if want := "releaseInterval={min: 5m, max: 5m}"; set != want {
+		t.Errorf("suggested override = %q, want %q", set, want)
+	}
+	cfg := hourlyConfig()
+	if err := (config.Overrides{Set: []string{set}}).Apply(cfg); err != nil {
+		t.Fatalf("suggested override %q: %v", set, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("suggested override %q: %v", set, err)
+	}
+}
+
+func TestCalibrateErrors(t *testing.T) {
+	samples := hourlySamples(1)
+	if _, err := Calibrate(context.Background(), hourlyConfig(), samples[:1], Options{Seeds: 1}); err == nil {
+		t.Error("Calibrate() with one sample succeeded, want an error")
+	}
+	if _, err := Calibrate(context.Background(), hourlyConfig(), samples, Options{Seeds: 0}); err == nil {
+		t.Error("Calibrate() with no seeds succeeded, want an error")
+	}
+	if _, err := Calibrate(context.Background(), hourlyConfig(), samples, Options{Seeds: 1, Warmup: -time.Hour}); err == nil {
+		t.Error("Calibrate() with a negative warmup succeeded, want an error")
+	}
+}
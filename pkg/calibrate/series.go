@@ -0,0 +1,232 @@
+package calibrate
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sample is a number of leases observed in use at a point in time
+type Sample struct {
+	Time   time.Time
+	Leases float64
+}
+
+// LoadSeries reads a lease usage time series from a CSV or JSON file, chosen
+// by the file extension. The column selects the CSV value column by header
+// name, the second column being used when it is empty.
+func LoadSeries(path, column string) ([]Sample, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []Sample
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		samples, err = ParseJSON(data)
+	case ".csv", ".txt":
+		samples, err = ParseCSV(bytes.NewReader(data), column)
+	default:
+		return nil, fmt.Errorf("unsupported series file %s, expected a .csv or .json file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return samples, nil
+}
+
+// ParseCSV reads a series with one sample per row: a time in the first column
+// and the number of leases in use in the value column. A first row whose time
+// cannot be parsed is a header naming the columns.
+func ParseCSV(r io.Reader, column string) ([]Sample, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no samples found")
+	}
+
+	valueColumn := 1
+	firstRow := 0
+	if _, err := parseTime(records[0][0]); err != nil {
+		firstRow = 1
+		if column != "" {
+			valueColumn = -1
+			for i, name := range records[0] {
+				if strings.EqualFold(strings.TrimSpace(name), column) {
+					valueColumn = i
+				}
+			}
+			if valueColumn < 0 {
+				return nil, fmt.Errorf("column %q not found in the header %q", column, strings.Join(records[0], ","))
+			}
+		}
+	} else if column != "" {
+		return nil, fmt.Errorf("column %q requested but the file has no header", column)
+	}
+
+	samples := make([]Sample, 0, len(records)-firstRow)
+	for i, record := range records[firstRow:] {
+		line := firstRow + i + 1
+		if valueColumn >= len(record) {
+			return nil, fmt.Errorf("line %d: missing value column %d", line, valueColumn+1)
+		}
+		t, err := parseTime(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		leases, err := parseLeases(record[valueColumn])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		samples = append(samples, Sample{Time: t, Leases: leases})
+	}
+
+	return normalize(samples)
+}
+
+// ParseJSON reads a series either as an array of {"time": ..., "value": ...}
+// objects or as a Prometheus range query response, whose series are summed
+func ParseJSON(data []byte) ([]Sample, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return parseJSONArray(trimmed)
+	}
+	return parsePrometheus(trimmed)
+}
+
+// parseJSONArray reads an array of {"time": ..., "value": ...} objects
+func parseJSONArray(data []byte) ([]Sample, error) {
+	var points []struct {
+		Time  json.RawMessage `json:"time"`
+		Value *float64        `json:"value"`
+	}
+	if err := json.Unmarshal(data, &points); err != nil {
+		return nil, err
+	}
+
+	samples := make([]Sample, 0, len(points))
+	for i, point := range points {
+		if point.Time == nil || point.Value == nil {
+			return nil, fmt.Errorf("sample %d: time and value are required", i)
+		}
+		t, err := parseTime(strings.Trim(string(point.Time), `"`))
+		if err != nil {
+			return nil, fmt.Errorf("sample %d: %w", i, err)
+		}
+		if *point.Value < 0 {
+			return nil, fmt.Errorf("sample %d: negative lease count %g", i, *point.Value)
+		}
+		samples = append(samples, Sample{Time: t, Leases: *point.Value})
+	}
+
+	return normalize(samples)
+}
+
+// parsePrometheus reads the response of a Prometheus range query, summing
+// the values of all its series at every timestamp
+func parsePrometheus(data []byte) ([]Sample, error) {
+	var response struct {
+		Status string `json:"status"`
+		Data   struct {
+			ResultType string `json:"resultType"`
+			Result     []struct {
+				Values [][2]json.RawMessage `json:"values"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	if response.Status != "success" || response.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("expected a successful Prometheus range query response (status %q, result type %q)", response.Status, response.Data.ResultType)
+	}
+
+	totals := make(map[time.Time]float64)
+	for _, series := range response.Data.Result {
+		for _, value := range series.Values {
+			t, err := parseTime(string(value[0]))
+			if err != nil {
+				return nil, err
+			}
+			leases, err := parseLeases(strings.Trim(string(value[1]), `"`))
+			if err != nil {
+				return nil, err
+			}
+			totals[t] += leases
+		}
+	}
+
+	samples := make([]Sample, 0, len(totals))
+	for t, leases := range totals {
+		samples = append(samples, Sample{Time: t, Leases: leases})
+	}
+	return normalize(samples)
+}
+
+// parseTime parses an RFC 3339 time or a Unix timestamp in seconds or milliseconds
+func parseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse(time.DateTime, value); err == nil {
+		return t, nil
+	}
+
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected an RFC 3339 time or a Unix timestamp", value)
+	}
+	if seconds > 1e12 {
+		seconds /= 1000
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+}
+
+// parseLeases parses a number of leases in use
+func parseLeases(value string) (float64, error) {
+	leases, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || math.IsNaN(leases) || math.IsInf(leases, 0) {
+		return 0, fmt.Errorf("invalid lease count %q", value)
+	}
+	if leases < 0 {
+		return 0, fmt.Errorf("negative lease count %g", leases)
+	}
+	return leases, nil
+}
+
+// normalize sorts the samples by time and rejects series that cannot be
+// compared with a simulation
+func normalize(samples []Sample) ([]Sample, error) {
+	if len(samples) < 2 {
+		return nil, fmt.Errorf("at least 2 samples are needed, found %d", len(samples))
+	}
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Time.Before(samples[j].Time)
+	})
+	for i := 1; i < len(samples); i++ {
+		if samples[i].Time.Equal(samples[i-1].Time) {
+			return nil, fmt.Errorf("duplicate sample at %s", samples[i].Time.Format(time.RFC3339))
+		}
+	}
+	return samples, nil
+}
@@ -0,0 +1,163 @@
+package capacity
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// Targets defines the goals a lease capacity must meet to be recommended
+type Targets struct {
+	// MaxWaitTimeouts is the highest number of lease wait timeouts allowed in a run
+	MaxWaitTimeouts int
+	// MaxP95Wait is the highest allowed 95th percentile lease wait time (0 disables the check)
+	MaxP95Wait time.Duration
+	// MinUtilization is the lowest allowed fraction of lease capacity in use (0 disables the check)
+	MinUtilization float64
+}
+
+// Options controls the capacity search
+type Options struct {
+	MinLeases int
+	MaxLeases int
+	Seeds     int
+	BaseSeed  int64
+	StartTime time.Time
+	Targets   Targets
+}
+
+// Candidate holds the outcome of simulating one lease capacity across all seeds
+type Candidate struct {
+	MaxActiveLeases int
+	Runs            []simulation.Metrics
+
+	// Worst values observed across the seeded runs
+	WaitTimeouts int
+	P95Wait      time.Duration
+	MaxWait      time.Duration
+	Utilization  float64
+
+	MeetsTargets bool
+	Failures     []string
+}
+
+// Recommendation is the result of a capacity search
+type Recommendation struct {
+	Targets    Targets
+	Seeds      int
+	Candidates []Candidate
+
+	// Recommended is the lowest capacity meeting all targets, or 0 if none did
+	Recommended int
+}
+
+// Recommend simulates the configuration for every lease capacity in the
+// requested range and reports the minimum capacity that meets the targets.
+// The search starts above the reserved leases, as lower capacities leave no
+// lease to the periodic jobs. The capacity schedule is scaled with every
+// searched capacity, so that a maintenance window halving maxActiveLeases
+// still halves the searched capacity.
+func Recommend(ctx context.Context, cfg *config.Config, opts Options) (*Recommendation, error) {
+	if opts.MinLeases <= 0 {
+		return nil, fmt.Errorf("minimum lease count must be greater than 0")
+	}
+	if opts.MaxLeases < opts.MinLeases {
+		return nil, fmt.Errorf("maximum lease count %d is lower than minimum %d", opts.MaxLeases, opts.MinLeases)
+	}
+	if opts.Seeds <= 0 {
+		return nil, fmt.Errorf("number of seeds must be greater than 0")
+	}
+	minLeases := MinLeases(cfg, opts.MinLeases)
+	if opts.MaxLeases < minLeases {
+		return nil, fmt.Errorf("maximum lease count %d is not above the %d reserved leases", opts.MaxLeases, cfg.ReservedLeases)
+	}
+
+	rec := &Recommendation{
+		Targets: opts.Targets,
+		Seeds:   opts.Seeds,
+	}
+
+	for leases := minLeases; leases <= opts.MaxLeases; leases++ {
+		candidate, err := evaluate(ctx, cfg, leases, opts)
+		if err != nil {
+			return nil, err
+		}
+		rec.Candidates = append(rec.Candidates, candidate)
+
+		if candidate.MeetsTargets && rec.Recommended == 0 {
+			rec.Recommended = leases
+		}
+	}
+
+	return rec, nil
+}
+
+// MinLeases returns the lowest lease count searched from the requested one,
+// one more than the reserved leases at least
+func MinLeases(cfg *config.Config, requested int) int {
+	return max(requested, cfg.ReservedLeases+1)
+}
+
+// scaleCapacitySchedule returns a copy of the capacity changes with their
+// capacity scaled from the configured maxActiveLeases to leases, rounded
+func scaleCapacitySchedule(changes []config.CapacityChange, from, leases int) []config.CapacityChange {
+	if len(changes) == 0 || from <= 0 {
+		return changes
+	}
+	scaled := append([]config.CapacityChange(nil), changes...)
+	for i := range scaled {
+		scaled[i].MaxActiveLeases = int(math.Round(float64(scaled[i].MaxActiveLeases) * float64(leases) / float64(from)))
+	}
+	return scaled
+}
+
+// evaluate runs the simulation with the given capacity for every seed
+func evaluate(ctx context.Context, cfg *config.Config, leases int, opts Options) (Candidate, error) {
+	candidate := Candidate{MaxActiveLeases: leases}
+
+	runCfg := *cfg
+	runCfg.MaxActiveLeases = leases
+	runCfg.CapacitySchedule = scaleCapacitySchedule(cfg.CapacitySchedule, cfg.MaxActiveLeases, leases)
+
+	for i := 0; i < opts.Seeds; i++ {
+		sim := simulation.NewSimulator(&runCfg, simulation.WithStartTime(opts.StartTime), simulation.WithSeed(opts.BaseSeed+int64(i)))
+		result, err := sim.Run(ctx)
+		if err != nil {
+			return candidate, fmt.Errorf("simulation with %d leases failed: %w", leases, err)
+		}
+
+		metrics := result.Metrics
+		candidate.Runs = append(candidate.Runs, metrics)
+
+		if metrics.WaitTimeouts > candidate.WaitTimeouts {
+			candidate.WaitTimeouts = metrics.WaitTimeouts
+		}
+		if metrics.P95Wait > candidate.P95Wait {
+			candidate.P95Wait = metrics.P95Wait
+		}
+		if metrics.MaxWait > candidate.MaxWait {
+			candidate.MaxWait = metrics.MaxWait
+		}
+		if i == 0 || metrics.Utilization < candidate.Utilization {
+			candidate.Utilization = metrics.Utilization
+		}
+	}
+
+	targets := opts.Targets
+	if candidate.WaitTimeouts > targets.MaxWaitTimeouts {
+		candidate.Failures = append(candidate.Failures, fmt.Sprintf("%d wait timeouts > %d", candidate.WaitTimeouts, targets.MaxWaitTimeouts))
+	}
+	if targets.MaxP95Wait > 0 && candidate.P95Wait > targets.MaxP95Wait {
+		candidate.Failures = append(candidate.Failures, fmt.Sprintf("p95 wait %s > %s", candidate.P95Wait, targets.MaxP95Wait))
+	}
+	if targets.MinUtilization > 0 && candidate.Utilization < targets.MinUtilization {
+		candidate.Failures = append(candidate.Failures, fmt.Sprintf("utilisation %.1f%% < %.1f%%", candidate.Utilization*100, targets.MinUtilization*100))
+	}
+	candidate.MeetsTargets = len(candidate.Failures) == 0
+
+	return candidate, nil
+}
@@ -0,0 +1,154 @@
+package capacity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testConfig has three periodic jobs triggered at 01:00 for a day, so that
+// fewer than three leases make them wait
+func testConfig() *config.Config {
+	job := func(name string) config.Job {
+		return config.Job{
+			Name:         name,
+			Version:      "4.19",
+			Duration:     config.Duration{Duration: 2 * time.Hour},
+			TriggerType:  config.TriggerTypeCron,
+			CronSchedule: "0 1 * * *",
+		}
+	}
+	return &config.Config{
+		MaxActiveLeases:    3,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: time.Hour},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		Jobs:               []config.Job{job("a"), job("b"), job("c")},
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	tests := []struct {
+		name            string
+		minLeases       int
+		maxLeases       int
+		targets         Targets
+		wantCandidates  int
+		wantRecommended int
+	}{
+		{name: "lowest capacity without timeouts", minLeases: 1, maxLeases: 4, wantCandidates: 4, wantRecommended: 3},
+		{name: "timeouts allowed", minLeases: 1, maxLeases: 4, targets: Targets{MaxWaitTimeouts: 1}, wantCandidates: 4, wantRecommended: 2},
+		{name: "single capacity meeting the targets", minLeases: 3, maxLeases: 3, wantCandidates: 1, wantRecommended: 3},
+		{name: "maximum below the needed capacity", minLeases: 1, maxLeases: 2, wantCandidates: 2, wantRecommended: 0},
+		{name: "utilisation too low", minLeases: 1, maxLeases: 4, targets: Targets{MinUtilization: 0.5}, wantCandidates: 4, wantRecommended: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := Recommend(context.Background(), testConfig(), Options{
+				MinLeases: tt.minLeases,
+				MaxLeases: tt.maxLeases,
+				Seeds:     2,
+				StartTime: testStart,
+				Targets:   tt.targets,
+			})
+			if err != nil {
+				t.Fatalf("Recommend() error = %v", err)
+			}
+			if len(rec.Candidates) != tt.wantCandidates || rec.Candidates[0].MaxActiveLeases != tt.minLeases {
+				t.Errorf("searched %d candidates from %d, want %d from %d", len(rec.Candidates), rec.Candidates[0].MaxActiveLeases, tt.wantCandidates, tt.minLeases)
+			}
+			if rec.Recommended != tt.wantRecommended {
+				t.Errorf("Recommended = %d, want %d", rec.Recommended, tt.wantRecommended)
+			}
+			for _, candidate := range rec.Candidates {
+				if len(candidate.Runs) != 2 {
+					t.Errorf("%d leases simulated %d times, want once per seed", candidate.MaxActiveLeases, len(candidate.Runs))
+				}
+			}
+		})
+	}
+}
+
+func TestRecommendCapacitySchedule(t *testing.T) {
+	// Two of the three leases all day long
+	cfg := testConfig()
+	cfg.CapacitySchedule = []config.CapacityChange{{Name: "shortage", MaxActiveLeases: 2, At: testStart}}
+
+	rec, err := Recommend(context.Background(), cfg, Options{MinLeases: 1, MaxLeases: 6, Seeds: 1, StartTime: testStart})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v", err)
+	}
+	// Four leases are scaled to three during the shortage, enough for the three jobs
+	if rec.Recommended != 4 {
+		t.Errorf("Recommended = %d, want 4", rec.Recommended)
+	}
+	if cfg.CapacitySchedule[0].MaxActiveLeases != 2 {
+		t.Errorf("Recommend() changed the capacity schedule of the configuration to %d leases", cfg.CapacitySchedule[0].MaxActiveLeases)
+	}
+}
+
+func TestRecommendInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "no minimum", opts: Options{MinLeases: 0, MaxLeases: 3, Seeds: 1}},
+		{name: "maximum below minimum", opts: Options{MinLeases: 4, MaxLeases: 3, Seeds: 1}},
+		{name: "no seeds", opts: Options{MinLeases: 1, MaxLeases: 3, Seeds: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Recommend(context.Background(), testConfig(), tt.opts); err == nil {
+				t.Error("Recommend() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestMinLeases(t *testing.T) {
+	tests := []struct {
+		reserved  int
+		requested int
+		want      int
+	}{
+		{reserved: 0, requested: 1, want: 1},
+		{reserved: 2, requested: 1, want: 3},
+		{reserved: 2, requested: 3, want: 3},
+		{reserved: 2, requested: 5, want: 5},
+	}
+
+	for _, tt := range tests {
+		cfg := testConfig()
+		cfg.ReservedLeases = tt.reserved
+		if got := MinLeases(cfg, tt.requested); got != tt.want {
+			t.Errorf("MinLeases() with %d reserved and %d requested = %d, want %d", tt.reserved, tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestRecommendReservedLeases(t *testing.T) {
+	cfg := testConfig()
+	cfg.ReservedLeases = 2
+
+	rec, err := Recommend(context.Background(), cfg, Options{MinLeases: 1, MaxLeases: 6, Seeds: 1, StartTime: testStart})
+	if err != nil {
+		t.Fatalf("Recommend() error = %v", err)
+	}
+	if first := rec.Candidates[0].MaxActiveLeases; first != 3 || len(rec.Candidates) != 4 {
+		t.Errorf("searched %d candidates from %d, want 4 from 3, above the reserved leases", len(rec.Candidates), first)
+	}
+	// The periodic jobs need three leases besides the two reserved
+	if rec.Recommended != 5 {
+		t.Errorf("Recommended = %d, want 5", rec.Recommended)
+	}
+
+	if _, err := Recommend(context.Background(), cfg, Options{MinLeases: 1, MaxLeases: 2, Seeds: 1, StartTime: testStart}); err == nil {
+		t.Error("Recommend() with no capacity above the reserved leases succeeded, want an error")
+	}
+}
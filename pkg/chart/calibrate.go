@@ -0,0 +1,62 @@
+package chart
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sherine-k/leases/pkg/calibrate"
+)
+
+// GenerateCalibration generates the comparison of the real lease usage with
+// the simulation of the same window, and the suggested adjustments
+func (g *Generator) GenerateCalibration(report *calibrate.Report) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString("Calibration Against Real Lease Usage\n")
+	sb.WriteString(strings.Repeat("=", g.width))
+	sb.WriteString("\n\n")
+
+	sb.WriteString(fmt.Sprintf("Window: %s to %s (%d samples, %d seeds, %d leases)\n\n",
+		report.Start.Format("2006-01-02 15:04"),
+		report.End.Format("2006-01-02 15:04"),
+		report.Samples,
+		report.Seeds,
+		report.MaxLeases))
+
+	rows := []struct {
+		label string
+		unit  metricUnit
+		value func(calibrate.Usage) float64
+	}{
+		{"Peak leases", unitHours, func(u calibrate.Usage) float64 { return u.PeakLeases }},
+		{"Time at capacity", unitDuration, func(u calibrate.Usage) float64 { return float64(u.TimeAtCapacity) }},
+		{"Lease-hours", unitHours, func(u calibrate.Usage) float64 { return u.LeaseHours }},
+	}
+	sb.WriteString(fmt.Sprintf("%-20s  %10s  %10s  %10s\n", "", "Real", "Simulated", "Difference"))
+	for _, row := range rows {
+		observed, simulated := row.value(report.Real), row.value(report.Simulated)
+		sb.WriteString(fmt.Sprintf("  - %-16s  %10s  %10s  %10s\n",
+			row.label,
+			formatMetric(row.unit, observed),
+			formatMetric(row.unit, simulated),
+			formatMetricChange(row.unit, simulated-observed)))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("Error of the simulated usage, averaged over the seeds:\n")
+	sb.WriteString(fmt.Sprintf("  - RMSE: %.2f leases\n", report.RMSE))
+	sb.WriteString(fmt.Sprintf("  - Mean bias: %+.2f leases\n", report.Bias))
+	sb.WriteString("\n")
+
+	sb.WriteString("Suggestions:\n")
+	for _, suggestion := range report.Suggestions {
+		sb.WriteString(fmt.Sprintf("  - %s\n", suggestion.Message))
+		if suggestion.Set != "" {
+			sb.WriteString(fmt.Sprintf("    --set '%s'\n", suggestion.Set))
+		}
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
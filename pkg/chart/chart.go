@@ -5,6 +5,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/sherine-k/leases/pkg/capacity"
+	"github.com/sherine-k/leases/pkg/optimize"
 	"github.com/sherine-k/leases/pkg/simulation"
 )
 
@@ -27,8 +29,11 @@ func NewGenerator() *Generator {
 	}
 }
 
-// GenerateLeaseChart generates an ASCII chart showing lease usage over time
-func (g *Generator) GenerateLeaseChart(timePoints []simulation.TimePoint, events []simulation.Event, maxLeases int) string {
+// GenerateLeaseChart generates an ASCII chart showing lease usage over time.
+// The top reservedLeases slots of the capacity are reserved for release
+// controller jobs, and the slots above the capacity of the time points,
+// changed from maxLeases by the capacity schedule, are unavailable.
+func (g *Generator) GenerateLeaseChart(timePoints []simulation.TimePoint, events []simulation.Event, maxLeases, reservedLeases int) string {
 	if len(timePoints) == 0 {
 		return "No data to display"
 	}
@@ -44,8 +49,10 @@ func (g *Generator) GenerateLeaseChart(timePoints []simulation.TimePoint, events
 	// Build enhanced time points with timeout information
 	type EnhancedTimePoint struct {
 		ActiveLeases  int
+		CleanupLeases int
 		WaitingJobs   int
 		TimeoutJobs   int
+		MaxLeases     int
 	}
 
 	enhancedPoints := make([]EnhancedTimePoint, len(timePoints))
@@ -61,12 +68,24 @@ func (g *Generator) GenerateLeaseChart(timePoints []simulation.TimePoint, events
 		}
 
 		enhancedPoints[i] = EnhancedTimePoint{
-			ActiveLeases: tp.ActiveLeases,
-			WaitingJobs:  tp.WaitingJobs,
-			TimeoutJobs:  timeoutCount,
+			ActiveLeases:  tp.ActiveLeases,
+			CleanupLeases: cleanupLeases(tp),
+			WaitingJobs:   tp.WaitingJobs,
+			TimeoutJobs:   timeoutCount,
+			MaxLeases:     tp.MaxLeases,
 		}
 	}
 
+	// The lease slots go up to the highest capacity
+	leaseSlots := maxLeases
+	hasCleanups := false
+	capacityChanges := false
+	for _, ep := range enhancedPoints {
+		hasCleanups = hasCleanups || ep.CleanupLeases > 0
+		capacityChanges = capacityChanges || ep.MaxLeases != maxLeases
+		leaseSlots = max(leaseSlots, ep.MaxLeases)
+	}
+
 	// Find max waiting/timeout jobs to determine chart height
 	maxWaitingAndTimeout := 0
 	for _, ep := range enhancedPoints {
@@ -76,11 +95,11 @@ func (g *Generator) GenerateLeaseChart(timePoints []simulation.TimePoint, events
 		}
 	}
 
-	totalRows := maxLeases + maxWaitingAndTimeout
+	totalRows := leaseSlots + maxWaitingAndTimeout
 
 	// Build the chart from top to bottom
 	// First draw waiting/timeout rows (if any)
-	for row := totalRows; row > maxLeases; row-- {
+	for row := totalRows; row > leaseSlots; row-- {
 		// Y-axis label
 		sb.WriteString(fmt.Sprintf("%3d |", row))
 
@@ -92,7 +111,7 @@ func (g *Generator) GenerateLeaseChart(timePoints []simulation.TimePoint, events
 			}
 
 			ep := enhancedPoints[pointIndex]
-			waitingRow := row - maxLeases
+			waitingRow := row - leaseSlots
 
 			if waitingRow <= ep.TimeoutJobs {
 				// Show timeout
@@ -114,8 +133,8 @@ func (g *Generator) GenerateLeaseChart(timePoints []simulation.TimePoint, events
 		sb.WriteString("\n")
 	}
 
-	// Draw lease slots (maxLeases down to 1)
-	for leaseSlot := maxLeases; leaseSlot >= 1; leaseSlot-- {
+	// Draw lease slots (leaseSlots down to 1)
+	for leaseSlot := leaseSlots; leaseSlot >= 1; leaseSlot-- {
 		// Y-axis label
 		sb.WriteString(fmt.Sprintf("%3d |", leaseSlot))
 
@@ -131,6 +150,15 @@ func (g *Generator) GenerateLeaseChart(timePoints []simulation.TimePoint, events
 			if ep.ActiveLeases >= leaseSlot {
 				// This lease slot is active
 				sb.WriteString("█")
+			} else if ep.ActiveLeases+ep.CleanupLeases >= leaseSlot {
+				// This lease slot is dirty or being cleaned up
+				sb.WriteString("▒")
+			} else if leaseSlot > ep.MaxLeases {
+				// This lease slot is above the capacity of the time
+				sb.WriteString("x")
+			} else if leaseSlot > ep.MaxLeases-reservedLeases {
+				// This reserved lease slot is free
+				sb.WriteString(".")
 			} else {
 				// This lease slot is free
 				sb.WriteString(" ")
@@ -194,11 +222,22 @@ func (g *Generator) GenerateLeaseChart(timePoints []simulation.TimePoint, events
 	// Legend
 	sb.WriteString("\n")
 	sb.WriteString("Legend:\n")
-	sb.WriteString(fmt.Sprintf("  Lease slots (1-%d):\n", maxLeases))
+	sb.WriteString(fmt.Sprintf("  Lease slots (1-%d):\n", leaseSlots))
 	sb.WriteString("    █ - Active lease\n")
+	if hasCleanups {
+		sb.WriteString("    ▒ - Dirty lease, released and waiting for or under cleanup\n")
+	}
 	sb.WriteString("    (space) - Free lease\n")
+	if capacityChanges {
+		sb.WriteString(fmt.Sprintf("    x - Unavailable lease, above the capacity of the time (%d by default)\n", maxLeases))
+	}
+	if reservedLeases > 0 && capacityChanges {
+		sb.WriteString(fmt.Sprintf("    . - Free lease reserved for release controller jobs (top %d of the capacity)\n", reservedLeases))
+	} else if reservedLeases > 0 {
+		sb.WriteString(fmt.Sprintf("    . - Free lease reserved for release controller jobs (slots %d-%d)\n", maxLeases-reservedLeases+1, maxLeases))
+	}
 	if maxWaitingAndTimeout > 0 {
-		sb.WriteString(fmt.Sprintf("  Waiting/Timeout rows (>%d):\n", maxLeases))
+		sb.WriteString(fmt.Sprintf("  Waiting/Timeout rows (>%d):\n", leaseSlots))
 		sb.WriteString("    * - Job waiting for lease\n")
 		sb.WriteString("    ! - Job timed out waiting for lease\n")
 	}
@@ -209,6 +248,16 @@ func (g *Generator) GenerateLeaseChart(timePoints []simulation.TimePoint, events
 
 // GenerateEventSummary generates a summary of events
 func (g *Generator) GenerateEventSummary(events []simulation.Event) string {
+	stats := simulation.NewStatsSink()
+	for _, event := range events {
+		_ = stats.Emit(event)
+	}
+	return g.GenerateEventStats(stats)
+}
+
+// GenerateEventStats generates the summary of the events counted by a
+// statistics sink, which does not need the events to be kept
+func (g *Generator) GenerateEventStats(stats *simulation.StatsSink) string {
 	var sb strings.Builder
 
 	sb.WriteString("\n")
@@ -216,33 +265,50 @@ func (g *Generator) GenerateEventSummary(events []simulation.Event) string {
 	sb.WriteString(strings.Repeat("=", g.width))
 	sb.WriteString("\n\n")
 
-	// Group events by type
-	eventsByType := make(map[simulation.EventType]int)
-	for _, event := range events {
-		eventsByType[event.Type]++
-	}
+	eventsByType := stats.Counts
+	releaseControllerEvents := stats.ReleaseControllerCounts
 
-	sb.WriteString(fmt.Sprintf("Total Events: %d\n", len(events)))
+	sb.WriteString(fmt.Sprintf("Total Events: %d\n", stats.Total()))
 	sb.WriteString(fmt.Sprintf("  - Leases Acquired: %d\n", eventsByType[simulation.EventTypeLeaseAcquired]))
 	sb.WriteString(fmt.Sprintf("  - Leases Released: %d\n", eventsByType[simulation.EventTypeLeaseReleased]))
 	sb.WriteString(fmt.Sprintf("  - Jobs Waiting: %d\n", eventsByType[simulation.EventTypeJobWaiting]))
 	sb.WriteString(fmt.Sprintf("  - Job Timeouts: %d\n", eventsByType[simulation.EventTypeJobTimeout]))
 	sb.WriteString(fmt.Sprintf("  - Max Exceeded: %d\n", eventsByType[simulation.EventTypeMaxExceeded]))
+	if cleaned := eventsByType[simulation.EventTypeLeaseCleaned]; cleaned > 0 {
+		sb.WriteString(fmt.Sprintf("  - Leases Cleaned Up: %d\n", cleaned))
+	}
+	if changes := eventsByType[simulation.EventTypeCapacityChanged]; changes > 0 {
+		sb.WriteString(fmt.Sprintf("  - Capacity Changes: %d\n", changes))
+	}
+	if preempted := eventsByType[simulation.EventTypeJobPreempted]; preempted > 0 {
+		sb.WriteString(fmt.Sprintf("  - Jobs Preempted: %d\n", preempted))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("Release Controller Jobs:\n")
+	sb.WriteString(fmt.Sprintf("  - Leases Acquired: %d\n", releaseControllerEvents[simulation.EventTypeLeaseAcquired]))
+	sb.WriteString(fmt.Sprintf("  - Jobs Waiting: %d\n", releaseControllerEvents[simulation.EventTypeJobWaiting]))
+	sb.WriteString(fmt.Sprintf("  - Job Timeouts: %d\n", releaseControllerEvents[simulation.EventTypeJobTimeout]))
 	sb.WriteString("\n")
 
 	return sb.String()
 }
 
-// GenerateWarnings generates a list of warnings
-func (g *Generator) GenerateWarnings(warnings []simulation.Event) string {
+// GenerateWarnings generates a list of warnings out of total warnings,
+// which may be more than the warnings kept to list
+func (g *Generator) GenerateWarnings(warnings []simulation.Event, total int) string {
 	var sb strings.Builder
 
 	sb.WriteString("\n")
-	sb.WriteString("Warnings\n")
+	sb.WriteString("Warnings")
+	if total > len(warnings) {
+		sb.WriteString(fmt.Sprintf(" (showing first %d warnings)", len(warnings)))
+	}
+	sb.WriteString("\n")
 	sb.WriteString(strings.Repeat("=", g.width))
 	sb.WriteString("\n\n")
 
-	if len(warnings) == 0 {
+	if total == 0 {
 		sb.WriteString("No warnings!\n")
 		return sb.String()
 	}
@@ -252,8 +318,12 @@ func (g *Generator) GenerateWarnings(warnings []simulation.Event) string {
 		sb.WriteString(fmt.Sprintf("[%s] %s\n", timestamp, warning.Message))
 	}
 
+	if total > len(warnings) {
+		sb.WriteString(fmt.Sprintf("\n... and %d more warnings\n", total-len(warnings)))
+	}
+
 	sb.WriteString("\n")
-	sb.WriteString(fmt.Sprintf("Total Warnings: %d\n", len(warnings)))
+	sb.WriteString(fmt.Sprintf("Total Warnings: %d\n", total))
 	sb.WriteString("\n")
 
 	return sb.String()
@@ -293,6 +363,12 @@ func (g *Generator) GenerateDetailedTimeline(events []simulation.Event, limit in
 			typeIcon = "T"
 		case simulation.EventTypeMaxExceeded:
 			typeIcon = "!"
+		case simulation.EventTypeLeaseCleaned:
+			typeIcon = "~"
+		case simulation.EventTypeCapacityChanged:
+			typeIcon = "="
+		case simulation.EventTypeJobPreempted:
+			typeIcon = "X"
 		}
 
 		sb.WriteString(fmt.Sprintf("[%s] %s [%d] %s\n",
@@ -311,6 +387,90 @@ func (g *Generator) GenerateDetailedTimeline(events []simulation.Event, limit in
 	return sb.String()
 }
 
+// GenerateRecommendation generates the report of a lease capacity search
+func (g *Generator) GenerateRecommendation(rec *capacity.Recommendation) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString("Capacity Recommendation\n")
+	sb.WriteString(strings.Repeat("=", g.width))
+	sb.WriteString("\n\n")
+
+	sb.WriteString(fmt.Sprintf("Targets (worst case over %d seeds):\n", rec.Seeds))
+	sb.WriteString(fmt.Sprintf("  - Wait timeouts: <= %d\n", rec.Targets.MaxWaitTimeouts))
+	if rec.Targets.MaxP95Wait > 0 {
+		sb.WriteString(fmt.Sprintf("  - P95 wait: <= %s\n", FormatDuration(rec.Targets.MaxP95Wait)))
+	}
+	if rec.Targets.MinUtilization > 0 {
+		sb.WriteString(fmt.Sprintf("  - Utilisation: >= %.1f%%\n", rec.Targets.MinUtilization*100))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString(fmt.Sprintf("%6s  %13s  %8s  %8s  %11s  %s\n", "Leases", "Wait timeouts", "P95 wait", "Max wait", "Utilisation", "Result"))
+	for _, c := range rec.Candidates {
+		result := "ok"
+		if !c.MeetsTargets {
+			result = strings.Join(c.Failures, ", ")
+		}
+		marker := " "
+		if c.MaxActiveLeases == rec.Recommended {
+			marker = ">"
+		}
+		sb.WriteString(fmt.Sprintf("%s%5d  %13d  %8s  %8s  %10.1f%%  %s\n",
+			marker,
+			c.MaxActiveLeases,
+			c.WaitTimeouts,
+			FormatDuration(c.P95Wait),
+			FormatDuration(c.MaxWait),
+			c.Utilization*100,
+			result))
+	}
+	sb.WriteString("\n")
+
+	if rec.Recommended > 0 {
+		sb.WriteString(fmt.Sprintf("Recommended maxActiveLeases: %d\n", rec.Recommended))
+	} else {
+		sb.WriteString("No lease count in the searched range meets the targets\n")
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// GenerateOptimization generates the report of a schedule search
+func (g *Generator) GenerateOptimization(result *optimize.Result, seeds int) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString("Schedule Optimization\n")
+	sb.WriteString(strings.Repeat("=", g.width))
+	sb.WriteString("\n\n")
+
+	sb.WriteString(fmt.Sprintf("Totals over %d seeds:  %10s  %10s\n", seeds, "Before", "After"))
+	sb.WriteString(fmt.Sprintf("  - Wait timeouts:     %10d  %10d\n", result.Before.WaitTimeouts, result.After.WaitTimeouts))
+	sb.WriteString(fmt.Sprintf("  - Total wait:        %10s  %10s\n", FormatDuration(result.Before.TotalWait), FormatDuration(result.After.TotalWait)))
+	sb.WriteString(fmt.Sprintf("  - Peak demand:       %10d  %10d\n", result.Before.PeakDemand, result.After.PeakDemand))
+	sb.WriteString("\n")
+
+	if len(result.Changes) == 0 {
+		sb.WriteString("No better schedule found\n")
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf("Proposed changes (%d jobs):\n", len(result.Changes)))
+	for _, change := range result.Changes {
+		sb.WriteString(fmt.Sprintf("  - %s: %q -> %q\n", change.JobName, change.OldSchedule, change.NewSchedule))
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// cleanupLeases returns the number of dirty and cleaning leases of a time point
+func cleanupLeases(tp simulation.TimePoint) int {
+	return tp.DirtyLeases + tp.CleaningLeases
+}
+
 // FormatDuration formats a duration in a human-readable way
 func FormatDuration(d time.Duration) string {
 	if d < time.Minute {
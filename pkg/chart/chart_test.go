@@ -0,0 +1,296 @@
+package chart
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/calibrate"
+	"github.com/sherine-k/leases/pkg/capacity"
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/optimize"
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+var update = flag.Bool("update", false, "Update the golden files in testdata")
+
+// testStart is a Monday, so that the outputs do not depend on the current date
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// assertGolden compares an output with testdata/<name>.golden, rewriting the
+// file instead when the tests run with -update
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run the tests with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s (run the tests with -update to accept it)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// testConfig returns a configuration with contention, timeouts and release controller jobs
+func testConfig(maxLeases int) *config.Config {
+	job := func(name, version, schedule string, duration time.Duration) config.Job {
+		return config.Job{
+			Name:         name,
+			Version:      version,
+			Duration:     config.Duration{Duration: duration},
+			TriggerType:  config.TriggerTypeCron,
+			CronSchedule: schedule,
+		}
+	}
+	release := func(name, version string, duration time.Duration) config.Job {
+		return config.Job{
+			Name:                name,
+			Version:             version,
+			Duration:            config.Duration{Duration: duration},
+			TriggerType:         config.TriggerTypeReleaseController,
+			IsReleaseController: true,
+		}
+	}
+
+	return &config.Config{
+		MaxActiveLeases:    maxLeases,
+		ReservedLeases:     1,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: 2 * time.Hour},
+		SimulationDuration: config.Duration{Duration: 2 * 24 * time.Hour},
+		Jobs: []config.Job{
+			job("e2e-aws-4.19", "4.19", "0 1 * * *", 3*time.Hour),
+			job("e2e-gcp-4.19", "4.19", "0 1 * * *", 2*time.Hour),
+			job("upgrade-4.19", "4.19", "30 1 * * *", 5*time.Hour),
+			job("e2e-aws-4.20", "4.20", "0 */8 * * *", 2*time.Hour),
+			release("rc-e2e-4.20", "4.20", 3*time.Hour),
+			release("rc-upgrade-4.20", "4.20", 90*time.Minute),
+		},
+	}
+}
+
+// runTestSimulation runs the test configuration with a fixed start time and seed
+func runTestSimulation(t *testing.T, maxLeases int) *simulation.Result {
+	t.Helper()
+
+	sim := simulation.NewSimulator(testConfig(maxLeases), simulation.WithStartTime(testStart), simulation.WithSeed(1))
+	result, err := sim.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	return result
+}
+
+func TestGenerateLeaseChart(t *testing.T) {
+	result := runTestSimulation(t, 3)
+	got := NewGenerator().GenerateLeaseChart(result.TimePoints, result.Events, 3, 1)
+	assertGolden(t, "lease_chart", got)
+}
+
+func TestGenerateLeaseChartCleanup(t *testing.T) {
+	cfg := testConfig(3)
+	cfg.Pools = []config.Pool{{Name: config.DefaultPool, CleanupDelay: config.Duration{Duration: time.Hour}}}
+	result, err := simulation.NewSimulator(cfg, simulation.WithStartTime(testStart), simulation.WithSeed(1)).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	g := NewGenerator()
+	assertGolden(t, "lease_chart_cleanup", g.GenerateLeaseChart(result.TimePoints, result.Events, 3, 1))
+	assertGolden(t, "event_summary_cleanup", g.GenerateEventSummary(result.Events))
+}
+
+func TestGenerateLeaseChartCapacity(t *testing.T) {
+	cfg := testConfig(3)
+	cfg.CapacitySchedule = []config.CapacityChange{
+		{Name: "maintenance", MaxActiveLeases: 1, CronSchedule: "0 2 * * *", Duration: config.Duration{Duration: 4 * time.Hour}, Policy: config.CapacityPolicyPreempt},
+		{Name: "growth", MaxActiveLeases: 4, At: testStart.Add(36 * time.Hour)},
+	}
+	result, err := simulation.NewSimulator(cfg, simulation.WithStartTime(testStart), simulation.WithSeed(1)).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	g := NewGenerator()
+	assertGolden(t, "lease_chart_capacity", g.GenerateLeaseChart(result.TimePoints, result.Events, 3, 1))
+	assertGolden(t, "event_summary_capacity", g.GenerateEventSummary(result.Events))
+}
+
+func TestGenerateLeasePhases(t *testing.T) {
+	cfg := testConfig(3)
+	cfg.Pools = []config.Pool{{Name: "aws-quota", SetupDuration: config.Duration{Duration: 45 * time.Minute}, TeardownDuration: config.Duration{Duration: 15 * time.Minute}}}
+	cfg.Jobs[0].Pool = "aws-quota"
+	cfg.Jobs[4].Pool = "aws-quota"
+	cfg.Jobs[1].SetupDuration = config.Duration{Duration: 30 * time.Minute}
+	result, err := simulation.NewSimulator(cfg, simulation.WithStartTime(testStart), simulation.WithSeed(1)).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	assertGolden(t, "lease_phases", NewGenerator().GenerateLeasePhases(result.Instances, result.Start, result.End))
+}
+
+func TestGeneratePreemptionReport(t *testing.T) {
+	cfg := testConfig(3)
+	cfg.Preemption = config.Preemption{Enabled: true, OnPreempt: config.PreemptActionRetry}
+	cfg.Jobs[4].Priority = 10
+	cfg.Jobs[5].Priority = 10
+	cfg.Jobs[2].Priority = -1
+	cfg.Jobs[3].Priority = -1
+	result, err := simulation.NewSimulator(cfg, simulation.WithStartTime(testStart), simulation.WithSeed(1)).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	assertGolden(t, "preemption_report", NewGenerator().GeneratePreemptionReport(result.Instances, result.Metrics))
+}
+
+func TestGenerateEventSummary(t *testing.T) {
+	result := runTestSimulation(t, 3)
+	assertGolden(t, "event_summary", NewGenerator().GenerateEventSummary(result.Events))
+}
+
+func TestGenerateWarnings(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxLeases int
+		limit     int
+	}{
+		{name: "warnings", maxLeases: 3},
+		{name: "warnings_limited", maxLeases: 3, limit: 1},
+		{name: "warnings_none", maxLeases: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runTestSimulation(t, tt.maxLeases)
+			warnings := result.Warnings
+			if tt.limit > 0 {
+				warnings = warnings[:tt.limit]
+			}
+			assertGolden(t, tt.name, NewGenerator().GenerateWarnings(warnings, result.WarningCount))
+		})
+	}
+}
+
+func TestGenerateDetailedTimeline(t *testing.T) {
+	result := runTestSimulation(t, 3)
+	assertGolden(t, "timeline", NewGenerator().GenerateDetailedTimeline(result.Events, 20))
+}
+
+func TestGenerateInstanceReport(t *testing.T) {
+	result := runTestSimulation(t, 3)
+	assertGolden(t, "instances", NewGenerator().GenerateInstanceReport(result.Instances, 30*time.Minute))
+}
+
+func TestGenerateRecommendation(t *testing.T) {
+	rec := &capacity.Recommendation{
+		Targets: capacity.Targets{MaxWaitTimeouts: 0, MaxP95Wait: 30 * time.Minute, MinUtilization: 0.2},
+		Seeds:   3,
+		Candidates: []capacity.Candidate{
+			{MaxActiveLeases: 2, WaitTimeouts: 4, P95Wait: 2 * time.Hour, MaxWait: 2 * time.Hour, Utilization: 0.61, Failures: []string{"4 wait timeouts", "P95 wait 2h0m"}},
+			{MaxActiveLeases: 3, WaitTimeouts: 0, P95Wait: 25 * time.Minute, MaxWait: 90 * time.Minute, Utilization: 0.42, MeetsTargets: true},
+			{MaxActiveLeases: 4, WaitTimeouts: 0, P95Wait: 0, MaxWait: 30 * time.Minute, Utilization: 0.31, MeetsTargets: true},
+		},
+		Recommended: 3,
+	}
+	assertGolden(t, "recommendation", NewGenerator().GenerateRecommendation(rec))
+}
+
+func TestGenerateOptimization(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *optimize.Result
+	}{
+		{
+			name: "optimization",
+			result: &optimize.Result{
+				Before: optimize.Score{WaitTimeouts: 3, TotalWait: 7 * time.Hour, PeakDemand: 6},
+				After:  optimize.Score{WaitTimeouts: 0, TotalWait: 45 * time.Minute, PeakDemand: 4},
+				Changes: []optimize.Change{
+					{JobName: "e2e-gcp-4.19", OldSchedule: "0 1 * * *", NewSchedule: "0 4 * * *"},
+					{JobName: "upgrade-4.19", OldSchedule: "30 1 * * *", NewSchedule: "30 13 * * *"},
+				},
+			},
+		},
+		{
+			name: "optimization_unchanged",
+			result: &optimize.Result{
+				Before: optimize.Score{PeakDemand: 2},
+				After:  optimize.Score{PeakDemand: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertGolden(t, tt.name, NewGenerator().GenerateOptimization(tt.result, 3))
+		})
+	}
+}
+
+func TestGenerateSVGChart(t *testing.T) {
+	result := runTestSimulation(t, 3)
+	assertGolden(t, "chart_svg", NewGenerator().GenerateSVGChart(result.TimePoints, 3, 1))
+}
+
+func TestGenerateHTMLReport(t *testing.T) {
+	result := runTestSimulation(t, 3)
+	got, err := NewGenerator().GenerateHTMLReport(Report{Title: "Test <report>", MaxLeases: 3, ReservedLeases: 1, Result: result})
+	if err != nil {
+		t.Fatalf("GenerateHTMLReport() error = %v", err)
+	}
+	assertGolden(t, "report_html", got)
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		want     string
+	}{
+		{duration: 0, want: "0s"},
+		{duration: 45 * time.Second, want: "45s"},
+		{duration: 30 * time.Minute, want: "30m"},
+		{duration: 2 * time.Hour, want: "2h0m"},
+		{duration: 26*time.Hour + 15*time.Minute, want: "26h15m"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatDuration(tt.duration); got != tt.want {
+			t.Errorf("FormatDuration(%s) = %q, want %q", tt.duration, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateCalibration(t *testing.T) {
+	report := &calibrate.Report{
+		Start:     testStart,
+		End:       testStart.Add(7 * 24 * time.Hour),
+		Samples:   2017,
+		Seeds:     5,
+		MaxLeases: 3,
+		RMSE:      0.84,
+		Bias:      -0.31,
+		Real:      calibrate.Usage{PeakLeases: 3, TimeAtCapacity: 9*time.Hour + 20*time.Minute, LeaseHours: 262.5},
+		Simulated: calibrate.Usage{PeakLeases: 2.8, TimeAtCapacity: 6*time.Hour + 5*time.Minute, LeaseHours: 210.4},
+		Suggestions: []calibrate.Suggestion{
+			{Message: "Scale the job durations by 1.25 to match the real lease-hours (262.5 real, 210.4 simulated)"},
+			{Message: "Or trigger releases 1.61 times as often, every 2h29m to 4h58m", Set: "releaseInterval={min: 2h29m, max: 4h58m}"},
+		},
+	}
+	assertGolden(t, "calibration", NewGenerator().GenerateCalibration(report))
+}
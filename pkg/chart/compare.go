@@ -0,0 +1,306 @@
+package chart
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// Scenario is the outcome of one simulated configuration in a comparison
+type Scenario struct {
+	Name       string
+	MaxLeases  int
+	Metrics    simulation.Metrics
+	TimePoints []simulation.TimePoint
+}
+
+// metricUnit is how a compared metric is formatted
+type metricUnit int
+
+const (
+	unitCount metricUnit = iota
+	unitDuration
+	unitHours
+	unitPercent
+)
+
+// comparisonRow describes a metric compared across scenarios
+type comparisonRow struct {
+	label string
+	value func(s Scenario) float64
+	unit  metricUnit
+	// better is -1 when lower values win, 1 when higher values win and 0
+	// when the metric is informational only
+	better int
+}
+
+var comparisonRows = []comparisonRow{
+	{"Max active leases", func(s Scenario) float64 { return float64(s.MaxLeases) }, unitCount, 0},
+	{"Job instances", func(s Scenario) float64 { return float64(s.Metrics.JobInstances) }, unitCount, 0},
+	{"Wait timeouts", func(s Scenario) float64 { return float64(s.Metrics.WaitTimeouts) }, unitCount, -1},
+	{"RC wait timeouts", func(s Scenario) float64 { return float64(s.Metrics.ReleaseControllerWaitTimeouts) }, unitCount, -1},
+	{"Exec timeouts", func(s Scenario) float64 { return float64(s.Metrics.ExecTimeouts) }, unitCount, -1},
+	{"Jobs waited", func(s Scenario) float64 { return float64(s.Metrics.WaitedJobs) }, unitCount, -1},
+	{"RC jobs waited", func(s Scenario) float64 { return float64(s.Metrics.ReleaseControllerWaitedJobs) }, unitCount, -1},
+	{"Total wait", func(s Scenario) float64 { return float64(s.Metrics.TotalWait) }, unitDuration, -1},
+	{"P95 wait", func(s Scenario) float64 { return float64(s.Metrics.P95Wait) }, unitDuration, -1},
+	{"Max wait", func(s Scenario) float64 { return float64(s.Metrics.MaxWait) }, unitDuration, -1},
+	{"Peak active leases", func(s Scenario) float64 { return float64(s.Metrics.PeakActiveLeases) }, unitCount, 0},
+	{"Peak demand", func(s Scenario) float64 { return float64(s.Metrics.PeakDemand) }, unitCount, -1},
+	{"Lease hours", func(s Scenario) float64 { return s.Metrics.LeaseHours }, unitHours, 0},
+	{"Utilisation", func(s Scenario) float64 { return s.Metrics.Utilization }, unitPercent, 1},
+	{"Time at capacity", func(s Scenario) float64 { return float64(s.Metrics.TimeAtCapacity) }, unitDuration, -1},
+}
+
+// formatMetric formats the value of a metric
+func formatMetric(unit metricUnit, value float64) string {
+	switch unit {
+	case unitDuration:
+		return FormatDuration(time.Duration(value))
+	case unitHours:
+		return fmt.Sprintf("%.1f", value)
+	case unitPercent:
+		return fmt.Sprintf("%.1f%%", value*100)
+	}
+	return fmt.Sprintf("%d", int(value))
+}
+
+// scenarioSymbols are the plot symbols of the scenarios in overlaid charts
+const scenarioSymbols = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// GenerateComparisonTable generates a side-by-side table of the key metrics
+// of several scenarios. The winning value of each metric is marked with '*'.
+func (g *Generator) GenerateComparisonTable(scenarios []Scenario) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString("Scenario Comparison\n")
+	sb.WriteString(strings.Repeat("=", g.width))
+	sb.WriteString("\n\n")
+
+	columnWidth := 10
+	for _, scenario := range scenarios {
+		if len(scenario.Name)+1 > columnWidth {
+			columnWidth = len(scenario.Name) + 1
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("%-20s", "Metric"))
+	for _, scenario := range scenarios {
+		sb.WriteString(fmt.Sprintf("  %*s", columnWidth, scenario.Name))
+	}
+	sb.WriteString("\n")
+
+	for _, row := range comparisonRows {
+		sb.WriteString(fmt.Sprintf("%-20s", row.label))
+
+		winners := comparisonWinners(scenarios, row)
+		for i, scenario := range scenarios {
+			text := formatMetric(row.unit, row.value(scenario))
+			if winners[i] {
+				text += "*"
+			} else {
+				text += " "
+			}
+			sb.WriteString(fmt.Sprintf("  %*s", columnWidth, text))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString("* - Best value for the metric\n")
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// GenerateMetricsDiff generates a table of the metrics that changed between
+// two runs, telling whether each change is an improvement
+func (g *Generator) GenerateMetricsDiff(previous, current Scenario) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString("Changes Since the Previous Run\n")
+	sb.WriteString(strings.Repeat("=", g.width))
+	sb.WriteString("\n\n")
+
+	changed := 0
+	for _, row := range comparisonRows {
+		before, after := row.value(previous), row.value(current)
+		if before == after {
+			continue
+		}
+		if changed == 0 {
+			sb.WriteString(fmt.Sprintf("%-20s  %10s  %10s  %10s\n", "Metric", previous.Name, current.Name, "Change"))
+		}
+		changed++
+
+		verdict := ""
+		switch {
+		case row.better == 0:
+		case float64(row.better)*(after-before) > 0:
+			verdict = "  better"
+		default:
+			verdict = "  worse"
+		}
+		sb.WriteString(fmt.Sprintf("%-20s  %10s  %10s  %10s%s\n", row.label,
+			formatMetric(row.unit, before), formatMetric(row.unit, after), formatMetricChange(row.unit, after-before), verdict))
+	}
+	if changed == 0 {
+		sb.WriteString("No metric changed\n")
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// formatMetricChange formats the signed difference between two metric values
+func formatMetricChange(unit metricUnit, change float64) string {
+	sign := "+"
+	if change < 0 {
+		sign = "-"
+		change = -change
+	}
+	if unit == unitPercent {
+		return fmt.Sprintf("%s%.1fpt", sign, change*100)
+	}
+	return sign + formatMetric(unit, change)
+}
+
+// comparisonWinners reports which scenarios have the best value of a metric.
+// No scenario wins an informational metric or a metric on which all tie.
+func comparisonWinners(scenarios []Scenario, row comparisonRow) []bool {
+	winners := make([]bool, len(scenarios))
+	if row.better == 0 || len(scenarios) < 2 {
+		return winners
+	}
+
+	best := row.value(scenarios[0])
+	allEqual := true
+	for _, scenario := range scenarios[1:] {
+		value := row.value(scenario)
+		if value != best {
+			allEqual = false
+		}
+		if float64(row.better)*(value-best) > 0 {
+			best = value
+		}
+	}
+	if allEqual {
+		return winners
+	}
+
+	for i, scenario := range scenarios {
+		winners[i] = row.value(scenario) == best
+	}
+	return winners
+}
+
+// GenerateComparisonChart generates an ASCII chart overlaying the active
+// leases of several scenarios. Each scenario is drawn with its own letter
+// at its lease level; '#' marks columns where scenarios overlap.
+func (g *Generator) GenerateComparisonChart(scenarios []Scenario) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString("Lease Usage Comparison\n")
+	sb.WriteString(strings.Repeat("=", g.width))
+	sb.WriteString("\n\n")
+
+	// The longest scenario defines the time axis
+	var axis []simulation.TimePoint
+	maxRows := 0
+	for _, scenario := range scenarios {
+		if len(scenario.TimePoints) > len(axis) {
+			axis = scenario.TimePoints
+		}
+		if scenario.MaxLeases > maxRows {
+			maxRows = scenario.MaxLeases
+		}
+		for _, tp := range scenario.TimePoints {
+			if tp.ActiveLeases > maxRows {
+				maxRows = tp.ActiveLeases
+			}
+		}
+	}
+
+	if len(axis) == 0 {
+		sb.WriteString("No data to display\n")
+		return sb.String()
+	}
+
+	plotWidth := g.width - 6
+	columns := len(axis)
+	if columns > plotWidth {
+		columns = plotWidth
+	}
+
+	// Lease level of every scenario in every column, -1 past its end; the
+	// columns span the whole time axis
+	levels := make([][]int, len(scenarios))
+	for i, scenario := range scenarios {
+		levels[i] = make([]int, columns)
+		for x := 0; x < columns; x++ {
+			pointIndex := 0
+			if columns > 1 {
+				pointIndex = int(float64(x) / float64(columns-1) * float64(len(axis)-1))
+			}
+			if pointIndex < len(scenario.TimePoints) {
+				levels[i][x] = scenario.TimePoints[pointIndex].ActiveLeases
+			} else {
+				levels[i][x] = -1
+			}
+		}
+	}
+
+	for row := maxRows; row >= 1; row-- {
+		sb.WriteString(fmt.Sprintf("%3d |", row))
+		for x := 0; x < columns; x++ {
+			cell := ' '
+			for i := range scenarios {
+				if levels[i][x] != row {
+					continue
+				}
+				if cell == ' ' {
+					cell = rune(scenarioSymbols[i%len(scenarioSymbols)])
+				} else {
+					cell = '#'
+				}
+			}
+			sb.WriteRune(cell)
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("    +")
+	sb.WriteString(strings.Repeat("-", plotWidth))
+	sb.WriteString("\n")
+
+	totalDuration := axis[len(axis)-1].Time.Sub(axis[0].Time)
+	labelLine := []rune(strings.Repeat(" ", plotWidth))
+	for day := 0; time.Duration(day)*24*time.Hour <= totalDuration; day++ {
+		column := 0
+		if totalDuration > 0 {
+			column = int(float64(time.Duration(day)*24*time.Hour) / float64(totalDuration) * float64(columns-1))
+		}
+		marker := fmt.Sprintf("%dd", day)
+		if column+len(marker) <= plotWidth {
+			copy(labelLine[column:], []rune(marker))
+		}
+	}
+	sb.WriteString("    ")
+	sb.WriteString(string(labelLine))
+	sb.WriteString("\n")
+
+	sb.WriteString("\n")
+	sb.WriteString("Legend:\n")
+	for i, scenario := range scenarios {
+		sb.WriteString(fmt.Sprintf("    %c - %s (max %d leases)\n", scenarioSymbols[i%len(scenarioSymbols)], scenario.Name, scenario.MaxLeases))
+	}
+	sb.WriteString("    # - Several scenarios at the same level\n")
+	sb.WriteString("\n")
+
+	return sb.String()
+}
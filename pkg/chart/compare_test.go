@@ -0,0 +1,102 @@
+package chart
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// testScenarios returns the test configuration simulated with two lease counts
+func testScenarios(t *testing.T) []Scenario {
+	scenarios := []Scenario{}
+	for _, maxLeases := range []int{3, 5} {
+		result := runTestSimulation(t, maxLeases)
+		scenarios = append(scenarios, Scenario{
+			Name:       fmt.Sprintf("config-%d", maxLeases),
+			MaxLeases:  maxLeases,
+			Metrics:    result.Metrics,
+			TimePoints: result.TimePoints,
+		})
+	}
+	return scenarios
+}
+
+func TestGenerateComparisonTable(t *testing.T) {
+	assertGolden(t, "comparison_table", NewGenerator().GenerateComparisonTable(testScenarios(t)))
+}
+
+func TestGenerateComparisonChart(t *testing.T) {
+	assertGolden(t, "comparison_chart", NewGenerator().GenerateComparisonChart(testScenarios(t)))
+
+	// Fewer time points than columns are drawn one per column
+	scenarios := testScenarios(t)
+	for i := range scenarios {
+		scenarios[i].TimePoints = scenarios[i].TimePoints[:12]
+	}
+	assertGolden(t, "comparison_chart_short", NewGenerator().GenerateComparisonChart(scenarios))
+}
+
+func TestGenerateMetricsDiff(t *testing.T) {
+	scenarios := testScenarios(t)
+	assertGolden(t, "metrics_diff", NewGenerator().GenerateMetricsDiff(scenarios[0], scenarios[1]))
+	assertGolden(t, "metrics_diff_unchanged", NewGenerator().GenerateMetricsDiff(scenarios[0], scenarios[0]))
+}
+
+func TestComparisonWinners(t *testing.T) {
+	scenario := func(waitTimeouts int, utilization float64) Scenario {
+		return Scenario{MaxLeases: 3, Metrics: simulation.Metrics{WaitTimeouts: waitTimeouts, Utilization: utilization}}
+	}
+	row := func(label string) comparisonRow {
+		for _, row := range comparisonRows {
+			if row.label == label {
+				return row
+			}
+		}
+		t.Fatalf("no comparison row %s", label)
+		return comparisonRow{}
+	}
+
+	tests := []struct {
+		name      string
+		row       string
+		scenarios []Scenario
+		want      []bool
+	}{
+		{name: "lower wins", row: "Wait timeouts", scenarios: []Scenario{scenario(2, 0), scenario(0, 0), scenario(0, 0)}, want: []bool{false, true, true}},
+		{name: "higher wins", row: "Utilisation", scenarios: []Scenario{scenario(0, 0.4), scenario(0, 0.6)}, want: []bool{false, true}},
+		{name: "all tie", row: "Wait timeouts", scenarios: []Scenario{scenario(1, 0), scenario(1, 0)}, want: []bool{false, false}},
+		{name: "single scenario", row: "Wait timeouts", scenarios: []Scenario{scenario(1, 0)}, want: []bool{false}},
+		{name: "informational", row: "Max active leases", scenarios: []Scenario{{MaxLeases: 3}, {MaxLeases: 5}}, want: []bool{false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := comparisonWinners(tt.scenarios, row(tt.row)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("comparisonWinners() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatMetricChange(t *testing.T) {
+	tests := []struct {
+		unit   metricUnit
+		change float64
+		want   string
+	}{
+		{unit: unitCount, change: 2, want: "+2"},
+		{unit: unitCount, change: -3, want: "-3"},
+		{unit: unitDuration, change: float64(-90 * time.Minute), want: "-1h30m"},
+		{unit: unitHours, change: 1.5, want: "+1.5"},
+		{unit: unitPercent, change: 0.125, want: "+12.5pt"},
+	}
+
+	for _, tt := range tests {
+		if got := formatMetricChange(tt.unit, tt.change); got != tt.want {
+			t.Errorf("formatMetricChange(%v, %v) = %q, want %q", tt.unit, tt.change, got, tt.want)
+		}
+	}
+}
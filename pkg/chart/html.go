@@ -0,0 +1,81 @@
+package chart
+
+import (
+	"html/template"
+	"strings"
+
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// Report is the content of an HTML report of a simulation
+type Report struct {
+	Title          string
+	MaxLeases      int
+	ReservedLeases int
+	Result         *simulation.Result
+}
+
+// reportMetric is a row of the metrics table of an HTML report
+type reportMetric struct {
+	Label string
+	Value string
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { padding: 0.25em 1em; border-bottom: 1px solid #e0e0e0; text-align: left; }
+td.value { text-align: right; }
+.warning { color: #d0021b; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Result.Start.Format "Mon 02 Jan 2006 15:04"}} to {{.Result.End.Format "Mon 02 Jan 2006 15:04"}}</p>
+{{.Chart}}
+<h2>Metrics</h2>
+<table>
+{{range .Metrics}}<tr><td>{{.Label}}</td><td class="value">{{.Value}}</td></tr>
+{{end}}</table>
+<h2>Warnings ({{len .Warnings}})</h2>
+{{if .Warnings}}<table>
+<tr><th>Time</th><th>Warning</th></tr>
+{{range .Warnings}}<tr class="warning"><td>{{.Time.Format "Mon 15:04"}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>
+{{else}}<p>No warnings: all jobs acquired a lease without waiting.</p>
+{{end}}</body>
+</html>
+`))
+
+// GenerateHTMLReport generates a standalone HTML page with the SVG chart,
+// the metrics and the warnings of a simulation
+func (g *Generator) GenerateHTMLReport(report Report) (string, error) {
+	scenario := Scenario{MaxLeases: report.MaxLeases, Metrics: report.Result.Metrics}
+	metrics := make([]reportMetric, 0, len(comparisonRows))
+	for _, row := range comparisonRows {
+		metrics = append(metrics, reportMetric{Label: row.label, Value: formatMetric(row.unit, row.value(scenario))})
+	}
+
+	data := struct {
+		Report
+		Chart    template.HTML
+		Metrics  []reportMetric
+		Warnings []simulation.Event
+	}{
+		Report:   report,
+		Chart:    template.HTML(g.GenerateSVGChart(report.Result.TimePoints, report.MaxLeases, report.ReservedLeases)),
+		Metrics:  metrics,
+		Warnings: report.Result.Warnings,
+	}
+
+	var sb strings.Builder
+	if err := reportTemplate.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
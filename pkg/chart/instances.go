@@ -0,0 +1,74 @@
+package chart
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+)
+
+// GenerateInstanceReport lists every job run with when it was scheduled, when
+// it acquired a lease and finished, how late it started and its outcome.
+// Runs that slipped less than minSlip are left out, except those that never started.
+func (g *Generator) GenerateInstanceReport(instances []*config.JobInstance, minSlip time.Duration) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString("Job Runs")
+	if minSlip > 0 {
+		sb.WriteString(fmt.Sprintf(" (slipped %s or more)", FormatDuration(minSlip)))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(strings.Repeat("=", g.width))
+	sb.WriteString("\n\n")
+
+	sb.WriteString(fmt.Sprintf("%-10s  %-10s  %-10s  %7s  %-12s  %s\n", "Scheduled", "Acquired", "Finished", "Slip", "Outcome", "Job"))
+
+	listed, delayed, neverStarted := 0, 0, 0
+	var maxSlip time.Duration
+	for _, instance := range instances {
+		slip, started := instance.ScheduleSlip()
+		if !started {
+			neverStarted++
+		} else if slip > 0 {
+			delayed++
+		}
+		if slip > maxSlip {
+			maxSlip = slip
+		}
+		if started && slip < minSlip {
+			continue
+		}
+		listed++
+
+		acquired, slipText := "-", "-"
+		if started {
+			acquired = instance.LeaseAcquiredTime.Format("Mon 15:04")
+			slipText = FormatDuration(slip)
+		}
+		finished := "-"
+		if !instance.FinishedTime.IsZero() {
+			finished = instance.FinishedTime.Format("Mon 15:04")
+		}
+
+		sb.WriteString(fmt.Sprintf("%-10s  %-10s  %-10s  %7s  %-12s  %s\n",
+			instance.ScheduledTime.Format("Mon 15:04"),
+			acquired,
+			finished,
+			slipText,
+			instance.Outcome,
+			instance.Job.Name))
+	}
+
+	if listed == 0 {
+		sb.WriteString("No job runs to list.\n")
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("Runs: %d, started late: %d, never started: %d, max slip: %s\n",
+		len(instances), delayed, neverStarted, FormatDuration(maxSlip)))
+	sb.WriteString("\n")
+
+	return sb.String()
+}
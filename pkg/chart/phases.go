@@ -0,0 +1,67 @@
+package chart
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// GenerateLeasePhases generates the breakdown of the lease-hours of the
+// simulation window by pool and by phase of the job runs: setup, tests and
+// teardown
+func (g *Generator) GenerateLeasePhases(instances []*config.JobInstance, start, end time.Time) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString("Lease Time by Phase\n")
+	sb.WriteString(strings.Repeat("=", g.width))
+	sb.WriteString("\n\n")
+
+	type phases struct{ setup, test, teardown time.Duration }
+	byPool := make(map[string]*phases)
+	total := &phases{}
+	for _, instance := range instances {
+		setup, test, teardown := simulation.LeasePhases(instance, start, end)
+		pool, ok := byPool[instance.Job.PoolName()]
+		if !ok {
+			pool = &phases{}
+			byPool[instance.Job.PoolName()] = pool
+		}
+		for _, p := range []*phases{pool, total} {
+			p.setup += setup
+			p.test += test
+			p.teardown += teardown
+		}
+	}
+
+	pools := make([]string, 0, len(byPool))
+	for pool := range byPool {
+		pools = append(pools, pool)
+	}
+	sort.Strings(pools)
+
+	sb.WriteString(fmt.Sprintf("%-20s  %10s  %10s  %10s  %9s\n", "Pool", "Setup", "Tests", "Teardown", "Overhead"))
+	row := func(name string, p *phases) {
+		overhead := 0.0
+		if held := p.setup + p.test + p.teardown; held > 0 {
+			overhead = float64(p.setup+p.teardown) / float64(held)
+		}
+		sb.WriteString(fmt.Sprintf("%-20s  %9.1fh  %9.1fh  %9.1fh  %8.1f%%\n",
+			name, p.setup.Hours(), p.test.Hours(), p.teardown.Hours(), overhead*100))
+	}
+	for _, pool := range pools {
+		row(pool, byPool[pool])
+	}
+	if len(pools) > 1 {
+		row("Total", total)
+	}
+	sb.WriteString("\n")
+	sb.WriteString("Overhead is the share of the lease time spent setting up and tearing down.\n")
+	sb.WriteString("\n")
+
+	return sb.String()
+}
@@ -0,0 +1,85 @@
+package chart
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// GeneratePreemptionReport generates the preemptions of a simulation, the
+// lease-hours they wasted and the waits of the jobs by priority, to weigh
+// what preempting saves the high priority jobs against what it costs
+func (g *Generator) GeneratePreemptionReport(instances []*config.JobInstance, m simulation.Metrics) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString("Preemptions\n")
+	sb.WriteString(strings.Repeat("=", g.width))
+	sb.WriteString("\n\n")
+
+	wasted := 0.0
+	if m.LeaseHours > 0 {
+		wasted = m.PreemptedLeaseHours / m.LeaseHours
+	}
+	sb.WriteString(fmt.Sprintf("Jobs Preempted: %d\n", m.Preemptions))
+	sb.WriteString(fmt.Sprintf("  - By Higher Priority Jobs: %d\n", m.PriorityPreemptions))
+	sb.WriteString(fmt.Sprintf("  - By Capacity Changes: %d\n", m.Preemptions-m.PriorityPreemptions))
+	sb.WriteString(fmt.Sprintf("  - Retried: %d\n", m.Retries))
+	sb.WriteString(fmt.Sprintf("Wasted Lease-Hours: %.1fh (%.1f%% of %.1fh)\n", m.PreemptedLeaseHours, wasted*100, m.LeaseHours))
+	sb.WriteString("\n")
+
+	type priorityStats struct {
+		runs, preempted, preempting, waited, waitTimeouts int
+		maxWait                                           time.Duration
+	}
+	byPriority := make(map[int]*priorityStats)
+	stats := func(priority int) *priorityStats {
+		s, ok := byPriority[priority]
+		if !ok {
+			s = &priorityStats{}
+			byPriority[priority] = s
+		}
+		return s
+	}
+	for _, instance := range instances {
+		s := stats(instance.Job.Priority)
+		s.runs++
+		switch instance.State {
+		case config.JobStatePreempted:
+			s.preempted++
+			if instance.PreemptedBy != nil {
+				stats(instance.PreemptedBy.Job.Priority).preempting++
+			}
+		case config.JobStateWaitTimeout:
+			s.waitTimeouts++
+		}
+		if instance.LeaseWaitTime > 0 {
+			s.waited++
+		}
+		if instance.LeaseWaitTime > s.maxWait {
+			s.maxWait = instance.LeaseWaitTime
+		}
+	}
+
+	priorities := make([]int, 0, len(byPriority))
+	for priority := range byPriority {
+		priorities = append(priorities, priority)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+
+	sb.WriteString(fmt.Sprintf("%8s  %6s  %9s  %10s  %6s  %13s  %10s\n", "Priority", "Runs", "Preempted", "Preempting", "Waited", "Wait Timeouts", "Max Wait"))
+	for _, priority := range priorities {
+		s := byPriority[priority]
+		sb.WriteString(fmt.Sprintf("%8d  %6d  %9d  %10d  %6d  %13d  %10s\n",
+			priority, s.runs, s.preempted, s.preempting, s.waited, s.waitTimeouts, FormatDuration(s.maxWait)))
+	}
+	sb.WriteString("\n")
+	sb.WriteString("Compare with --set 'preemption={enabled: false}' to see the waits without preemption.\n")
+	sb.WriteString("\n")
+
+	return sb.String()
+}
@@ -0,0 +1,162 @@
+package chart
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// Dimensions of the SVG chart, in pixels
+const (
+	svgWidth        = 960
+	svgHeight       = 360
+	svgMarginLeft   = 48
+	svgMarginRight  = 16
+	svgMarginTop    = 32
+	svgMarginBottom = 56
+)
+
+// GenerateSVGChart generates an SVG chart of the active leases over time,
+// with the waiting jobs stacked above them and lines at the maximum number
+// of leases and at the start of the leases reserved for release controller
+// jobs, following the capacity of the time points
+func (g *Generator) GenerateSVGChart(timePoints []simulation.TimePoint, maxLeases, reservedLeases int) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		svgWidth, svgHeight, svgWidth, svgHeight))
+	sb.WriteString(fmt.Sprintf(`<text x="%d" y="20" font-size="14" font-weight="bold">Lease Usage Over Time</text>`+"\n", svgMarginLeft))
+
+	if len(timePoints) < 2 {
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d">No data to display</text>`+"\n", svgMarginLeft, svgHeight/2))
+		sb.WriteString("</svg>\n")
+		return sb.String()
+	}
+
+	start, end := timePoints[0].Time, timePoints[len(timePoints)-1].Time
+	top := maxLeases
+	hasCleanups := false
+	lowest, highest := maxLeases, maxLeases
+	for _, tp := range timePoints {
+		top = max(top, tp.ActiveLeases+cleanupLeases(tp)+tp.WaitingJobs, tp.MaxLeases)
+		hasCleanups = hasCleanups || cleanupLeases(tp) > 0
+		lowest, highest = min(lowest, tp.MaxLeases), max(highest, tp.MaxLeases)
+	}
+	top++
+
+	plotWidth := float64(svgWidth - svgMarginLeft - svgMarginRight)
+	plotHeight := float64(svgHeight - svgMarginTop - svgMarginBottom)
+	x := func(t time.Time) float64 {
+		return float64(svgMarginLeft) + plotWidth*float64(t.Sub(start))/float64(end.Sub(start))
+	}
+	y := func(leases int) float64 {
+		return float64(svgMarginTop) + plotHeight*float64(top-leases)/float64(top)
+	}
+
+	// Horizontal grid lines with the lease counts
+	step := max(top/8, 1)
+	for leases := 0; leases <= top; leases += step {
+		sb.WriteString(fmt.Sprintf(`<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#e0e0e0"/>`+"\n",
+			svgMarginLeft, y(leases), svgWidth-svgMarginRight, y(leases)))
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%d</text>`+"\n",
+			svgMarginLeft-6, y(leases), leases))
+	}
+
+	// Vertical grid lines at every day
+	for day := start.Truncate(24 * time.Hour); !day.After(end); day = day.Add(24 * time.Hour) {
+		if day.Before(start) {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf(`<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#e0e0e0"/>`+"\n",
+			x(day), svgMarginTop, x(day), svgHeight-svgMarginBottom))
+		sb.WriteString(fmt.Sprintf(`<text x="%.1f" y="%d" text-anchor="middle">%s</text>`+"\n",
+			x(day), svgHeight-svgMarginBottom+16, day.Format("Mon 02 Jan")))
+	}
+
+	// Waiting jobs stacked above the dirty leases and the active leases
+	sb.WriteString(fmt.Sprintf(`<path d="%s" fill="#f5a623" fill-opacity="0.6"/>`+"\n",
+		stepArea(timePoints, x, y, func(tp simulation.TimePoint) int { return tp.ActiveLeases + cleanupLeases(tp) + tp.WaitingJobs })))
+	if hasCleanups {
+		sb.WriteString(fmt.Sprintf(`<path d="%s" fill="#8b572a" fill-opacity="0.6"/>`+"\n",
+			stepArea(timePoints, x, y, func(tp simulation.TimePoint) int { return tp.ActiveLeases + cleanupLeases(tp) })))
+	}
+	sb.WriteString(fmt.Sprintf(`<path d="%s" fill="#4a90d9"/>`+"\n",
+		stepArea(timePoints, x, y, func(tp simulation.TimePoint) int { return tp.ActiveLeases })))
+
+	if lowest == highest {
+		sb.WriteString(fmt.Sprintf(`<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#d0021b" stroke-dasharray="6 4"/>`+"\n",
+			svgMarginLeft, y(maxLeases), svgWidth-svgMarginRight, y(maxLeases)))
+		if reservedLeases > 0 {
+			sb.WriteString(fmt.Sprintf(`<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#9b9b9b" stroke-dasharray="2 4"/>`+"\n",
+				svgMarginLeft, y(maxLeases-reservedLeases), svgWidth-svgMarginRight, y(maxLeases-reservedLeases)))
+		}
+	} else {
+		sb.WriteString(fmt.Sprintf(`<path d="%s" fill="none" stroke="#d0021b" stroke-dasharray="6 4"/>`+"\n",
+			stepLine(timePoints, x, y, func(tp simulation.TimePoint) int { return tp.MaxLeases })))
+		if reservedLeases > 0 {
+			sb.WriteString(fmt.Sprintf(`<path d="%s" fill="none" stroke="#9b9b9b" stroke-dasharray="2 4"/>`+"\n",
+				stepLine(timePoints, x, y, func(tp simulation.TimePoint) int { return max(tp.MaxLeases-reservedLeases, 0) })))
+		}
+	}
+
+	// Axes
+	sb.WriteString(fmt.Sprintf(`<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#000"/>`+"\n",
+		svgMarginLeft, svgMarginTop, svgMarginLeft, svgHeight-svgMarginBottom))
+	sb.WriteString(fmt.Sprintf(`<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#000"/>`+"\n",
+		svgMarginLeft, svgHeight-svgMarginBottom, svgWidth-svgMarginRight, svgHeight-svgMarginBottom))
+
+	// Legend
+	type legendItem struct{ color, label string }
+	legend := []legendItem{{"#4a90d9", "Active leases"}}
+	if hasCleanups {
+		legend = append(legend, legendItem{"#8b572a", "Dirty leases"})
+	}
+	legend = append(legend,
+		legendItem{"#f5a623", "Waiting jobs"},
+		legendItem{"#d0021b", fmt.Sprintf("Max active leases (%d)", maxLeases)})
+	if lowest != highest {
+		legend[len(legend)-1].label = fmt.Sprintf("Max active leases (%d to %d)", lowest, highest)
+	}
+	if reservedLeases > 0 {
+		legend = append(legend, legendItem{"#9b9b9b", fmt.Sprintf("Reserved for release controller jobs above (%d)", reservedLeases)})
+	}
+	legendX := svgMarginLeft
+	for _, item := range legend {
+		sb.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`+"\n", legendX, svgHeight-20, item.color))
+		sb.WriteString(fmt.Sprintf(`<text x="%d" y="%d">%s</text>`+"\n", legendX+14, svgHeight-11, html.EscapeString(item.label)))
+		legendX += 14 + 7*len(item.label) + 24
+	}
+
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
+
+// stepLine returns the path of a step function of the time points
+func stepLine(timePoints []simulation.TimePoint, x func(time.Time) float64, y func(int) float64, value func(simulation.TimePoint) int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("M%.1f %.1f", x(timePoints[0].Time), y(value(timePoints[0]))))
+	for i, tp := range timePoints {
+		sb.WriteString(fmt.Sprintf(" L%.1f %.1f", x(tp.Time), y(value(tp))))
+		if i+1 < len(timePoints) {
+			sb.WriteString(fmt.Sprintf(" L%.1f %.1f", x(timePoints[i+1].Time), y(value(tp))))
+		}
+	}
+	return sb.String()
+}
+
+// stepArea returns the path of the area below a step function of the time points
+func stepArea(timePoints []simulation.TimePoint, x func(time.Time) float64, y func(int) float64, value func(simulation.TimePoint) int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("M%.1f %.1f", x(timePoints[0].Time), y(0)))
+	for i, tp := range timePoints {
+		sb.WriteString(fmt.Sprintf(" L%.1f %.1f", x(tp.Time), y(value(tp))))
+		if i+1 < len(timePoints) {
+			sb.WriteString(fmt.Sprintf(" L%.1f %.1f", x(timePoints[i+1].Time), y(value(tp))))
+		}
+	}
+	sb.WriteString(fmt.Sprintf(" L%.1f %.1f Z", x(timePoints[len(timePoints)-1].Time), y(0)))
+	return sb.String()
+}
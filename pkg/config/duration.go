@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Day is 24 hours; calendar days with daylight saving changes are not considered
+	Day = 24 * time.Hour
+	// Week is 7 days
+	Week = 7 * Day
+)
+
+// Duration is a configuration duration. On top of the Go duration format
+// (e.g. "90m", "5h15m") it accepts days and weeks ("7d", "1w2d12h") and
+// ISO-8601 durations ("P7D", "PT5H30M"), and marshals to the compact form.
+type Duration struct {
+	time.Duration
+}
+
+// durationPart matches one number and unit of a duration such as "1w2d12h"
+var durationPart = regexp.MustCompile(`^([0-9]*\.?[0-9]+|[0-9]+\.)(ns|us|µs|ms|s|m|h|d|w)`)
+
+// isoDuration matches an ISO-8601 duration with week, day and time components
+var isoDuration = regexp.MustCompile(`^P(?:([0-9.]+)W)?(?:([0-9.]+)D)?(?:T(?:([0-9.]+)H)?(?:([0-9.]+)M)?(?:([0-9.]+)S)?)?$`)
+
+// isoUnits are the units of the components matched by isoDuration
+var isoUnits = []time.Duration{Week, Day, time.Hour, time.Minute, time.Second}
+
+// ParseDuration parses a duration in the Go format extended with the "d" and
+// "w" units, or in the ISO-8601 format. Years and months are rejected since
+// their length varies.
+func ParseDuration(s string) (time.Duration, error) {
+	value := strings.TrimSpace(s)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "-"), "+")
+
+	var d time.Duration
+	var err error
+	switch {
+	case value == "0":
+	case strings.HasPrefix(value, "P"):
+		d, err = parseISODuration(value)
+	default:
+		d, err = parseUnitDuration(value)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+
+	if negative {
+		return -d, nil
+	}
+	return d, nil
+}
+
+// parseUnitDuration parses a sequence of numbers with units
+func parseUnitDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var total time.Duration
+	for value != "" {
+		match := durationPart.FindStringSubmatch(value)
+		if match == nil {
+			return 0, fmt.Errorf("expected a number followed by a unit (ns, us, ms, s, m, h, d, w)")
+		}
+		value = value[len(match[0]):]
+
+		switch match[2] {
+		case "d", "w":
+			number, err := strconv.ParseFloat(match[1], 64)
+			if err != nil {
+				return 0, err
+			}
+			unit := Day
+			if match[2] == "w" {
+				unit = Week
+			}
+			total += time.Duration(number * float64(unit))
+		default:
+			part, err := time.ParseDuration(match[0])
+			if err != nil {
+				return 0, err
+			}
+			total += part
+		}
+	}
+	return total, nil
+}
+
+// parseISODuration parses an ISO-8601 duration such as "P1W2D" or "PT5H30M"
+func parseISODuration(value string) (time.Duration, error) {
+	match := isoDuration.FindStringSubmatch(value)
+	if match == nil {
+		if strings.ContainsAny(strings.SplitN(value, "T", 2)[0], "YM") {
+			return 0, fmt.Errorf("years and months are not supported")
+		}
+		return 0, fmt.Errorf("expected an ISO-8601 duration such as P7D or PT5H30M")
+	}
+	if value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("missing duration components")
+	}
+
+	var total time.Duration
+	for i, unit := range isoUnits {
+		if match[i+1] == "" {
+			continue
+		}
+		number, err := strconv.ParseFloat(match[i+1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", match[i+1])
+		}
+		total += time.Duration(number * float64(unit))
+	}
+	return total, nil
+}
+
+// String returns the duration in the compact format accepted by ParseDuration,
+// e.g. "7d", "1d12h" or "5h15m"
+func (d Duration) String() string {
+	value := d.Duration
+	if value == 0 {
+		return "0s"
+	}
+
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	var sb strings.Builder
+	sb.WriteString(sign)
+	if days := value / Day; days > 0 {
+		sb.WriteString(fmt.Sprintf("%dd", days))
+		value -= days * Day
+	}
+	if value%time.Second != 0 {
+		// Sub-second precision is rare enough to use the Go format for the rest
+		if value > 0 {
+			sb.WriteString(value.String())
+		}
+		return sb.String()
+	}
+	if hours := value / time.Hour; hours > 0 {
+		sb.WriteString(fmt.Sprintf("%dh", hours))
+		value -= hours * time.Hour
+	}
+	if minutes := value / time.Minute; minutes > 0 {
+		sb.WriteString(fmt.Sprintf("%dm", minutes))
+		value -= minutes * time.Minute
+	}
+	if seconds := value / time.Second; seconds > 0 {
+		sb.WriteString(fmt.Sprintf("%ds", seconds))
+	}
+	return sb.String()
+}
+
+// UnmarshalYAML parses a duration scalar. Errors are reported as field errors
+// so that decoding carries on and reports the other problems of the file.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: expected a duration", node.Line)}}
+	}
+
+	parsed, err := ParseDuration(node.Value)
+	if err != nil {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: %v", node.Line, err)}}
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalYAML returns the duration in its compact format
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+// UnmarshalText parses a duration, used by encoding/json
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalText returns the duration in its compact format, used by encoding/json
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "90m", want: 90 * time.Minute},
+		{value: "5h15m", want: 5*time.Hour + 15*time.Minute},
+		{value: "0", want: 0},
+		{value: "1d", want: Day},
+		{value: "2w", want: 2 * Week},
+		{value: "1w2d3h", want: Week + 2*Day + 3*time.Hour},
+		{value: "1.5d", want: 36 * time.Hour},
+		{value: "P7D", want: 7 * Day},
+		{value: "PT5H30M", want: 5*time.Hour + 30*time.Minute},
+		{value: "P1W", want: Week},
+		{value: "P1DT12H", want: 36 * time.Hour},
+		{value: " 7d ", want: 7 * Day},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseDuration(tt.value)
+			if err != nil {
+				t.Fatalf("ParseDuration(%q) error = %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDuration(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDurationErrors(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: "empty duration"},
+		{value: "   ", want: "empty duration"},
+		{value: "P1Y", want: "years and months are not supported"},
+		{value: "P1M", want: "years and months are not supported"},
+		{value: "P1Y2D", want: "years and months are not supported"},
+		{value: "P", want: "missing duration components"},
+		{value: "P1DT", want: "missing duration components"},
+		{value: "7", want: "expected a number followed by a unit"},
+		{value: "7x", want: "expected a number followed by a unit"},
+		{value: "d", want: "expected a number followed by a unit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			_, err := ParseDuration(tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseDuration(%q) error = %v, want %q", tt.value, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestNegativeDurations checks that negative durations parse with their sign,
+// so that validation reports them against the field they are set on
+func TestNegativeDurations(t *testing.T) {
+	if got, err := ParseDuration("-1d12h"); err != nil || got != -36*time.Hour {
+		t.Errorf("ParseDuration(-1d12h) = %s, %v, want -36h", got, err)
+	}
+
+	base := `maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+jobs:
+  - name: e2e
+    duration: 1h
+    triggerType: release-controller
+`
+	tests := []struct {
+		name    string
+		setting string
+		value   string
+		want    string
+	}{
+		{name: "job timeout", setting: "jobTimeoutDuration: 4h", value: "jobTimeoutDuration: -PT4H", want: "negative.yaml:2:21: jobTimeoutDuration must be greater than 0"},
+		{name: "lease wait timeout", setting: "leaseWaitTimeout: 2h", value: "leaseWaitTimeout: -2h", want: "negative.yaml:3:19: leaseWaitTimeout must be greater than 0"},
+		{name: "simulation duration", setting: "simulationDuration: 7d", value: "simulationDuration: -7d", want: "negative.yaml:4:21: simulationDuration must be greater than 0"},
+		{name: "job duration", setting: "duration: 1h", value: "duration: -P1D", want: "negative.yaml:7:15: job e2e: duration must be greater than 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("negative.yaml", []byte(strings.Replace(base, tt.setting, tt.value, 1)))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationRoundTrip(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		want     string
+	}{
+		{duration: 0, want: "0s"},
+		{duration: 45 * time.Second, want: "45s"},
+		{duration: 90 * time.Minute, want: "1h30m"},
+		{duration: Day, want: "1d"},
+		{duration: 7 * Day, want: "7d"},
+		{duration: 2*Week + 3*Day + 4*time.Hour + 5*time.Minute + 6*time.Second, want: "17d4h5m6s"},
+		{duration: 36*time.Hour + 1500*time.Millisecond, want: "1d12h0m1.5s"},
+		{duration: -(Day + 12*time.Hour), want: "-1d12h"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			d := Duration{tt.duration}
+			if got := d.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if parsed, err := ParseDuration(d.String()); err != nil || parsed != tt.duration {
+				t.Errorf("ParseDuration(String()) = %s, %v, want %s", parsed, err, tt.duration)
+			}
+
+			out, err := yaml.Marshal(struct {
+				D Duration `yaml:"d"`
+			}{d})
+			if err != nil {
+				t.Fatalf("yaml.Marshal() error = %v", err)
+			}
+			if want := "d: " + tt.want + "\n"; string(out) != want {
+				t.Errorf("yaml.Marshal() = %q, want %q", out, want)
+			}
+			var back struct {
+				D Duration `yaml:"d"`
+			}
+			if err := yaml.Unmarshal(out, &back); err != nil || back.D != d {
+				t.Errorf("yaml.Unmarshal(%q) = %s, %v, want %s", out, back.D, err, d)
+			}
+		})
+	}
+}
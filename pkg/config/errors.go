@@ -0,0 +1,194 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Position is a location in a configuration file
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// String returns the position as file:line:column, omitting unknown parts
+func (p Position) String() string {
+	switch {
+	case p.File == "":
+		return ""
+	case p.Line == 0:
+		return p.File
+	case p.Column == 0:
+		return fmt.Sprintf("%s:%d", p.File, p.Line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+}
+
+// Problem is a single configuration problem and where it was found
+type Problem struct {
+	Position Position
+	Message  string
+}
+
+// String returns the problem prefixed with its position
+func (p Problem) String() string {
+	if position := p.Position.String(); position != "" {
+		return position + ": " + p.Message
+	}
+	return p.Message
+}
+
+// ValidationError lists all the problems found in a configuration
+type ValidationError struct {
+	Problems []Problem
+}
+
+// Error returns the problems, one per line
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].String()
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d problems found:", len(e.Problems)))
+	for _, problem := range e.Problems {
+		sb.WriteString("\n  ")
+		sb.WriteString(problem.String())
+	}
+	return sb.String()
+}
+
+// yamlErrorLine matches the line prefix of the messages of yaml.TypeError
+var yamlErrorLine = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// decodeProblems converts the field errors of a YAML decoding, such as
+// unknown fields or mistyped values, into problems. Other errors are returned as is.
+func decodeProblems(filename string, err error) ([]Problem, error) {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return nil, err
+	}
+
+	problems := []Problem{}
+	for _, message := range typeErr.Errors {
+		problem := Problem{Position: Position{File: filename}, Message: message}
+		if match := yamlErrorLine.FindStringSubmatch(message); match != nil {
+			problem.Position.Line, _ = strconv.Atoi(match[1])
+			problem.Message = match[2]
+		}
+		problems = append(problems, problem)
+	}
+	return problems, nil
+}
+
+// nodePosition returns the position of a YAML node
+func nodePosition(filename string, node *yaml.Node) Position {
+	return Position{File: filename, Line: node.Line, Column: node.Column}
+}
+
+// mappingPositions returns the position of the mapping node under the ""
+// key and the position of the value of every key of the mapping
+func mappingPositions(filename string, mapping *yaml.Node) map[string]Position {
+	positions := map[string]Position{"": nodePosition(filename, mapping)}
+	if mapping.Kind != yaml.MappingNode {
+		return positions
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		positions[mapping.Content[i].Value] = nodePosition(filename, mapping.Content[i+1])
+	}
+	return positions
+}
+
+// recordPositions stores the positions of the settings, pools, capacity
+// changes, jobs and job templates of a configuration decoded from root
+func recordPositions(filename string, root *yaml.Node, config *Config) {
+	mapping := documentMapping(root)
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		config.positions = map[string]Position{"": {File: filename}}
+		return
+	}
+	config.positions = mappingPositions(filename, mapping)
+
+	// The fields of settings such as preemption are recorded as
+	// "preemption.enabled", so that merging includes knows they were set
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if value := mapping.Content[i+1]; value.Kind == yaml.MappingNode {
+			for field, position := range mappingPositions(filename, value) {
+				if field != "" {
+					config.positions[mapping.Content[i].Value+"."+field] = position
+				}
+			}
+		}
+	}
+
+	if jobs := mappingValue(mapping, "jobs"); jobs != nil && jobs.Kind == yaml.SequenceNode {
+		for i, item := range jobs.Content {
+			if i < len(config.Jobs) {
+				config.Jobs[i].positions = mappingPositions(filename, item)
+			}
+		}
+	}
+
+	if pools := mappingValue(mapping, "pools"); pools != nil && pools.Kind == yaml.SequenceNode {
+		for i, item := range pools.Content {
+			if i < len(config.Pools) {
+				config.Pools[i].positions = mappingPositions(filename, item)
+			}
+		}
+	}
+
+	if changes := mappingValue(mapping, "capacitySchedule"); changes != nil && changes.Kind == yaml.SequenceNode {
+		for i, item := range changes.Content {
+			if i < len(config.CapacitySchedule) {
+				config.CapacitySchedule[i].positions = mappingPositions(filename, item)
+			}
+		}
+	}
+
+	if templates := mappingValue(mapping, "templates"); templates != nil && templates.Kind == yaml.SequenceNode {
+		for i, item := range templates.Content {
+			if i < len(config.Templates) {
+				config.Templates[i].positions = mappingPositions(filename, item)
+			}
+		}
+	}
+}
+
+// Position returns the position of a setting, or of the file if the setting is not set
+func (c *Config) Position(field string) Position {
+	if position, ok := c.positions[field]; ok {
+		return position
+	}
+	return c.positions[""]
+}
+
+// Position returns the position of a job field, or of the job if the field is not set
+func (j *Job) Position(field string) Position {
+	if position, ok := j.positions[field]; ok {
+		return position
+	}
+	return j.positions[""]
+}
+
+// Position returns the position of a pool field, or of the pool if the field is not set
+func (p *Pool) Position(field string) Position {
+	if position, ok := p.positions[field]; ok {
+		return position
+	}
+	return p.positions[""]
+}
+
+// Position returns the position of a capacity change field, or of the change if the field is not set
+func (c *CapacityChange) Position(field string) Position {
+	if position, ok := c.positions[field]; ok {
+		return position
+	}
+	return c.positions[""]
+}
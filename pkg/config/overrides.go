@@ -0,0 +1,238 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Overrides are what-if changes applied on top of a loaded configuration,
+// before it is validated
+type Overrides struct {
+	// Set holds assignments of the form "path=value", where path is a
+	// top-level field (e.g. "maxActiveLeases") or a job field prefixed by a
+	// job selector (e.g. "jobs[name=ocp-4.19-*].duration" or "jobs[3].cronSchedule").
+	// Values are parsed as YAML, like in the configuration file.
+	Set []string
+	// DisableJobs removes the jobs whose name matches one of the glob patterns
+	DisableJobs []string
+	// OnlyVersions keeps only the jobs of the listed versions
+	OnlyVersions []string
+}
+
+// IsEmpty reports whether the overrides change nothing
+func (o Overrides) IsEmpty() bool {
+	return len(o.Set) == 0 && len(o.DisableJobs) == 0 && len(o.OnlyVersions) == 0
+}
+
+// Apply applies the overrides to the configuration. Assignments are applied
+// first, in order, then the job filters.
+func (o Overrides) Apply(config *Config) error {
+	for _, assignment := range o.Set {
+		if err := applyAssignment(config, assignment); err != nil {
+			return fmt.Errorf("--set %s: %w", assignment, err)
+		}
+	}
+
+	for _, pattern := range o.DisableJobs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("--disable-job %s: %w", pattern, err)
+		}
+
+		jobs := []Job{}
+		for _, job := range config.Jobs {
+			if matched, _ := path.Match(pattern, job.Name); !matched {
+				jobs = append(jobs, job)
+			}
+		}
+		if len(jobs) == len(config.Jobs) {
+			return fmt.Errorf("--disable-job %s: no job matches", pattern)
+		}
+		config.Jobs = jobs
+	}
+
+	if len(o.OnlyVersions) > 0 {
+		versions := make(map[string]bool)
+		for _, version := range o.OnlyVersions {
+			versions[version] = true
+		}
+
+		jobs := []Job{}
+		for _, job := range config.Jobs {
+			if versions[job.Version] {
+				jobs = append(jobs, job)
+			}
+		}
+		config.Jobs = jobs
+	}
+
+	return nil
+}
+
+// SetsJobField reports whether an assignment sets one of the job fields,
+// named by their yaml name
+func (o Overrides) SetsJobField(fields ...string) bool {
+	for _, assignment := range o.Set {
+		fieldPath, _, err := splitAssignment(assignment)
+		if err != nil || !strings.HasPrefix(fieldPath, "jobs[") {
+			continue
+		}
+		field := strings.TrimPrefix(fieldPath[strings.Index(fieldPath, "]")+1:], ".")
+		if slices.Contains(fields, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitAssignment splits a "path=value" assignment
+func splitAssignment(assignment string) (string, string, error) {
+	var fieldPath, value string
+	var found bool
+	if strings.HasPrefix(assignment, "jobs[") {
+		// The job selector may itself contain '=', e.g. jobs[name=x].duration=6h
+		selectorEnd := strings.Index(assignment, "]")
+		if selectorEnd < 0 {
+			return "", "", fmt.Errorf("unterminated job selector")
+		}
+		var field string
+		field, value, found = strings.Cut(assignment[selectorEnd+1:], "=")
+		fieldPath = assignment[:selectorEnd+1] + field
+	} else {
+		fieldPath, value, found = strings.Cut(assignment, "=")
+	}
+	if !found {
+		return "", "", fmt.Errorf("expected path=value")
+	}
+	return fieldPath, value, nil
+}
+
+// applyAssignment applies a single "path=value" assignment
+func applyAssignment(config *Config, assignment string) error {
+	fieldPath, value, err := splitAssignment(assignment)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(fieldPath, "jobs[") {
+		if err := setField(reflect.ValueOf(config).Elem(), fieldPath, value); err != nil {
+			return err
+		}
+		config.positions = overridePosition(config.positions, fieldPath, assignment)
+		return nil
+	}
+
+	selectorEnd := strings.Index(fieldPath, "]")
+	selector := fieldPath[len("jobs["):selectorEnd]
+	field := strings.TrimPrefix(fieldPath[selectorEnd+1:], ".")
+	if field == "" {
+		return fmt.Errorf("missing job field after selector")
+	}
+
+	indexes, err := selectJobs(config.Jobs, selector)
+	if err != nil {
+		return err
+	}
+	for _, i := range indexes {
+		job := &config.Jobs[i]
+		if err := setField(reflect.ValueOf(job).Elem(), field, value); err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+
+		job.positions = overridePosition(job.positions, field, assignment)
+	}
+
+	return nil
+}
+
+// overridePosition returns the positions with the override recorded as the
+// position of the field. The positions are copied since jobs expanded from a
+// template share them.
+func overridePosition(positions map[string]Position, field, assignment string) map[string]Position {
+	overridden := map[string]Position{field: {File: "--set " + assignment}}
+	for key, position := range positions {
+		if key != field {
+			overridden[key] = position
+		}
+	}
+	return overridden
+}
+
+// selectJobs returns the indexes of the jobs matched by a selector: "*" for
+// all jobs, an index, or "field=glob" matching a job field such as name or version
+func selectJobs(jobs []Job, selector string) ([]int, error) {
+	indexes := []int{}
+
+	if selector == "*" {
+		for i := range jobs {
+			indexes = append(indexes, i)
+		}
+		return indexes, nil
+	}
+
+	if index, err := strconv.Atoi(selector); err == nil {
+		if index < 0 || index >= len(jobs) {
+			return nil, fmt.Errorf("job index %d out of range", index)
+		}
+		return []int{index}, nil
+	}
+
+	field, pattern, found := strings.Cut(selector, "=")
+	if !found {
+		return nil, fmt.Errorf("invalid job selector %q", selector)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid job selector %q: %w", selector, err)
+	}
+
+	for i := range jobs {
+		value, err := fieldByTag(reflect.ValueOf(&jobs[i]).Elem(), field)
+		if err != nil {
+			return nil, err
+		}
+		if matched, _ := path.Match(pattern, fmt.Sprint(value.Interface())); matched {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("no job matches selector %q", selector)
+	}
+
+	return indexes, nil
+}
+
+// setField parses value as YAML into the struct field with the given yaml name
+func setField(v reflect.Value, name, value string) error {
+	field, err := fieldByTag(v, name)
+	if err != nil {
+		return err
+	}
+
+	parsed := reflect.New(field.Type())
+	if err := yaml.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", name, err)
+	}
+	field.Set(parsed.Elem())
+
+	return nil
+}
+
+// fieldByTag returns the struct field whose yaml tag name is name
+func fieldByTag(v reflect.Value, name string) (reflect.Value, error) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if tag == name {
+			return v.Field(i), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("unknown field %q", name)
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const overridesConfig = `maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+jobs:
+  - name: e2e-4.19
+    version: "4.19"
+    duration: 1h
+    triggerType: release-controller
+  - name: e2e-4.20
+    version: "4.20"
+    duration: 1h
+    triggerType: release-controller
+  - name: upgrade-4.20
+    version: "4.20"
+    duration: 2h
+    triggerType: cron
+    cronSchedule: "0 1 * * *"
+`
+
+func TestOverridesApply(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filename, []byte(overridesConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	allJobs := []string{"e2e-4.19", "e2e-4.20", "upgrade-4.20"}
+	tests := []struct {
+		name      string
+		overrides Overrides
+		wantJobs  []string
+		check     func(cfg *Config) bool
+		wantErr   string
+	}{
+		{
+			name:      "top-level setting",
+			overrides: Overrides{Set: []string{"maxActiveLeases=14", "leaseWaitTimeout=30m"}},
+			wantJobs:  allJobs,
+			check: func(cfg *Config) bool {
+				return cfg.MaxActiveLeases == 14 && cfg.LeaseWaitTimeout.Duration == 30*time.Minute
+			},
+		},
+		{
+			name:      "nested setting",
+			overrides: Overrides{Set: []string{"preemption={enabled: true, onPreempt: retry}"}},
+			wantJobs:  allJobs,
+			check: func(cfg *Config) bool {
+				return cfg.Preemption.Enabled && cfg.Preemption.OnPreempt == PreemptActionRetry
+			},
+		},
+		{
+			name:      "jobs selected by name",
+			overrides: Overrides{Set: []string{"jobs[name=e2e-*].duration=6h"}},
+			wantJobs:  allJobs,
+			check: func(cfg *Config) bool {
+				return cfg.Jobs[0].Duration.Duration == 6*time.Hour && cfg.Jobs[1].Duration.Duration == 6*time.Hour && cfg.Jobs[2].Duration.Duration == 2*time.Hour
+			},
+		},
+		{
+			name:      "jobs selected by version",
+			overrides: Overrides{Set: []string{"jobs[version=4.20].priority=5"}},
+			wantJobs:  allJobs,
+			check: func(cfg *Config) bool {
+				return cfg.Jobs[0].Priority == 0 && cfg.Jobs[1].Priority == 5 && cfg.Jobs[2].Priority == 5
+			},
+		},
+		{
+			name:      "job selected by index",
+			overrides: Overrides{Set: []string{"jobs[2].cronSchedule=30 2 * * *"}},
+			wantJobs:  allJobs,
+			check: func(cfg *Config) bool {
+				return cfg.Jobs[2].CronSchedule == "30 2 * * *"
+			},
+		},
+		{
+			name:      "all jobs selected, later assignments winning",
+			overrides: Overrides{Set: []string{"jobs[*].duration=3h", "jobs[0].duration=30m"}},
+			wantJobs:  allJobs,
+			check: func(cfg *Config) bool {
+				return cfg.Jobs[0].Duration.Duration == 30*time.Minute && cfg.Jobs[2].Duration.Duration == 3*time.Hour
+			},
+		},
+		{
+			name:      "disabled jobs",
+			overrides: Overrides{DisableJobs: []string{"e2e-*"}},
+			wantJobs:  []string{"upgrade-4.20"},
+		},
+		{
+			name:      "only versions",
+			overrides: Overrides{OnlyVersions: []string{"4.19"}},
+			wantJobs:  []string{"e2e-4.19"},
+		},
+		{
+			name:      "disabled jobs and only versions",
+			overrides: Overrides{DisableJobs: []string{"upgrade-*"}, OnlyVersions: []string{"4.20"}},
+			wantJobs:  []string{"e2e-4.20"},
+		},
+		{
+			name:      "unknown job",
+			overrides: Overrides{Set: []string{"jobs[name=serial-*].duration=6h"}},
+			wantErr:   `--set jobs[name=serial-*].duration=6h: no job matches selector "name=serial-*"`,
+		},
+		{
+			name:      "job index out of range",
+			overrides: Overrides{Set: []string{"jobs[3].duration=6h"}},
+			wantErr:   "--set jobs[3].duration=6h: job index 3 out of range",
+		},
+		{
+			name:      "unknown field",
+			overrides: Overrides{Set: []string{"maxLeases=14"}},
+			wantErr:   `--set maxLeases=14: unknown field "maxLeases"`,
+		},
+		{
+			name:      "missing value",
+			overrides: Overrides{Set: []string{"maxActiveLeases"}},
+			wantErr:   "--set maxActiveLeases: expected path=value",
+		},
+		{
+			name:      "unterminated selector",
+			overrides: Overrides{Set: []string{"jobs[0.duration=6h"}},
+			wantErr:   "--set jobs[0.duration=6h: unterminated job selector",
+		},
+		{
+			name:      "disabled job matching nothing",
+			overrides: Overrides{DisableJobs: []string{"serial-*"}},
+			wantErr:   "--disable-job serial-*: no job matches",
+		},
+		{
+			name:      "disabled jobs conflicting with only versions",
+			overrides: Overrides{DisableJobs: []string{"e2e-4.19"}, OnlyVersions: []string{"4.19"}},
+			wantErr:   "at least one job must be defined",
+		},
+		{
+			name:      "invalid top-level setting",
+			overrides: Overrides{Set: []string{"maxActiveLeases=0"}},
+			wantErr:   "--set maxActiveLeases=0: maxActiveLeases must be greater than 0",
+		},
+		{
+			name:      "invalid job setting",
+			overrides: Overrides{Set: []string{"jobs[0].duration=-1h"}},
+			wantErr:   "--set jobs[0].duration=-1h: job e2e-4.19: duration must be greater than 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfigWithOverrides(filename, tt.overrides)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadConfigWithOverrides() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfigWithOverrides() error = %v", err)
+			}
+
+			jobs := []string{}
+			for _, job := range cfg.Jobs {
+				jobs = append(jobs, job.Name)
+			}
+			if !reflect.DeepEqual(jobs, tt.wantJobs) {
+				t.Errorf("jobs = %v, want %v", jobs, tt.wantJobs)
+			}
+			if tt.check != nil && !tt.check(cfg) {
+				t.Errorf("overrides %+v not applied", tt.overrides)
+			}
+		})
+	}
+}
+
+func TestSetsJobField(t *testing.T) {
+	tests := []struct {
+		set  []string
+		want bool
+	}{
+		{set: nil, want: false},
+		{set: []string{"maxActiveLeases=14"}, want: false},
+		{set: []string{"jobs[name=ocp-4.19-*].duration=6h"}, want: false},
+		{set: []string{"jobs[name=ocp-4.19-*].cronSchedule=0 3 * * *"}, want: true},
+		{set: []string{"jobs[3].movable=true"}, want: true},
+		{set: []string{"jobs[version=4.19].duration=6h", "jobs[*].allowedHours=0-6"}, want: true},
+		{set: []string{"cronSchedule=0 3 * * *"}, want: false},
+	}
+
+	for _, tt := range tests {
+		overrides := Overrides{Set: tt.set}
+		if got := overrides.SetsJobField("cronSchedule", "movable", "allowedHours"); got != tt.want {
+			t.Errorf("SetsJobField(%q) = %v, want %v", tt.set, got, tt.want)
+		}
+	}
+}
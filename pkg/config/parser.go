@@ -1,75 +1,351 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/sherine-k/leases/pkg/schedule"
 	"gopkg.in/yaml.v3"
 )
 
 // LoadConfig loads and parses the configuration file
 func LoadConfig(filename string) (*Config, error) {
+	return LoadConfigWithOverrides(filename, Overrides{})
+}
+
+// LoadConfigWithOverrides loads and parses the configuration file, applying
+// the what-if overrides before validating it
+func LoadConfigWithOverrides(filename string, overrides Overrides) (*Config, error) {
+	config, err := loadFile(filename, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := overrides.Apply(config); err != nil {
+		return nil, fmt.Errorf("failed to apply overrides: %w", err)
+	}
+
+	// Validate configuration
+	if err := validateConfig(config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return config, nil
+}
+
+// loadFile reads a configuration file, merging the files it includes and
+// expanding its job templates. loading holds the files being loaded, to
+// detect include cycles.
+func loadFile(filename string, loading map[string]bool) (*Config, error) {
+	absPath, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config file path: %w", err)
+	}
+	if loading[absPath] {
+		return nil, fmt.Errorf("include cycle detected at %s", filename)
+	}
+	loading[absPath] = true
+	defer delete(loading, absPath)
+
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	config, err := decodeConfig(filename, data)
+	if err != nil {
+		return nil, err
 	}
 
-	// Validate configuration
-	if err := validateConfig(&config); err != nil {
+	// Included files come first, so that settings of the including file win
+	merged := &Config{}
+	files := []string{filename}
+	for _, include := range config.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(filename), include)
+		}
+
+		included, err := loadFile(include, loading)
+		if err != nil {
+			return nil, fmt.Errorf("%s: include %s: %w", filename, include, err)
+		}
+		mergeConfig(merged, included)
+		files = append(files, included.files...)
+	}
+	mergeConfig(merged, config)
+	merged.files = files
+	for field, position := range config.positions {
+		if _, ok := merged.positions[field]; !ok {
+			merged.positions[field] = position
+		}
+	}
+
+	return merged, nil
+}
+
+// Parse parses and validates a configuration that is not read from a file,
+// such as one received over the network. name identifies the configuration
+// in the problems reported. Includes are rejected since they refer to files.
+func Parse(name string, data []byte) (*Config, error) {
+	config, err := decodeConfig(name, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Include) > 0 {
+		config.problems = append(config.problems, Problem{Position: config.Position("include"), Message: "include is only supported in configuration files"})
+	}
+
+	if err := validateConfig(config); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	return config, nil
+}
+
+// decodeConfig decodes a configuration document and expands its job
+// templates. Field problems are recorded in the configuration rather than
+// returned, so that validation reports them along with the others.
+func decodeConfig(filename string, data []byte) (*Config, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	// Decode with known-fields checking so that typos in field names are reported
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && err != io.EOF {
+		problems, err := decodeProblems(filename, err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+		config.problems = append(config.problems, problems...)
+	}
+	recordPositions(filename, &root, &config)
+
+	templateJobs, problems := expandTemplates(config.Templates)
+	config.Jobs = append(config.Jobs, templateJobs...)
+	config.problems = append(config.problems, problems...)
+
 	return &config, nil
 }
 
-// validateConfig validates the configuration
+// mergeConfig merges src into dst: settings and pools set in src replace the
+// ones of dst, even with zero values, and the capacity changes, jobs and
+// problems of src are appended to the ones of dst
+func mergeConfig(dst, src *Config) {
+	if dst.positions == nil {
+		dst.positions = make(map[string]Position)
+	}
+	// merge applies a setting of src when it was set in its files, recording
+	// its position and the one of the setting it is a field of
+	merge := func(field string, apply func()) {
+		position, ok := src.positions[field]
+		if !ok {
+			return
+		}
+		apply()
+		dst.positions[field] = position
+		if setting, _, nested := strings.Cut(field, "."); nested {
+			dst.positions[setting] = src.Position(setting)
+		}
+	}
+
+	merge("maxActiveLeases", func() { dst.MaxActiveLeases = src.MaxActiveLeases })
+	merge("reservedLeases", func() { dst.ReservedLeases = src.ReservedLeases })
+	merge("jobTimeoutDuration", func() { dst.JobTimeoutDuration = src.JobTimeoutDuration })
+	merge("leaseWaitTimeout", func() { dst.LeaseWaitTimeout = src.LeaseWaitTimeout })
+	merge("simulationDuration", func() { dst.SimulationDuration = src.SimulationDuration })
+	merge("releaseInterval.min", func() { dst.ReleaseInterval.Min = src.ReleaseInterval.Min })
+	merge("releaseInterval.max", func() { dst.ReleaseInterval.Max = src.ReleaseInterval.Max })
+	merge("preemption.enabled", func() { dst.Preemption.Enabled = src.Preemption.Enabled })
+	merge("preemption.onPreempt", func() { dst.Preemption.OnPreempt = src.Preemption.OnPreempt })
+	for _, pool := range src.Pools {
+		replaced := false
+		for i := range dst.Pools {
+			if dst.Pools[i].Name == pool.Name {
+				dst.Pools[i] = pool
+				replaced = true
+			}
+		}
+		if !replaced {
+			dst.Pools = append(dst.Pools, pool)
+		}
+	}
+	dst.CapacitySchedule = append(dst.CapacitySchedule, src.CapacitySchedule...)
+	dst.Jobs = append(dst.Jobs, src.Jobs...)
+	dst.problems = append(dst.problems, src.problems...)
+}
+
+// Validate validates a loaded configuration again, after a caller changed
+// some of its settings
+func (c *Config) Validate() error {
+	if err := validateConfig(c); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+// validateConfig validates the configuration, reporting all the problems
+// found as a *ValidationError
 func validateConfig(config *Config) error {
+	problems := append([]Problem{}, config.problems...)
+	report := func(position Position, format string, args ...interface{}) {
+		problems = append(problems, Problem{Position: position, Message: fmt.Sprintf(format, args...)})
+	}
+
 	if config.MaxActiveLeases <= 0 {
-		return fmt.Errorf("maxActiveLeases must be greater than 0")
+		report(config.Position("maxActiveLeases"), "maxActiveLeases must be greater than 0")
 	}
 
-	if config.JobTimeoutDuration <= 0 {
-		return fmt.Errorf("jobTimeoutDuration must be greater than 0")
+	if config.ReservedLeases < 0 || (config.MaxActiveLeases > 0 && config.ReservedLeases >= config.MaxActiveLeases) {
+		report(config.Position("reservedLeases"), "reservedLeases must be between 0 and maxActiveLeases - 1")
 	}
 
-	if config.LeaseWaitTimeout <= 0 {
-		return fmt.Errorf("leaseWaitTimeout must be greater than 0")
+	if config.JobTimeoutDuration.Duration <= 0 {
+		report(config.Position("jobTimeoutDuration"), "jobTimeoutDuration must be greater than 0")
 	}
 
-	if config.SimulationDuration <= 0 {
-		return fmt.Errorf("simulationDuration must be greater than 0")
+	if config.LeaseWaitTimeout.Duration <= 0 {
+		report(config.Position("leaseWaitTimeout"), "leaseWaitTimeout must be greater than 0")
+	}
+
+	if config.SimulationDuration.Duration <= 0 {
+		report(config.Position("simulationDuration"), "simulationDuration must be greater than 0")
+	}
+
+	if interval := config.ReleaseInterval; (interval.Min.Duration != 0 && interval.Min.Duration < MinReleaseInterval) ||
+		(interval.Max.Duration != 0 && interval.Max.Duration < MinReleaseInterval) {
+		report(config.Position("releaseInterval"), "releaseInterval bounds must be at least %s", Duration{Duration: MinReleaseInterval})
+	} else if interval.Max.Duration != 0 && interval.Min.Duration > interval.Max.Duration {
+		report(config.Position("releaseInterval"), "releaseInterval min %s is longer than max %s", interval.Min, interval.Max)
+	}
+
+	if action := config.Preemption.OnPreempt; action != "" && action != PreemptActionDrop && action != PreemptActionRetry {
+		report(config.Position("preemption"), "preemption onPreempt must be either 'drop' or 'retry'")
+	}
+
+	poolsByName := make(map[string]Pool)
+	for i := range config.Pools {
+		pool := &config.Pools[i]
+
+		if pool.Name == "" {
+			report(pool.Position(""), "pool %d: name is required", i)
+		} else if first, ok := poolsByName[pool.Name]; ok {
+			report(pool.Position("name"), "pool %s: duplicate name, first defined at %s", pool.Name, first.Position("name"))
+		} else {
+			poolsByName[pool.Name] = *pool
+		}
+
+		if pool.CleanupDelay.Duration < 0 {
+			report(pool.Position("cleanupDelay"), "pool %s: cleanupDelay must not be negative", pool.Name)
+		}
+		if pool.SetupDuration.Duration < 0 {
+			report(pool.Position("setupDuration"), "pool %s: setupDuration must not be negative", pool.Name)
+		}
+		if pool.TeardownDuration.Duration < 0 {
+			report(pool.Position("teardownDuration"), "pool %s: teardownDuration must not be negative", pool.Name)
+		}
+	}
+
+	for i := range config.CapacitySchedule {
+		change := &config.CapacitySchedule[i]
+		label := change.Label(i)
+
+		if change.MaxActiveLeases < 0 {
+			report(change.Position("maxActiveLeases"), "capacity change %s: maxActiveLeases must not be negative", label)
+		}
+
+		switch {
+		case change.At.IsZero() && change.CronSchedule == "":
+			report(change.Position(""), "capacity change %s: either at or cronSchedule is required", label)
+		case !change.At.IsZero() && change.CronSchedule != "":
+			report(change.Position("cronSchedule"), "capacity change %s: at and cronSchedule are exclusive", label)
+		case change.CronSchedule != "":
+			if _, err := schedule.ParseSchedule(change.CronSchedule); err != nil {
+				report(change.Position("cronSchedule"), "capacity change %s: invalid cronSchedule %q: %v", label, change.CronSchedule, err)
+			}
+			if change.Duration.Duration == 0 {
+				report(change.Position(""), "capacity change %s: duration is required for cronSchedule windows", label)
+			}
+		}
+
+		if change.Duration.Duration < 0 {
+			report(change.Position("duration"), "capacity change %s: duration must not be negative", label)
+		}
+
+		if change.Policy != "" && change.Policy != CapacityPolicyDrain && change.Policy != CapacityPolicyPreempt {
+			report(change.Position("policy"), "capacity change %s: policy must be either 'drain' or 'preempt'", label)
+		}
 	}
 
 	if len(config.Jobs) == 0 {
-		return fmt.Errorf("at least one job must be defined")
+		report(config.Position("jobs"), "at least one job must be defined")
 	}
 
-	for i, job := range config.Jobs {
+	jobsByName := make(map[string]Job)
+	for i := range config.Jobs {
+		job := &config.Jobs[i]
+
 		if job.Name == "" {
-			return fmt.Errorf("job %d: name is required", i)
+			report(job.Position(""), "job %d: name is required", i)
+		} else if first, ok := jobsByName[job.Name]; ok {
+			report(job.Position("name"), "job %s: duplicate name, first defined at %s", job.Name, first.Position("name"))
+		} else {
+			jobsByName[job.Name] = *job
 		}
 
-		if job.Duration <= 0 {
-			return fmt.Errorf("job %s: duration must be greater than 0", job.Name)
+		if job.Duration.Duration <= 0 {
+			report(job.Position("duration"), "job %s: duration must be greater than 0", job.Name)
+		}
+		if job.SetupDuration.Duration < 0 {
+			report(job.Position("setupDuration"), "job %s: setupDuration must not be negative", job.Name)
+		}
+		if job.TeardownDuration.Duration < 0 {
+			report(job.Position("teardownDuration"), "job %s: teardownDuration must not be negative", job.Name)
 		}
 
 		if job.TriggerType != TriggerTypeCron && job.TriggerType != TriggerTypeReleaseController {
-			return fmt.Errorf("job %s: triggerType must be either 'cron' or 'release-controller'", job.Name)
+			report(job.Position("triggerType"), "job %s: triggerType must be either 'cron' or 'release-controller'", job.Name)
 		}
 
 		if job.TriggerType == TriggerTypeCron && job.CronSchedule == "" {
-			return fmt.Errorf("job %s: cronSchedule is required for cron-type jobs", job.Name)
+			report(job.Position(""), "job %s: cronSchedule is required for cron-type jobs", job.Name)
 		}
 
+		if job.CronSchedule != "" {
+			if _, err := schedule.ParseSchedule(job.CronSchedule); err != nil {
+				report(job.Position("cronSchedule"), "job %s: invalid cronSchedule %q: %v", job.Name, job.CronSchedule, err)
+			}
+		}
+
+		if job.Movable && job.TriggerType != TriggerTypeCron {
+			report(job.Position("movable"), "job %s: only cron-type jobs can be movable", job.Name)
+		}
+
+		if job.AllowedHours != "" {
+			if _, err := schedule.ExpandField(job.AllowedHours, 0, 23); err != nil {
+				report(job.Position("allowedHours"), "job %s: invalid allowedHours: %v", job.Name, err)
+			}
+		}
+
+		// Release-controller triggered jobs are release controller jobs
 		if job.TriggerType == TriggerTypeReleaseController {
+			if _, explicit := job.positions["isReleaseController"]; explicit && !job.IsReleaseController {
+				report(job.Position("isReleaseController"), "job %s: isReleaseController cannot be false for release-controller triggered jobs", job.Name)
+			}
 			job.IsReleaseController = true
 		}
 	}
 
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
 	return nil
 }
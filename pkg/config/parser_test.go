@@ -0,0 +1,454 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse("posted.yaml", []byte(`maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+jobs:
+  - name: rc-e2e
+    duration: 90m
+    triggerType: release-controller
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if cfg.SimulationDuration.Duration != 7*Day || cfg.Jobs[0].Duration.Duration != 90*time.Minute {
+		t.Errorf("Parse() durations = %s, %s, want 7d, 1h30m", cfg.SimulationDuration, cfg.Jobs[0].Duration)
+	}
+	if !cfg.Jobs[0].IsReleaseController {
+		t.Error("Parse() did not validate the configuration: release-controller job is not a release controller job")
+	}
+	if len(cfg.Files()) != 0 {
+		t.Errorf("Files() = %v, want none", cfg.Files())
+	}
+}
+
+func TestParseProblems(t *testing.T) {
+	_, err := Parse("posted.yaml", []byte(`include: [other.yaml]
+maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2x
+simulationDuration: 7d
+jobs:
+  - name: e2e
+    duration: 1h
+    triggerType: cron
+`))
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Parse() error = %v, want a *ValidationError", err)
+	}
+	want := []string{
+		`posted.yaml:4: invalid duration "2x": expected a number followed by a unit (ns, us, ms, s, m, h, d, w)`,
+		"posted.yaml:1:10: include is only supported in configuration files",
+		"posted.yaml:4:19: leaseWaitTimeout must be greater than 0",
+		"posted.yaml:7:5: job e2e: cronSchedule is required for cron-type jobs",
+	}
+	if len(validationErr.Problems) != len(want) {
+		t.Fatalf("Parse() problems = %v, want %d", validationErr, len(want))
+	}
+	for i, problem := range validationErr.Problems {
+		if problem.String() != want[i] {
+			t.Errorf("problem %d = %q, want %q", i, problem, want[i])
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg, err := Parse("posted.yaml", []byte(`maxActiveLeases: 3
+reservedLeases: 1
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+jobs:
+  - name: e2e
+    duration: 1h
+    triggerType: release-controller
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg.MaxActiveLeases = 1
+	err = cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "posted.yaml:2:17: reservedLeases must be between 0 and maxActiveLeases - 1") {
+		t.Errorf("Validate() error = %v, want the reserved leases rejected", err)
+	}
+}
+
+func TestDuplicateJobNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "same file",
+			files: map[string]string{"config.yaml": `maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+jobs:
+  - name: e2e
+    duration: 1h
+    triggerType: release-controller
+  - name: upgrade
+    duration: 1h
+    triggerType: release-controller
+  - name: e2e
+    duration: 2h
+    triggerType: release-controller
+`},
+			want: []string{"config.yaml:12:11: job e2e: duplicate name, first defined at config.yaml:6:11"},
+		},
+		{
+			name: "included file",
+			files: map[string]string{
+				"common.yaml": `jobs:
+  - name: e2e
+    duration: 1h
+    triggerType: release-controller
+`,
+				"config.yaml": `include: [common.yaml]
+maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+jobs:
+  - name: e2e
+    duration: 1h
+    triggerType: release-controller
+`,
+			},
+			want: []string{"config.yaml:7:11: job e2e: duplicate name, first defined at common.yaml:2:11"},
+		},
+		{
+			name: "template expansion",
+			files: map[string]string{"config.yaml": `maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+jobs:
+  - name: e2e-4.19
+    duration: 1h
+    triggerType: release-controller
+templates:
+  - name: e2e-${version}
+    duration: 1h
+    triggerType: release-controller
+    matrix:
+      - version: "4.19"
+      - version: "4.20"
+`},
+			want: []string{"job e2e-4.19: duplicate name, first defined at config.yaml:6:11"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			_, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("LoadConfig() error = %v, want a *ValidationError", err)
+			}
+			if len(validationErr.Problems) != len(tt.want) {
+				t.Fatalf("LoadConfig() problems = %v, want %q", validationErr, tt.want)
+			}
+			for i, problem := range validationErr.Problems {
+				got := strings.ReplaceAll(problem.String(), dir+string(filepath.Separator), "")
+				if !strings.HasSuffix(got, tt.want[i]) {
+					t.Errorf("problem %d = %q, want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseReleaseInterval(t *testing.T) {
+	parse := func(releaseInterval string) (*Config, error) {
+		return Parse("posted.yaml", []byte(`maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+releaseInterval: `+releaseInterval+`
+jobs:
+  - name: rc-e2e
+    duration: 90m
+    triggerType: release-controller
+`))
+	}
+
+	tests := []struct {
+		releaseInterval string
+		wantMin         time.Duration
+		wantMax         time.Duration
+	}{
+		{releaseInterval: "{}", wantMin: 4 * time.Hour, wantMax: 8 * time.Hour},
+		{releaseInterval: "{min: 2h, max: 3h30m}", wantMin: 2 * time.Hour, wantMax: 210 * time.Minute},
+		{releaseInterval: "{min: 10h}", wantMin: 10 * time.Hour, wantMax: 10 * time.Hour},
+		{releaseInterval: "{max: 2h}", wantMin: 2 * time.Hour, wantMax: 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		cfg, err := parse(tt.releaseInterval)
+		if err != nil {
+			t.Fatalf("Parse(%s) error = %v", tt.releaseInterval, err)
+		}
+		if minInterval, maxInterval := cfg.ReleaseInterval.Bounds(); minInterval != tt.wantMin || maxInterval != tt.wantMax {
+			t.Errorf("Parse(%s) bounds = %s, %s, want %s, %s", tt.releaseInterval, minInterval, maxInterval, tt.wantMin, tt.wantMax)
+		}
+	}
+
+	for releaseInterval, want := range map[string]string{
+		"{min: 6h, max: 3h}":  "releaseInterval min 6h is longer than max 3h",
+		"{min: 1ms, max: 1h}": "releaseInterval bounds must be at least 5m",
+		"{max: 4m}":           "releaseInterval bounds must be at least 5m",
+		"{min: -1h}":          "releaseInterval bounds must be at least 5m",
+	} {
+		if _, err := parse(releaseInterval); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%s) error = %v, want %q", releaseInterval, err, want)
+		}
+	}
+}
+
+func TestParsePools(t *testing.T) {
+	cfg, err := Parse("posted.yaml", []byte(`maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+pools:
+  - name: aws-quota
+    cleanupDelay: 20m
+  - name: gcp-quota
+jobs:
+  - name: e2e-aws
+    pool: aws-quota
+    duration: 90m
+    triggerType: release-controller
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	for pool, want := range map[string]time.Duration{"aws-quota": 20 * time.Minute, "gcp-quota": 0, DefaultPool: 0} {
+		if got := cfg.CleanupDelay(pool); got != want {
+			t.Errorf("CleanupDelay(%s) = %s, want %s", pool, got, want)
+		}
+	}
+
+	_, err = Parse("posted.yaml", []byte(`maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+pools:
+  - name: aws-quota
+    cleanupDelay: -5m
+  - name: aws-quota
+  - cleanupDelay: 5m
+jobs:
+  - name: e2e-aws
+    duration: 90m
+    triggerType: release-controller
+`))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Parse() error = %v, want a *ValidationError", err)
+	}
+	want := []string{
+		"posted.yaml:7:19: pool aws-quota: cleanupDelay must not be negative",
+		"posted.yaml:8:11: pool aws-quota: duplicate name, first defined at posted.yaml:6:11",
+		"posted.yaml:9:5: pool 2: name is required",
+	}
+	if len(validationErr.Problems) != len(want) {
+		t.Fatalf("Parse() problems = %v, want %d", validationErr, len(want))
+	}
+	for i, problem := range validationErr.Problems {
+		if problem.String() != want[i] {
+			t.Errorf("problem %d = %q, want %q", i, problem, want[i])
+		}
+	}
+}
+
+func TestParseJobPhases(t *testing.T) {
+	cfg, err := Parse("phases.yaml", []byte(`maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+pools:
+  - name: aws-quota
+    setupDuration: 40m
+    teardownDuration: 10m
+jobs:
+  - name: e2e-aws
+    pool: aws-quota
+    duration: 90m
+    triggerType: release-controller
+  - name: e2e-aws-upgrade
+    pool: aws-quota
+    duration: 2h
+    setupDuration: 1h
+    triggerType: release-controller
+  - name: e2e-gcp
+    duration: 1h
+    teardownDuration: 5m
+    triggerType: release-controller
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := map[string][2]time.Duration{
+		"e2e-aws":         {40 * time.Minute, 10 * time.Minute},
+		"e2e-aws-upgrade": {time.Hour, 10 * time.Minute},
+		"e2e-gcp":         {0, 5 * time.Minute},
+	}
+	for i := range cfg.Jobs {
+		job := &cfg.Jobs[i]
+		if setup, teardown := cfg.JobPhases(job); setup != want[job.Name][0] || teardown != want[job.Name][1] {
+			t.Errorf("JobPhases(%s) = %s, %s, want %s, %s", job.Name, setup, teardown, want[job.Name][0], want[job.Name][1])
+		}
+	}
+
+	_, err = Parse("phases.yaml", []byte(`maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+jobs:
+  - name: e2e-gcp
+    duration: 1h
+    setupDuration: -5m
+    triggerType: release-controller
+`))
+	if err == nil || !strings.Contains(err.Error(), "setupDuration must not be negative") {
+		t.Errorf("Parse() error = %v, want a negative setupDuration problem", err)
+	}
+}
+
+func TestParseCapacitySchedule(t *testing.T) {
+	cfg, err := Parse("capacity.yaml", []byte(`maxActiveLeases: 10
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+capacitySchedule:
+  - name: maintenance
+    maxActiveLeases: 6
+    cronSchedule: "0 2 * * 2"
+    duration: 4h
+    policy: preempt
+  - maxActiveLeases: 15
+    at: 2026-07-01
+jobs:
+  - name: e2e-aws
+    duration: 90m
+    triggerType: release-controller
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(cfg.CapacitySchedule) != 2 {
+		t.Fatalf("got %d capacity changes, want 2", len(cfg.CapacitySchedule))
+	}
+	maintenance, growth := cfg.CapacitySchedule[0], cfg.CapacitySchedule[1]
+	if !maintenance.IsWindow() || maintenance.Policy != CapacityPolicyPreempt || maintenance.Duration.Duration != 4*time.Hour {
+		t.Errorf("maintenance = %+v, want a 4h preempt window", maintenance)
+	}
+	if growth.IsWindow() || !growth.At.Equal(time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)) || growth.Label(1) != "1" {
+		t.Errorf("growth = %+v, want a change from 2026-07-01 on", growth)
+	}
+
+	_, err = Parse("capacity.yaml", []byte(`maxActiveLeases: 10
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+capacitySchedule:
+  - name: maintenance
+    maxActiveLeases: -1
+    cronSchedule: "0 2 * * 2"
+    policy: evict
+  - maxActiveLeases: 15
+  - maxActiveLeases: 15
+    at: 2026-07-01T00:00:00Z
+    cronSchedule: "0 0 * * *"
+jobs:
+  - name: e2e-aws
+    duration: 90m
+    triggerType: release-controller
+`))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Parse() error = %v, want a *ValidationError", err)
+	}
+	want := []string{
+		"capacity.yaml:7:22: capacity change maintenance: maxActiveLeases must not be negative",
+		"capacity.yaml:6:5: capacity change maintenance: duration is required for cronSchedule windows",
+		"capacity.yaml:9:13: capacity change maintenance: policy must be either 'drain' or 'preempt'",
+		"capacity.yaml:10:5: capacity change 1: either at or cronSchedule is required",
+		"capacity.yaml:13:19: capacity change 2: at and cronSchedule are exclusive",
+	}
+	if len(validationErr.Problems) != len(want) {
+		t.Fatalf("Parse() problems = %v, want %d", validationErr, len(want))
+	}
+	for i, problem := range validationErr.Problems {
+		if problem.String() != want[i] {
+			t.Errorf("problem %d = %q, want %q", i, problem, want[i])
+		}
+	}
+}
+
+func TestParsePreemption(t *testing.T) {
+	cfg, err := Parse("preemption.yaml", []byte(`maxActiveLeases: 10
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+preemption:
+  enabled: true
+  onPreempt: retry
+jobs:
+  - name: e2e-aws
+    duration: 90m
+    priority: 10
+    triggerType: release-controller
+  - name: e2e-aws-periodic
+    duration: 90m
+    triggerType: cron
+    cronSchedule: "0 */4 * * *"
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !cfg.Preemption.Enabled || cfg.Preemption.OnPreempt != PreemptActionRetry {
+		t.Errorf("Preemption = %+v, want enabled with retry", cfg.Preemption)
+	}
+	if cfg.Jobs[0].Priority != 10 || cfg.Jobs[1].Priority != 0 {
+		t.Errorf("priorities = %d, %d, want 10, 0", cfg.Jobs[0].Priority, cfg.Jobs[1].Priority)
+	}
+
+	_, err = Parse("preemption.yaml", []byte(`maxActiveLeases: 10
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+preemption:
+  enabled: true
+  onPreempt: requeue
+jobs:
+  - name: e2e-aws
+    duration: 90m
+    triggerType: release-controller
+`))
+	if err == nil || !strings.Contains(err.Error(), "preemption onPreempt must be either 'drop' or 'retry'") {
+		t.Errorf("Parse() error = %v, want an onPreempt problem", err)
+	}
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaDescriptions documents the configuration fields in the JSON Schema,
+// keyed by type name and yaml field name
+var schemaDescriptions = map[string]string{
+	"Config.maxActiveLeases":    "Maximum number of concurrent leases allowed",
+	"Config.reservedLeases":     "Number of the leases only release controller jobs may use",
+	"Config.jobTimeoutDuration": "Maximum duration for a job to complete",
+	"Config.leaseWaitTimeout":   "Maximum time a job can wait for an available lease",
+	"Config.simulationDuration": "Total duration to simulate",
+	"Config.releaseInterval":    "Bounds of the random time between two releases of a version, triggering its release controller jobs",
+	"Config.pools":              "Settings of the lease pools the jobs lease from",
+	"Config.capacitySchedule":   "Changes of maxActiveLeases over time, such as maintenance windows",
+	"Config.preemption":         "Eviction of running jobs by the jobs of a higher priority finding no free lease",
+	"Config.jobs":               "CI jobs to simulate",
+	"Config.include":            "Configuration files whose settings and jobs are merged into this one, relative to this file",
+	"Config.templates":          "Job definitions expanded into one job per matrix entry",
+
+	"Job.name":                "Unique identifier for the job",
+	"Job.version":             "Version of the software being tested",
+	"Job.scenario":            "Type of test scenario",
+	"Job.payloadType":         "Platform type",
+	"Job.duration":            "How long the job takes to run",
+	"Job.triggerType":         "How the job is triggered",
+	"Job.pool":                "Lease pool, such as a Boskos resource type, the job leases from (default \"default\")",
+	"Job.setupDuration":       "Time the job holds its lease to set up before running for its duration (default the one of its pool)",
+	"Job.teardownDuration":    "Time the job holds its lease to tear down after its tests, even when they timed out (default the one of its pool)",
+	"Job.cronSchedule":        "Cron expression (5 fields) for cron-type jobs",
+	"Job.movable":             "Allow the optimizer to move the cron schedule of the job",
+	"Job.allowedHours":        "Hours a movable job may run at, in cron hour field syntax",
+	"Job.isReleaseController": "Whether the job may use the leases reserved for release controller jobs",
+	"Job.priority":            "Priority of the job: with preemption enabled, jobs preempt lower priority jobs and waiting jobs acquire leases highest priority first (default 0)",
+
+	"ReleaseInterval.min": "Shortest time between two releases, at least 5m (default 4h)",
+	"ReleaseInterval.max": "Longest time between two releases, at least 5m and min (default 8h)",
+
+	"Preemption.enabled":   "Let jobs finding no free lease evict a running job of a lower priority",
+	"Preemption.onPreempt": "What happens to preempted jobs: drop gives up the run, retry triggers it again (default drop)",
+
+	"Pool.name":             "Name of the pool, as set by the pool of its jobs",
+	"Pool.cleanupDelay":     "Time a released lease stays dirty or cleaning before it is free again (default 0)",
+	"Pool.setupDuration":    "Default setupDuration of the jobs of the pool",
+	"Pool.teardownDuration": "Default teardownDuration of the jobs of the pool",
+
+	"CapacityChange.name":            "Name of the change, shown in the capacity events",
+	"CapacityChange.maxActiveLeases": "Maximum number of concurrent leases while the change applies",
+	"CapacityChange.at":              "Time the change applies from, or start of its window when it has a duration",
+	"CapacityChange.cronSchedule":    "Cron expression (5 fields) of the start of recurring windows",
+	"CapacityChange.duration":        "Length of the windows the change applies during",
+	"CapacityChange.policy":          "How jobs running when the capacity shrinks are handled: drain lets them finish, preempt stops them (default drain)",
+
+	"JobTemplate.matrix": "Versions the template is expanded for",
+
+	"MatrixEntry.version":        "Version of the generated job, replacing ${version} in its name, scenario and payload type",
+	"MatrixEntry.scheduleOffset": "Moves the runs of the cron schedule of the template later by this duration, onto the next weekdays past midnight",
+	"MatrixEntry.triggerType":    "Replaces the trigger type of the template",
+	"MatrixEntry.cronSchedule":   "Replaces the cron schedule of the template",
+}
+
+// durationPattern matches the durations accepted by ParseDuration
+const durationPattern = `^[-+]?(0|([0-9]*\.?[0-9]+(ns|us|µs|ms|s|m|h|d|w))+|P([0-9.]+W)?([0-9.]+D)?(T([0-9.]+H)?([0-9.]+M)?([0-9.]+S)?)?)$`
+
+// Schema returns a JSON Schema (draft-07) describing configuration files
+func Schema() map[string]interface{} {
+	definitions := map[string]interface{}{
+		"duration": map[string]interface{}{
+			"type":        "string",
+			"pattern":     durationPattern,
+			"description": "Duration such as 90m, 5h15m, 7d or P7D",
+		},
+		"triggerType": map[string]interface{}{
+			"type": "string",
+			"enum": []string{string(TriggerTypeCron), string(TriggerTypeReleaseController)},
+		},
+		"capacityPolicy": map[string]interface{}{
+			"type": "string",
+			"enum": []string{string(CapacityPolicyDrain), string(CapacityPolicyPreempt)},
+		},
+		"preemptAction": map[string]interface{}{
+			"type": "string",
+			"enum": []string{string(PreemptActionDrop), string(PreemptActionRetry)},
+		},
+		"timestamp": map[string]interface{}{
+			"type":        "string",
+			"description": "Time such as 2026-07-01T00:00:00Z, or date such as 2026-07-01",
+		},
+	}
+
+	job := structSchema(reflect.TypeOf(Job{}), definitions)
+	job["required"] = []string{"name", "duration", "triggerType"}
+	job["allOf"] = []interface{}{cronScheduleRequired()}
+	definitions["Job"] = job
+
+	template := structSchema(reflect.TypeOf(JobTemplate{}), definitions)
+	delete(template["properties"].(map[string]interface{}), "version")
+	template["required"] = []string{"name", "matrix"}
+	definitions["JobTemplate"] = template
+
+	entry := structSchema(reflect.TypeOf(MatrixEntry{}), definitions)
+	entry["required"] = []string{"version"}
+	definitions["MatrixEntry"] = entry
+
+	definitions["ReleaseInterval"] = structSchema(reflect.TypeOf(ReleaseInterval{}), definitions)
+
+	pool := structSchema(reflect.TypeOf(Pool{}), definitions)
+	pool["required"] = []string{"name"}
+	definitions["Pool"] = pool
+
+	definitions["Preemption"] = structSchema(reflect.TypeOf(Preemption{}), definitions)
+
+	change := structSchema(reflect.TypeOf(CapacityChange{}), definitions)
+	change["required"] = []string{"maxActiveLeases"}
+	definitions["CapacityChange"] = change
+
+	schema := structSchema(reflect.TypeOf(Config{}), definitions)
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "CI Job Lease Simulator configuration"
+	schema["definitions"] = definitions
+	// Settings may come from included files
+	schema["if"] = map[string]interface{}{"not": map[string]interface{}{"required": []string{"include"}}}
+	schema["then"] = map[string]interface{}{"required": []string{"maxActiveLeases", "jobTimeoutDuration", "leaseWaitTimeout", "simulationDuration"}}
+
+	return schema
+}
+
+// cronScheduleRequired requires cronSchedule on cron-type jobs
+func cronScheduleRequired() map[string]interface{} {
+	return map[string]interface{}{
+		"if": map[string]interface{}{
+			"properties": map[string]interface{}{"triggerType": map[string]interface{}{"const": string(TriggerTypeCron)}},
+			"required":   []string{"triggerType"},
+		},
+		"then": map[string]interface{}{"required": []string{"cronSchedule"}},
+	}
+}
+
+// structSchema returns the object schema of a configuration struct from its yaml tags
+func structSchema(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	addStructProperties(t, t.Name(), properties, definitions)
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// addStructProperties adds the properties of the exported fields of a struct,
+// flattening inline fields
+func addStructProperties(t reflect.Type, typeName string, properties, definitions map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if options == "inline" {
+			addStructProperties(field.Type, field.Type.Name(), properties, definitions)
+			continue
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+
+		property := typeSchema(field.Type, definitions)
+		if description, ok := schemaDescriptions[typeName+"."+name]; ok {
+			property["description"] = description
+		}
+		properties[name] = property
+	}
+}
+
+// typeSchema returns the schema of a field type
+func typeSchema(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	switch t {
+	case reflect.TypeOf(Duration{}):
+		return map[string]interface{}{"$ref": "#/definitions/duration"}
+	case reflect.TypeOf(TriggerType("")):
+		return map[string]interface{}{"$ref": "#/definitions/triggerType"}
+	case reflect.TypeOf(CapacityPolicy("")):
+		return map[string]interface{}{"$ref": "#/definitions/capacityPolicy"}
+	case reflect.TypeOf(PreemptAction("")):
+		return map[string]interface{}{"$ref": "#/definitions/preemptAction"}
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"$ref": "#/definitions/timestamp"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), definitions)}
+	case reflect.Struct:
+		return map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+	}
+
+	panic(fmt.Sprintf("no JSON Schema for configuration field type %s", t))
+}
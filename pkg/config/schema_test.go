@@ -0,0 +1,258 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaValidator validates documents against the subset of JSON Schema
+// draft-07 that Schema generates
+type schemaValidator struct {
+	root map[string]interface{}
+}
+
+// validate returns the violations of a value against a schema, at path
+func (v schemaValidator) validate(schema map[string]interface{}, value interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/definitions/")
+		definition, ok := v.root["definitions"].(map[string]interface{})[name].(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: unknown $ref %s", path, ref)}
+		}
+		return v.validate(definition, value, path)
+	}
+
+	violations := []string{}
+	if not, ok := schema["not"].(map[string]interface{}); ok && len(v.validate(not, value, path)) == 0 {
+		violations = append(violations, fmt.Sprintf("%s: matches a forbidden schema", path))
+	}
+	if ifSchema, ok := schema["if"].(map[string]interface{}); ok && len(v.validate(ifSchema, value, path)) == 0 {
+		violations = append(violations, v.validate(schema["then"].(map[string]interface{}), value, path)...)
+	}
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			violations = append(violations, v.validate(sub.(map[string]interface{}), value, path)...)
+		}
+	}
+	if constant, ok := schema["const"]; ok && value != constant {
+		violations = append(violations, fmt.Sprintf("%s: %v is not %v", path, value, constant))
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			found = found || value == allowed
+		}
+		if !found {
+			violations = append(violations, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+		}
+	}
+
+	// Object and array keywords apply to values of their type, whether or
+	// not the schema has a type
+	if object, ok := value.(map[string]interface{}); ok {
+		properties, _ := schema["properties"].(map[string]interface{})
+		for key, field := range object {
+			property, ok := properties[key].(map[string]interface{})
+			if !ok {
+				if schema["additionalProperties"] == false {
+					violations = append(violations, fmt.Sprintf("%s: unknown property %s", path, key))
+				}
+				continue
+			}
+			violations = append(violations, v.validate(property, field, path+"."+key)...)
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, key := range required {
+			if _, ok := object[key.(string)]; !ok {
+				violations = append(violations, fmt.Sprintf("%s: missing property %s", path, key))
+			}
+		}
+	}
+	if array, ok := value.([]interface{}); ok {
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range array {
+				violations = append(violations, v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	if s, ok := value.(string); ok {
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+			violations = append(violations, fmt.Sprintf("%s: %q does not match %s", path, s, pattern))
+		}
+	}
+
+	valid := true
+	switch schema["type"] {
+	case "object":
+		_, valid = value.(map[string]interface{})
+	case "array":
+		_, valid = value.([]interface{})
+	case "string":
+		_, valid = value.(string)
+	case "integer":
+		n, ok := value.(float64)
+		valid = ok && n == float64(int64(n))
+	case "number":
+		_, valid = value.(float64)
+	case "boolean":
+		_, valid = value.(bool)
+	case nil:
+	default:
+		violations = append(violations, fmt.Sprintf("%s: unknown type %v", path, schema["type"]))
+	}
+	if !valid {
+		violations = append(violations, fmt.Sprintf("%s: %v is not of type %s", path, value, schema["type"]))
+	}
+
+	return violations
+}
+
+// validateYAML validates a YAML document against the schema, converting it
+// to JSON like the editors using the schema do
+func validateYAML(t *testing.T, root map[string]interface{}, data []byte) []string {
+	t.Helper()
+
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		t.Fatalf("failed to parse YAML: %v", err)
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		t.Fatalf("failed to convert YAML to JSON: %v", err)
+	}
+	var value interface{}
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		t.Fatal(err)
+	}
+
+	return schemaValidator{root: root}.validate(root, value, "$")
+}
+
+// generatedSchema returns the schema as it is printed, in its JSON form
+func generatedSchema(t *testing.T) map[string]interface{} {
+	t.Helper()
+
+	encoded, err := json.Marshal(Schema())
+	if err != nil {
+		t.Fatalf("failed to encode the schema: %v", err)
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(encoded, &root); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestSchemaSampleConfigs(t *testing.T) {
+	root := generatedSchema(t)
+
+	files, err := filepath.Glob("../../*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no sample configurations found")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Parse(file, data); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if violations := validateYAML(t, root, data); len(violations) > 0 {
+				t.Errorf("valid configuration rejected by the schema:\n%s", strings.Join(violations, "\n"))
+			}
+		})
+	}
+}
+
+func TestSchemaRejects(t *testing.T) {
+	root := generatedSchema(t)
+	header := `maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+`
+
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name:   "missing settings",
+			config: "jobs: []\n",
+			want:   "$: missing property maxActiveLeases",
+		},
+		{
+			name: "unknown job field",
+			config: header + `jobs:
+  - name: e2e
+    duration: 1h
+    triggerType: release-controller
+    schedule: daily
+`,
+			want: "$.jobs[0]: unknown property schedule",
+		},
+		{
+			name: "cron job without schedule",
+			config: header + `jobs:
+  - name: e2e
+    duration: 1h
+    triggerType: cron
+`,
+			want: "$.jobs[0]: missing property cronSchedule",
+		},
+		{
+			name: "invalid duration",
+			config: header + `jobs:
+  - name: e2e
+    duration: an hour
+    triggerType: release-controller
+`,
+			want: `$.jobs[0].duration: "an hour" does not match`,
+		},
+		{
+			name: "invalid trigger type",
+			config: header + `jobs:
+  - name: e2e
+    duration: 1h
+    triggerType: manual
+`,
+			want: "$.jobs[0].triggerType: manual is not one of",
+		},
+		{
+			name: "invalid priority",
+			config: header + `jobs:
+  - name: e2e
+    duration: 1h
+    triggerType: release-controller
+    priority: high
+`,
+			want: "$.jobs[0].priority: high is not of type integer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := validateYAML(t, root, []byte(tt.config))
+			for _, violation := range violations {
+				if strings.HasPrefix(violation, tt.want) {
+					return
+				}
+			}
+			t.Errorf("violations = %q, want %q", violations, tt.want)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// JobState is a state of the lifecycle of a job instance
+type JobState string
+
+const (
+	// JobStatePending is the state of a job instance not yet triggered
+	JobStatePending JobState = "pending"
+	// JobStateWaiting is the state of a triggered job instance waiting for a lease
+	JobStateWaiting JobState = "waiting"
+	// JobStateRunning is the state of a job instance holding a lease
+	JobStateRunning JobState = "running"
+	// JobStateTearingDown is the state of a job instance still holding its
+	// lease to tear down after its tests ended or timed out
+	JobStateTearingDown JobState = "tearing-down"
+	// JobStateCompleted is the final state of a job instance that ran to completion
+	JobStateCompleted JobState = "completed"
+	// JobStateWaitTimeout is the final state of a job instance that gave up waiting for a lease
+	JobStateWaitTimeout JobState = "wait-timeout"
+	// JobStateExecTimeout is the final state of a job instance that exceeded the job timeout
+	JobStateExecTimeout JobState = "exec-timeout"
+	// JobStatePreempted is the final state of a job instance stopped to free its lease
+	JobStatePreempted JobState = "preempted"
+)
+
+// jobTransitions lists the states each state may move to
+var jobTransitions = map[JobState][]JobState{
+	JobStatePending:     {JobStateWaiting, JobStateRunning},
+	JobStateWaiting:     {JobStateRunning, JobStateWaitTimeout},
+	JobStateRunning:     {JobStateCompleted, JobStateExecTimeout, JobStateTearingDown, JobStatePreempted},
+	JobStateTearingDown: {JobStateCompleted, JobStateExecTimeout, JobStatePreempted},
+}
+
+// IsFinal reports whether no transition leaves the state
+func (s JobState) IsFinal() bool {
+	return len(jobTransitions[s]) == 0
+}
+
+// CurrentState returns the state of the instance, pending until its first transition
+func (i *JobInstance) CurrentState() JobState {
+	if i.State == "" {
+		return JobStatePending
+	}
+	return i.State
+}
+
+// Transition moves the instance to a new state at the given time, recording
+// when the state was entered and updating the lease and timeout fields
+func (i *JobInstance) Transition(state JobState, at time.Time) error {
+	from := i.CurrentState()
+
+	allowed := false
+	for _, next := range jobTransitions[from] {
+		if next == state {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("job %s: invalid transition from %s to %s", i.Job.Name, from, state)
+	}
+
+	if i.StateTimes == nil {
+		i.StateTimes = make(map[JobState]time.Time)
+	}
+	i.State = state
+	i.StateTimes[state] = at
+
+	switch state {
+	case JobStateRunning:
+		i.LeaseAcquired = true
+		i.LeaseAcquiredTime = at
+		i.StartTime = at
+		i.EndTime = at.Add(i.SetupDuration + i.Job.Duration.Duration)
+		if waitingSince, ok := i.StateTimes[JobStateWaiting]; ok {
+			i.LeaseWaitTime = at.Sub(waitingSince)
+		}
+	case JobStateWaitTimeout:
+		i.TimedOut = true
+		i.LeaseWaitTime = at.Sub(i.StateTimes[JobStateWaiting])
+	case JobStateTearingDown:
+		// Tearing down before the end of the tests means they timed out
+		if at.Before(i.EndTime) {
+			i.TimedOut = true
+			i.EndTime = at
+		}
+	case JobStateExecTimeout:
+		i.TimedOut = true
+		if from == JobStateRunning {
+			i.EndTime = at
+		}
+	case JobStatePreempted:
+		if at.Before(i.EndTime) {
+			i.EndTime = at
+		}
+	}
+
+	if state.IsFinal() {
+		i.FinishedTime = at
+		i.Outcome = state
+	}
+
+	return nil
+}
+
+// ScheduleSlip returns how late the job acquired its lease compared to when it
+// was triggered, and false if it never acquired one
+func (i *JobInstance) ScheduleSlip() (time.Duration, bool) {
+	if i.LeaseAcquiredTime.IsZero() {
+		return 0, false
+	}
+	return i.LeaseAcquiredTime.Sub(i.ScheduledTime), true
+}
+
+// Since returns how long the instance has been in its current state at the given time
+func (i *JobInstance) Since(at time.Time) time.Duration {
+	entered, ok := i.StateTimes[i.CurrentState()]
+	if !ok {
+		return 0
+	}
+	return at.Sub(entered)
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJobInstanceTransition(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		path    []JobState
+		wantErr bool
+	}{
+		{name: "pending to running", path: []JobState{JobStateRunning}},
+		{name: "pending to waiting", path: []JobState{JobStateWaiting}},
+		{name: "waiting to running", path: []JobState{JobStateWaiting, JobStateRunning}},
+		{name: "waiting to wait-timeout", path: []JobState{JobStateWaiting, JobStateWaitTimeout}},
+		{name: "running to completed", path: []JobState{JobStateRunning, JobStateCompleted}},
+		{name: "running to exec-timeout", path: []JobState{JobStateRunning, JobStateExecTimeout}},
+		{name: "running to tearing-down", path: []JobState{JobStateRunning, JobStateTearingDown}},
+		{name: "tearing-down to completed", path: []JobState{JobStateRunning, JobStateTearingDown, JobStateCompleted}},
+		{name: "tearing-down to exec-timeout", path: []JobState{JobStateRunning, JobStateTearingDown, JobStateExecTimeout}},
+		{name: "running to preempted", path: []JobState{JobStateRunning, JobStatePreempted}},
+		{name: "tearing-down to preempted", path: []JobState{JobStateRunning, JobStateTearingDown, JobStatePreempted}},
+		{name: "pending to completed", path: []JobState{JobStateCompleted}, wantErr: true},
+		{name: "waiting to preempted", path: []JobState{JobStateWaiting, JobStatePreempted}, wantErr: true},
+		{name: "waiting to tearing-down", path: []JobState{JobStateWaiting, JobStateTearingDown}, wantErr: true},
+		{name: "waiting to exec-timeout", path: []JobState{JobStateWaiting, JobStateExecTimeout}, wantErr: true},
+		{name: "running to wait-timeout", path: []JobState{JobStateRunning, JobStateWaitTimeout}, wantErr: true},
+		{name: "completed is final", path: []JobState{JobStateRunning, JobStateCompleted, JobStateRunning}, wantErr: true},
+		{name: "wait-timeout is final", path: []JobState{JobStateWaiting, JobStateWaitTimeout, JobStateRunning}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := &JobInstance{Job: &Job{Name: "job", Duration: Duration{Duration: time.Hour}}}
+
+			var err error
+			for i, state := range tt.path {
+				if err = instance.Transition(state, start.Add(time.Duration(i)*time.Hour)); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			last := tt.path[len(tt.path)-1]
+			if instance.State != last {
+				t.Errorf("State = %s, want %s", instance.State, last)
+			}
+			if got, want := instance.StateTimes[last], start.Add(time.Duration(len(tt.path)-1)*time.Hour); !got.Equal(want) {
+				t.Errorf("StateTimes[%s] = %s, want %s", last, got, want)
+			}
+		})
+	}
+}
+
+func TestJobInstanceTransitionFields(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	instance := &JobInstance{Job: &Job{Name: "job", Duration: Duration{Duration: time.Hour}}}
+
+	if err := instance.Transition(JobStateWaiting, start); err != nil {
+		t.Fatal(err)
+	}
+	if err := instance.Transition(JobStateRunning, start.Add(30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !instance.LeaseAcquired {
+		t.Error("LeaseAcquired = false, want true")
+	}
+	if instance.LeaseWaitTime != 30*time.Minute {
+		t.Errorf("LeaseWaitTime = %s, want 30m", instance.LeaseWaitTime)
+	}
+	if want := start.Add(90 * time.Minute); !instance.EndTime.Equal(want) {
+		t.Errorf("EndTime = %s, want %s", instance.EndTime, want)
+	}
+	if since := instance.Since(start.Add(time.Hour)); since != 30*time.Minute {
+		t.Errorf("Since() = %s, want 30m", since)
+	}
+}
+
+func TestJobInstanceTransitionPhases(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	instance := &JobInstance{
+		Job:              &Job{Name: "job", Duration: Duration{Duration: time.Hour}},
+		SetupDuration:    30 * time.Minute,
+		TeardownDuration: 15 * time.Minute,
+	}
+
+	if err := instance.Transition(JobStateRunning, start); err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Add(90 * time.Minute); !instance.EndTime.Equal(want) {
+		t.Errorf("EndTime = %s, want %s after setup and tests", instance.EndTime, want)
+	}
+
+	// Tearing down before the end of the tests means the run timed out
+	if err := instance.Transition(JobStateTearingDown, start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if !instance.TimedOut {
+		t.Error("TimedOut = false, want true")
+	}
+	if want := start.Add(time.Hour); !instance.EndTime.Equal(want) {
+		t.Errorf("EndTime = %s, want %s", instance.EndTime, want)
+	}
+	if err := instance.Transition(JobStateExecTimeout, start.Add(75*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Add(time.Hour); !instance.EndTime.Equal(want) {
+		t.Errorf("EndTime = %s, want %s kept through the teardown", instance.EndTime, want)
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/schedule"
+)
+
+// expandTemplates returns the jobs generated by the job templates, and the
+// problems of the templates that could not be expanded
+func expandTemplates(templates []JobTemplate) ([]Job, []Problem) {
+	jobs := []Job{}
+	problems := []Problem{}
+
+	for i, template := range templates {
+		if template.Version != "" {
+			problems = append(problems, Problem{
+				Position: template.Position("version"),
+				Message:  fmt.Sprintf("template %d (%s): version must be set in the matrix, not in the template", i, template.Name),
+			})
+			continue
+		}
+		if len(template.Matrix) == 0 {
+			problems = append(problems, Problem{
+				Position: template.Position(""),
+				Message:  fmt.Sprintf("template %d (%s): matrix must list at least one version", i, template.Name),
+			})
+			continue
+		}
+
+		for _, entry := range template.Matrix {
+			job, err := expandTemplate(template, entry)
+			if err != nil {
+				problems = append(problems, Problem{
+					Position: template.Position("matrix"),
+					Message:  fmt.Sprintf("template %d (%s), version %s: %v", i, template.Name, entry.Version, err),
+				})
+				continue
+			}
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs, problems
+}
+
+// expandTemplate generates the job of a template for one matrix entry
+func expandTemplate(template JobTemplate, entry MatrixEntry) (Job, error) {
+	if entry.Version == "" {
+		return Job{}, fmt.Errorf("matrix entry without version")
+	}
+
+	job := template.Job
+	job.Version = entry.Version
+	job.Name = strings.ReplaceAll(job.Name, VersionPlaceholder, entry.Version)
+	job.Scenario = strings.ReplaceAll(job.Scenario, VersionPlaceholder, entry.Version)
+	job.PayloadType = strings.ReplaceAll(job.PayloadType, VersionPlaceholder, entry.Version)
+
+	if entry.TriggerType != "" {
+		job.TriggerType = entry.TriggerType
+	}
+	if entry.CronSchedule != "" {
+		job.CronSchedule = entry.CronSchedule
+	}
+
+	if entry.ScheduleOffset.Duration != 0 {
+		if job.TriggerType != TriggerTypeCron {
+			return Job{}, fmt.Errorf("scheduleOffset only applies to cron-type jobs")
+		}
+		if entry.ScheduleOffset.Duration%time.Minute != 0 {
+			return Job{}, fmt.Errorf("scheduleOffset must be a whole number of minutes")
+		}
+
+		expr, err := schedule.Parse(job.CronSchedule)
+		if err != nil {
+			return Job{}, fmt.Errorf("invalid cron schedule: %w", err)
+		}
+		shifted, err := expr.ShiftMinutes(int(entry.ScheduleOffset.Duration / time.Minute))
+		if err != nil {
+			return Job{}, err
+		}
+		job.CronSchedule = shifted.String()
+	}
+
+	return job, nil
+}
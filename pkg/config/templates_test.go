@@ -0,0 +1,334 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes configuration files into a temporary directory and
+// returns the directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const commonConfig = `maxActiveLeases: 10
+reservedLeases: 2
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+releaseInterval:
+  min: 2h
+  max: 6h
+preemption:
+  enabled: true
+  onPreempt: retry
+pools:
+  - name: aws
+    cleanupDelay: 10m
+  - name: gcp
+    cleanupDelay: 5m
+jobs:
+  - name: common-e2e
+    duration: 1h
+    triggerType: release-controller
+`
+
+func TestLoadConfigIncludes(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		check  func(cfg *Config) bool
+		jobs   []string
+		nFiles int
+	}{
+		{
+			name: "settings of the including file win",
+			files: map[string]string{
+				"base/common.yaml": commonConfig,
+				"config.yaml": `include: [base/common.yaml]
+maxActiveLeases: 14
+pools:
+  - name: aws
+    cleanupDelay: 30m
+jobs:
+  - name: e2e
+    duration: 1h
+    triggerType: release-controller
+`,
+			},
+			check: func(cfg *Config) bool {
+				return cfg.MaxActiveLeases == 14 && cfg.ReservedLeases == 2 && cfg.LeaseWaitTimeout.String() == "2h" &&
+					len(cfg.Pools) == 2 && cfg.Pools[0].CleanupDelay.String() == "30m" && cfg.Pools[1].CleanupDelay.String() == "5m"
+			},
+			jobs:   []string{"common-e2e", "e2e"},
+			nFiles: 2,
+		},
+		{
+			name: "zero values of the including file win",
+			files: map[string]string{
+				"common.yaml": commonConfig,
+				"config.yaml": `include: [common.yaml]
+reservedLeases: 0
+preemption:
+  enabled: false
+`,
+			},
+			check: func(cfg *Config) bool {
+				return cfg.ReservedLeases == 0 && !cfg.Preemption.Enabled && cfg.Preemption.OnPreempt == PreemptActionRetry &&
+					cfg.ReleaseInterval.Min.String() == "2h"
+			},
+			jobs:   []string{"common-e2e"},
+			nFiles: 2,
+		},
+		{
+			name: "nested includes relative to their file",
+			files: map[string]string{
+				"base/common.yaml": commonConfig,
+				"teams/team.yaml": `include: [../base/common.yaml]
+releaseInterval:
+  max: 12h
+jobs:
+  - name: team-e2e
+    duration: 1h
+    triggerType: release-controller
+`,
+				"config.yaml": `include: [teams/team.yaml]
+jobs:
+  - name: e2e
+    duration: 1h
+    triggerType: release-controller
+`,
+			},
+			check: func(cfg *Config) bool {
+				return cfg.ReleaseInterval.Min.String() == "2h" && cfg.ReleaseInterval.Max.String() == "12h"
+			},
+			jobs:   []string{"common-e2e", "team-e2e", "e2e"},
+			nFiles: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			cfg, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+
+			jobs := []string{}
+			for _, job := range cfg.Jobs {
+				jobs = append(jobs, job.Name)
+			}
+			if !reflect.DeepEqual(jobs, tt.jobs) {
+				t.Errorf("jobs = %v, want %v", jobs, tt.jobs)
+			}
+			if len(cfg.Files()) != tt.nFiles {
+				t.Errorf("Files() = %v, want %d files", cfg.Files(), tt.nFiles)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("settings = %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestLoadConfigIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"config.yaml": "include: [a.yaml]\n",
+				"a.yaml":      "include: [b.yaml]\n",
+				"b.yaml":      "include: [a.yaml]\n",
+			},
+			wantErr: "include cycle detected",
+		},
+		{
+			name: "self include",
+			files: map[string]string{
+				"config.yaml": "include: [config.yaml]\n",
+			},
+			wantErr: "include cycle detected",
+		},
+		{
+			name: "missing file",
+			files: map[string]string{
+				"config.yaml": "include: [missing.yaml]\n",
+			},
+			wantErr: "failed to read config file",
+		},
+		{
+			name: "problem in an included file",
+			files: map[string]string{
+				"common.yaml": strings.Replace(commonConfig, "jobTimeoutDuration: 4h", "jobTimeoutDuration: 0s", 1),
+				"config.yaml": "include: [common.yaml]\n",
+			},
+			wantErr: "common.yaml:3:21: jobTimeoutDuration must be greater than 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			_, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpandTemplates(t *testing.T) {
+	cfg, err := Parse("templates.yaml", []byte(`maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+templates:
+  - name: "ocp-${version}-fips"
+    scenario: "fips-${version}"
+    payloadType: "${version}-multi"
+    duration: 2h
+    triggerType: cron
+    cronSchedule: "0 4 * * 1-4"
+    matrix:
+      - version: "4.19"
+      - version: "4.18"
+        scheduleOffset: 2h30m
+      - version: "4.17"
+        scheduleOffset: 20h
+      - version: "4.16"
+        cronSchedule: "0 12 * * *"
+      - version: "4.21"
+        triggerType: release-controller
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []struct {
+		name, scenario, payloadType string
+		triggerType                 TriggerType
+		cronSchedule                string
+	}{
+		{"ocp-4.19-fips", "fips-4.19", "4.19-multi", TriggerTypeCron, "0 4 * * 1-4"},
+		{"ocp-4.18-fips", "fips-4.18", "4.18-multi", TriggerTypeCron, "30 6 * * 1-4"},
+		// Moved past midnight onto the next weekdays
+		{"ocp-4.17-fips", "fips-4.17", "4.17-multi", TriggerTypeCron, "0 0 * * 2-5"},
+		{"ocp-4.16-fips", "fips-4.16", "4.16-multi", TriggerTypeCron, "0 12 * * *"},
+		{"ocp-4.21-fips", "fips-4.21", "4.21-multi", TriggerTypeReleaseController, "0 4 * * 1-4"},
+	}
+	if len(cfg.Jobs) != len(want) {
+		t.Fatalf("got %d jobs, want %d", len(cfg.Jobs), len(want))
+	}
+	for i, w := range want {
+		job := cfg.Jobs[i]
+		if job.Name != w.name || job.Scenario != w.scenario || job.PayloadType != w.payloadType ||
+			job.TriggerType != w.triggerType || job.CronSchedule != w.cronSchedule {
+			t.Errorf("job %d = %s %s %s %s %q, want %+v", i, job.Name, job.Scenario, job.PayloadType, job.TriggerType, job.CronSchedule, w)
+		}
+		if job.Duration.String() != "2h" {
+			t.Errorf("job %s: duration %s, want the 2h of the template", job.Name, job.Duration)
+		}
+	}
+}
+
+func TestExpandTemplatesProblems(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			name: "version in the template",
+			template: `  - name: "e2e-${version}"
+    version: "4.19"
+    duration: 1h
+    triggerType: release-controller
+    matrix:
+      - version: "4.19"
+`,
+			want: "template 0 (e2e-${version}): version must be set in the matrix, not in the template",
+		},
+		{
+			name: "empty matrix",
+			template: `  - name: "e2e-${version}"
+    duration: 1h
+    triggerType: release-controller
+    matrix: []
+`,
+			want: "template 0 (e2e-${version}): matrix must list at least one version",
+		},
+		{
+			name: "offset of a release controller job",
+			template: `  - name: "e2e-${version}"
+    duration: 1h
+    triggerType: release-controller
+    matrix:
+      - version: "4.19"
+        scheduleOffset: 1h
+`,
+			want: "template 0 (e2e-${version}), version 4.19: scheduleOffset only applies to cron-type jobs",
+		},
+		{
+			name: "offset moving runs to another day of month",
+			template: `  - name: "e2e-${version}"
+    duration: 1h
+    triggerType: cron
+    cronSchedule: "0 22 1 * *"
+    matrix:
+      - version: "4.19"
+        scheduleOffset: 4h
+`,
+			want: `template 0 (e2e-${version}), version 4.19: cannot shift "0 22 1 * *" by 240 minutes: its runs would move to another day of month`,
+		},
+		{
+			name: "offset of a schedule firing at several minutes",
+			template: `  - name: "e2e-${version}"
+    duration: 1h
+    triggerType: cron
+    cronSchedule: "*/30 2 * * *"
+    matrix:
+      - version: "4.19"
+        scheduleOffset: 15m
+`,
+			want: "minute field is not a single value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("templates.yaml", []byte(`maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+jobs:
+  - name: e2e
+    duration: 1h
+    triggerType: release-controller
+templates:
+`+tt.template))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,35 +1,267 @@
 package config
 
 import (
+	"fmt"
 	"time"
 )
 
 // Config represents the entire configuration for the lease simulator
 type Config struct {
-	MaxActiveLeases      int           `yaml:"maxActiveLeases"`
-	JobTimeoutDuration   time.Duration `yaml:"jobTimeoutDuration"`
-	LeaseWaitTimeout     time.Duration `yaml:"leaseWaitTimeout"`
-	SimulationDuration   time.Duration `yaml:"simulationDuration"`
-	Jobs                 []Job         `yaml:"jobs"`
+	MaxActiveLeases    int              `yaml:"maxActiveLeases"`
+	ReservedLeases     int              `yaml:"reservedLeases,omitempty"`
+	JobTimeoutDuration Duration         `yaml:"jobTimeoutDuration"`
+	LeaseWaitTimeout   Duration         `yaml:"leaseWaitTimeout"`
+	SimulationDuration Duration         `yaml:"simulationDuration"`
+	ReleaseInterval    ReleaseInterval  `yaml:"releaseInterval,omitempty"`
+	Pools              []Pool           `yaml:"pools,omitempty"`
+	CapacitySchedule   []CapacityChange `yaml:"capacitySchedule,omitempty"`
+	Preemption         Preemption       `yaml:"preemption,omitempty"`
+	Jobs               []Job            `yaml:"jobs"`
+
+	// Other configuration files whose settings and jobs are merged into this one
+	Include []string `yaml:"include,omitempty"`
+	// Job definitions expanded across a list of versions
+	Templates []JobTemplate `yaml:"templates,omitempty"`
+
+	// files lists the configuration files loaded, including the included ones
+	files []string
+	// positions holds where each setting was set, and the file under the "" key
+	positions map[string]Position
+	// problems found while loading, reported by validation
+	problems []Problem
+}
+
+// Default bounds of the interval between two releases of a version
+const (
+	DefaultReleaseIntervalMin = 4 * time.Hour
+	DefaultReleaseIntervalMax = 8 * time.Hour
+)
+
+// MinReleaseInterval is the shortest interval between two releases of a
+// version accepted, one step of the simulation
+const MinReleaseInterval = 5 * time.Minute
+
+// ReleaseInterval bounds the random time between two releases of a version,
+// each release triggering the release controller jobs of the version
+type ReleaseInterval struct {
+	Min Duration `yaml:"min,omitempty"`
+	Max Duration `yaml:"max,omitempty"`
+}
+
+// Bounds returns the shortest and longest interval between two releases.
+// Unset bounds default to DefaultReleaseIntervalMin and
+// DefaultReleaseIntervalMax, widened to include the bound that is set.
+func (r ReleaseInterval) Bounds() (time.Duration, time.Duration) {
+	minInterval, maxInterval := r.Min.Duration, r.Max.Duration
+	if minInterval == 0 {
+		minInterval = DefaultReleaseIntervalMin
+		if maxInterval != 0 {
+			minInterval = min(minInterval, maxInterval)
+		}
+	}
+	if maxInterval == 0 {
+		maxInterval = max(DefaultReleaseIntervalMax, minInterval)
+	}
+	return minInterval, maxInterval
+}
+
+// Files returns the configuration files the configuration was loaded from,
+// the main file first followed by the included ones
+func (c *Config) Files() []string {
+	return c.files
 }
 
 // Job represents a single CI job
 type Job struct {
-	Name        string        `yaml:"name"`
-	Version     string        `yaml:"version"`
-	Scenario    string        `yaml:"scenario"`
-	PayloadType string        `yaml:"payloadType"`
-	Duration    time.Duration `yaml:"duration"`
-	TriggerType TriggerType   `yaml:"triggerType"`
+	Name        string      `yaml:"name"`
+	Version     string      `yaml:"version"`
+	Scenario    string      `yaml:"scenario"`
+	PayloadType string      `yaml:"payloadType"`
+	Duration    Duration    `yaml:"duration"`
+	TriggerType TriggerType `yaml:"triggerType"`
+
+	// Pool is the lease pool, such as a Boskos resource type, the job leases
+	// from. Jobs without a pool are in DefaultPool.
+	Pool string `yaml:"pool,omitempty"`
+
+	// SetupDuration and TeardownDuration extend the time the job holds its
+	// lease before and after Duration, replacing the ones of its pool when set
+	SetupDuration    Duration `yaml:"setupDuration,omitempty"`
+	TeardownDuration Duration `yaml:"teardownDuration,omitempty"`
 
 	// For cron-based jobs
 	CronSchedule string `yaml:"cronSchedule,omitempty"`
 
+	// Movable cron jobs may have their schedule moved by the optimizer,
+	// keeping their frequency and weekdays. AllowedHours optionally restricts
+	// the hours they may run at, using the cron hour field syntax (e.g. "0-6,20-23").
+	Movable      bool   `yaml:"movable,omitempty"`
+	AllowedHours string `yaml:"allowedHours,omitempty"`
+
 	// For release controller jobs
-	// These are considered as "always reserved" leases
+	// Release controller jobs may use the leases reserved by ReservedLeases,
+	// which other jobs cannot. It defaults to true for release-controller
+	// triggered jobs and cannot be false for them; set on a cron job, it marks
+	// a cron fallback of a release controller job.
 	IsReleaseController bool `yaml:"isReleaseController,omitempty"`
+
+	// Priority lets jobs evict the running jobs of a lower priority, and
+	// orders the jobs waiting for a lease, the highest first, when preemption
+	// is enabled (default 0)
+	Priority int `yaml:"priority,omitempty"`
+
+	// positions holds where each field was set, and the job itself under the "" key
+	positions map[string]Position
+}
+
+// DefaultPool is the pool of the jobs that do not set one
+const DefaultPool = "default"
+
+// Pool holds the settings of a lease pool. Like Boskos, the simulator marks a
+// released lease dirty and only frees it once the janitor has cleaned it up,
+// CleanupDelay after its release.
+type Pool struct {
+	Name         string   `yaml:"name"`
+	CleanupDelay Duration `yaml:"cleanupDelay,omitempty"`
+
+	// SetupDuration and TeardownDuration are the defaults of the jobs of the pool
+	SetupDuration    Duration `yaml:"setupDuration,omitempty"`
+	TeardownDuration Duration `yaml:"teardownDuration,omitempty"`
+
+	// positions holds where each field was set, and the pool itself under the "" key
+	positions map[string]Position
 }
 
+// CleanupDelay returns the time a lease of the pool stays dirty or cleaning
+// after its release, 0 for the pools without settings
+func (c *Config) CleanupDelay(pool string) time.Duration {
+	return c.pool(pool).CleanupDelay.Duration
+}
+
+// JobPhases returns how long a job holds its lease to set up before running
+// for its Duration, and to tear down after, defaulting to the ones of its pool
+func (c *Config) JobPhases(job *Job) (setup, teardown time.Duration) {
+	pool := c.pool(job.PoolName())
+	setup, teardown = job.SetupDuration.Duration, job.TeardownDuration.Duration
+	if setup == 0 {
+		setup = pool.SetupDuration.Duration
+	}
+	if teardown == 0 {
+		teardown = pool.TeardownDuration.Duration
+	}
+	return setup, teardown
+}
+
+// pool returns the settings of a pool, empty for the pools without settings
+func (c *Config) pool(name string) Pool {
+	for _, p := range c.Pools {
+		if p.Name == name {
+			return p
+		}
+	}
+	return Pool{Name: name}
+}
+
+// Preemption lets jobs that find no free lease evict a running job of a lower
+// priority and take its lease
+type Preemption struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// OnPreempt is what happens to the preempted jobs, including the ones
+	// preempted by the capacity schedule, PreemptActionDrop by default
+	OnPreempt PreemptAction `yaml:"onPreempt,omitempty"`
+}
+
+// PreemptAction defines what happens to a preempted job
+type PreemptAction string
+
+const (
+	// PreemptActionDrop gives up the preempted run
+	PreemptActionDrop PreemptAction = "drop"
+	// PreemptActionRetry triggers the job again, waiting for a lease
+	PreemptActionRetry PreemptAction = "retry"
+)
+
+// CapacityChange changes MaxActiveLeases from a point in time on, or during
+// windows lasting Duration: a one-off window starting At, or recurring windows
+// starting at every CronSchedule time.
+type CapacityChange struct {
+	Name            string    `yaml:"name,omitempty"`
+	MaxActiveLeases int       `yaml:"maxActiveLeases"`
+	At              time.Time `yaml:"at,omitempty"`
+	CronSchedule    string    `yaml:"cronSchedule,omitempty"`
+	Duration        Duration  `yaml:"duration,omitempty"`
+
+	// Policy is how the jobs running when the capacity shrinks below the
+	// leases in use are handled, CapacityPolicyDrain by default
+	Policy CapacityPolicy `yaml:"policy,omitempty"`
+
+	// positions holds where each field was set, and the change itself under the "" key
+	positions map[string]Position
+}
+
+// CapacityPolicy defines how running jobs are handled when the capacity shrinks
+type CapacityPolicy string
+
+const (
+	// CapacityPolicyDrain lets the running jobs finish, no job acquiring a
+	// lease until the leases in use fit the new capacity
+	CapacityPolicyDrain CapacityPolicy = "drain"
+	// CapacityPolicyPreempt stops running jobs, periodic jobs first and the
+	// most recently started first, until the leases in use fit the new capacity
+	CapacityPolicyPreempt CapacityPolicy = "preempt"
+)
+
+// IsWindow reports whether the change only applies during windows, rather
+// than from At on
+func (c *CapacityChange) IsWindow() bool {
+	return c.CronSchedule != "" || c.Duration.Duration != 0
+}
+
+// Label returns the name of the change, or its index in the schedule if it has none
+func (c *CapacityChange) Label(index int) string {
+	if c.Name == "" {
+		return fmt.Sprintf("%d", index)
+	}
+	return c.Name
+}
+
+// PoolName returns the pool of the job, DefaultPool if it is not set
+func (j *Job) PoolName() string {
+	if j.Pool == "" {
+		return DefaultPool
+	}
+	return j.Pool
+}
+
+// ReleaseController reports whether the job is a release controller job,
+// allowed to use the reserved leases. Release controller triggered jobs are,
+// even in configurations built without Parse setting IsReleaseController.
+func (j *Job) ReleaseController() bool {
+	return j.IsReleaseController || j.TriggerType == TriggerTypeReleaseController
+}
+
+// JobTemplate is a job definition expanded into one job per matrix entry.
+// The "${version}" placeholder in the name, scenario and payload type is
+// replaced by the version of the entry.
+type JobTemplate struct {
+	Job    `yaml:",inline"`
+	Matrix []MatrixEntry `yaml:"matrix"`
+}
+
+// MatrixEntry is one version a job template is expanded for
+type MatrixEntry struct {
+	Version string `yaml:"version"`
+	// ScheduleOffset moves the runs of the cron schedule of the template later
+	// by the given duration, onto the next weekdays past midnight
+	ScheduleOffset Duration `yaml:"scheduleOffset,omitempty"`
+	// TriggerType and CronSchedule replace the ones of the template when set
+	TriggerType  TriggerType `yaml:"triggerType,omitempty"`
+	CronSchedule string      `yaml:"cronSchedule,omitempty"`
+}
+
+// VersionPlaceholder is replaced by the matrix version in job templates
+const VersionPlaceholder = "${version}"
+
 // TriggerType defines how a job is triggered
 type TriggerType string
 
@@ -38,12 +270,36 @@ const (
 	TriggerTypeReleaseController TriggerType = "release-controller"
 )
 
-// JobInstance represents a specific execution of a job
+// JobInstance represents a specific execution of a job. StartTime and EndTime
+// are the planned run until the job acquires a lease, then the actual one.
 type JobInstance struct {
-	Job       *Job
-	StartTime time.Time
-	EndTime   time.Time
+	Job           *Job
+	StartTime     time.Time
+	EndTime       time.Time
 	LeaseAcquired bool
 	LeaseWaitTime time.Duration
-	TimedOut   bool
+	TimedOut      bool
+
+	// ScheduledTime is when the job was triggered, by its cron schedule or a release
+	ScheduledTime time.Time
+	// LeaseAcquiredTime is when the job acquired a lease, zero if it never did
+	LeaseAcquiredTime time.Time
+	// SetupDuration and TeardownDuration are the phases of the run before and
+	// after the tests, EndTime being the end of the tests
+	SetupDuration    time.Duration
+	TeardownDuration time.Duration
+	// FinishedTime is when the job completed or timed out
+	FinishedTime time.Time
+	// Outcome is the final state of the job, empty until it is reached
+	Outcome JobState
+	// PreemptedBy is the job that evicted this preempted one, nil when it
+	// was preempted by a capacity change
+	PreemptedBy *JobInstance
+	// RetryOf is the preempted run this run retries, nil for first runs
+	RetryOf *JobInstance
+
+	// State is the lifecycle state of the instance, changed by Transition,
+	// and StateTimes holds when each state was entered
+	State      JobState
+	StateTimes map[JobState]time.Time
 }
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetCronSchedules returns the configuration file contents with the
+// cronSchedule of the named jobs replaced. The values are edited in place so
+// that comments and formatting of the rest of the file are preserved.
+func SetCronSchedules(data []byte, schedules map[string]string) ([]byte, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	jobs := mappingValue(documentMapping(&root), "jobs")
+	if jobs == nil || jobs.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("no jobs list found")
+	}
+
+	lines := strings.Split(string(data), "\n")
+	found := make(map[string]bool)
+
+	for _, item := range jobs.Content {
+		name := mappingValue(item, "name")
+		if name == nil {
+			continue
+		}
+		newSchedule, ok := schedules[name.Value]
+		if !ok {
+			continue
+		}
+
+		value := mappingValue(item, "cronSchedule")
+		if value == nil {
+			return nil, fmt.Errorf("job %s: no cronSchedule to replace", name.Value)
+		}
+
+		line := lines[value.Line-1]
+		start := value.Column - 1
+		offset := strings.Index(line[start:], value.Value)
+		if offset < 0 {
+			return nil, fmt.Errorf("job %s: cronSchedule not found on line %d", name.Value, value.Line)
+		}
+		start += offset
+		lines[value.Line-1] = line[:start] + newSchedule + line[start+len(value.Value):]
+		found[name.Value] = true
+	}
+
+	for name := range schedules {
+		if !found[name] {
+			return nil, fmt.Errorf("job %s not found in the jobs list (jobs from templates and includes cannot be rewritten)", name)
+		}
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// documentMapping returns the top-level mapping of a parsed YAML document
+func documentMapping(root *yaml.Node) *yaml.Node {
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		return root.Content[0]
+	}
+	return root
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// MetricPrefix is the prefix of the names of the exported metrics
+const MetricPrefix = "leases_simulated_"
+
+// noVersion is the version label of the jobs without a version
+const noVersion = "none"
+
+// labelName matches valid OpenMetrics label names
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// gauge describes an exported metric family
+type gauge struct {
+	name string
+	help string
+	// label is the label breaking the family down, empty for totals
+	label string
+	// values returns the value of every series of the family at a time point,
+	// keyed by label value
+	values func(tp simulation.TimePoint) map[string]int
+}
+
+// WriteOpenMetrics writes the time points as OpenMetrics text, one gauge
+// sample per time point with its timestamp, so that they can be backfilled
+// into Prometheus with "promtool tsdb create-blocks-from openmetrics". The
+// labels are added to every series, for instance to tell simulated
+// scenarios apart.
+func WriteOpenMetrics(w io.Writer, cfg *config.Config, timePoints []simulation.TimePoint, labels map[string]string) error {
+	for name := range labels {
+		if !labelName.MatchString(name) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		if name == "pool" || name == "version" {
+			return fmt.Errorf("label %q is used by the exported metrics", name)
+		}
+	}
+
+	// Every pool and version has a series, even when idle, so that the
+	// series have no gaps
+	pools := map[string]bool{}
+	versions := map[string]bool{}
+	for i := range cfg.Jobs {
+		pools[cfg.Jobs[i].PoolName()] = true
+		versions[versionLabel(cfg.Jobs[i].Version)] = true
+	}
+
+	total := func(value func(tp simulation.TimePoint) int) func(tp simulation.TimePoint) map[string]int {
+		return func(tp simulation.TimePoint) map[string]int {
+			return map[string]int{"": value(tp)}
+		}
+	}
+	byPool := func(value func(u simulation.Usage) int) func(tp simulation.TimePoint) map[string]int {
+		return func(tp simulation.TimePoint) map[string]int {
+			values := make(map[string]int, len(pools))
+			for pool := range pools {
+				values[pool] = value(tp.Pools[pool])
+			}
+			return values
+		}
+	}
+	byVersion := func(value func(u simulation.Usage) int) func(tp simulation.TimePoint) map[string]int {
+		return func(tp simulation.TimePoint) map[string]int {
+			values := make(map[string]int, len(versions))
+			for version := range versions {
+				values[version] = 0
+			}
+			for version, usage := range tp.Versions {
+				values[versionLabel(version)] += value(usage)
+			}
+			return values
+		}
+	}
+	active := func(u simulation.Usage) int { return u.ActiveLeases }
+	waiting := func(u simulation.Usage) int { return u.WaitingJobs }
+
+	gauges := []gauge{
+		{"active_leases", "Leases in use.", "", total(func(tp simulation.TimePoint) int { return tp.ActiveLeases })},
+		{"waiting_jobs", "Jobs waiting for a lease.", "", total(func(tp simulation.TimePoint) int { return tp.WaitingJobs })},
+		{"max_active_leases", "Maximum number of leases, following the capacity schedule.", "", total(func(tp simulation.TimePoint) int { return tp.MaxLeases })},
+		{"pool_active_leases", "Leases in use by the jobs of a pool.", "pool", byPool(active)},
+		{"pool_waiting_jobs", "Jobs of a pool waiting for a lease.", "pool", byPool(waiting)},
+		{"version_active_leases", "Leases in use by the jobs of a version.", "version", byVersion(active)},
+		{"version_waiting_jobs", "Jobs of a version waiting for a lease.", "version", byVersion(waiting)},
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, g := range gauges {
+		writeGauge(bw, g, timePoints, labels)
+	}
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
+
+// writeGauge writes a metric family. OpenMetrics requires the samples of a
+// series to be consecutive and in time order.
+func writeGauge(w *bufio.Writer, g gauge, timePoints []simulation.TimePoint, labels map[string]string) {
+	name := MetricPrefix + g.name
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(w, "# HELP %s %s\n", name, g.help)
+
+	series := make([]map[string]int, len(timePoints))
+	keys := map[string]bool{}
+	for i, tp := range timePoints {
+		series[i] = g.values(tp)
+		for key := range series[i] {
+			keys[key] = true
+		}
+	}
+
+	for _, key := range sortedKeys(keys) {
+		seriesLabels := make(map[string]string, len(labels)+1)
+		for label, value := range labels {
+			seriesLabels[label] = value
+		}
+		if g.label != "" {
+			seriesLabels[g.label] = key
+		}
+		selector := name + formatLabels(seriesLabels)
+
+		for i, tp := range timePoints {
+			timestamp := strconv.FormatFloat(float64(tp.Time.UnixMilli())/1000, 'f', -1, 64)
+			fmt.Fprintf(w, "%s %d %s\n", selector, series[i][key], timestamp)
+		}
+	}
+}
+
+// formatLabels returns the label set of a series, sorted by name
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelValueEscaper escapes the characters OpenMetrics does not allow in label values
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// versionLabel returns the label value of a job version
+func versionLabel(version string) string {
+	if version == "" {
+		return noVersion
+	}
+	return version
+}
+
+func sortedKeys(values map[string]bool) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
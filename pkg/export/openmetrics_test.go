@@ -0,0 +1,94 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	cfg := &config.Config{
+		MaxActiveLeases: 3,
+		Jobs: []config.Job{
+			{Name: "e2e", Version: "4.19", Pool: "aws"},
+			{Name: "upgrade", Version: "4.19"},
+			{Name: "tool"},
+		},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timePoints := []simulation.TimePoint{
+		{
+			Time:         start,
+			ActiveLeases: 2,
+			WaitingJobs:  1,
+			MaxLeases:    3,
+			Pools:        map[string]simulation.Usage{"aws": {ActiveLeases: 1, WaitingJobs: 1}, "default": {ActiveLeases: 1}},
+			Versions:     map[string]simulation.Usage{"4.19": {ActiveLeases: 1, WaitingJobs: 1}, "": {ActiveLeases: 1}},
+		},
+		{
+			Time:      start.Add(90 * time.Second),
+			MaxLeases: 2,
+			Pools:     map[string]simulation.Usage{},
+			Versions:  map[string]simulation.Usage{},
+		},
+	}
+
+	var sb strings.Builder
+	if err := WriteOpenMetrics(&sb, cfg, timePoints, map[string]string{"scenario": `a "b"`}); err != nil {
+		t.Fatalf("WriteOpenMetrics() error = %v", err)
+	}
+
+	want := `# TYPE leases_simulated_active_leases gauge
+# HELP leases_simulated_active_leases Leases in use.
+leases_simulated_active_leases{scenario="a \"b\""} 2 1704067200
+leases_simulated_active_leases{scenario="a \"b\""} 0 1704067290
+# TYPE leases_simulated_waiting_jobs gauge
+# HELP leases_simulated_waiting_jobs Jobs waiting for a lease.
+leases_simulated_waiting_jobs{scenario="a \"b\""} 1 1704067200
+leases_simulated_waiting_jobs{scenario="a \"b\""} 0 1704067290
+# TYPE leases_simulated_max_active_leases gauge
+# HELP leases_simulated_max_active_leases Maximum number of leases, following the capacity schedule.
+leases_simulated_max_active_leases{scenario="a \"b\""} 3 1704067200
+leases_simulated_max_active_leases{scenario="a \"b\""} 2 1704067290
+# TYPE leases_simulated_pool_active_leases gauge
+# HELP leases_simulated_pool_active_leases Leases in use by the jobs of a pool.
+leases_simulated_pool_active_leases{pool="aws",scenario="a \"b\""} 1 1704067200
+leases_simulated_pool_active_leases{pool="aws",scenario="a \"b\""} 0 1704067290
+leases_simulated_pool_active_leases{pool="default",scenario="a \"b\""} 1 1704067200
+leases_simulated_pool_active_leases{pool="default",scenario="a \"b\""} 0 1704067290
+# TYPE leases_simulated_pool_waiting_jobs gauge
+# HELP leases_simulated_pool_waiting_jobs Jobs of a pool waiting for a lease.
+leases_simulated_pool_waiting_jobs{pool="aws",scenario="a \"b\""} 1 1704067200
+leases_simulated_pool_waiting_jobs{pool="aws",scenario="a \"b\""} 0 1704067290
+leases_simulated_pool_waiting_jobs{pool="default",scenario="a \"b\""} 0 1704067200
+leases_simulated_pool_waiting_jobs{pool="default",scenario="a \"b\""} 0 1704067290
+# TYPE leases_simulated_version_active_leases gauge
+# HELP leases_simulated_version_active_leases Leases in use by the jobs of a version.
+leases_simulated_version_active_leases{scenario="a \"b\"",version="4.19"} 1 1704067200
+leases_simulated_version_active_leases{scenario="a \"b\"",version="4.19"} 0 1704067290
+leases_simulated_version_active_leases{scenario="a \"b\"",version="none"} 1 1704067200
+leases_simulated_version_active_leases{scenario="a \"b\"",version="none"} 0 1704067290
+# TYPE leases_simulated_version_waiting_jobs gauge
+# HELP leases_simulated_version_waiting_jobs Jobs of a version waiting for a lease.
+leases_simulated_version_waiting_jobs{scenario="a \"b\"",version="4.19"} 1 1704067200
+leases_simulated_version_waiting_jobs{scenario="a \"b\"",version="4.19"} 0 1704067290
+leases_simulated_version_waiting_jobs{scenario="a \"b\"",version="none"} 0 1704067200
+leases_simulated_version_waiting_jobs{scenario="a \"b\"",version="none"} 0 1704067290
+# EOF
+`
+	if got := sb.String(); got != want {
+		t.Errorf("WriteOpenMetrics() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteOpenMetricsInvalidLabels(t *testing.T) {
+	for _, labels := range []map[string]string{{"1scenario": "a"}, {"pool": "a"}} {
+		var sb strings.Builder
+		if err := WriteOpenMetrics(&sb, &config.Config{}, nil, labels); err == nil {
+			t.Errorf("WriteOpenMetrics() with labels %v succeeded, want an error", labels)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/schedule"
+)
+
+// Finding is a suspicious pattern found in a configuration
+type Finding struct {
+	Rule     string
+	Position config.Position
+	Message  string
+}
+
+// String returns the finding prefixed with its position and rule
+func (f Finding) String() string {
+	message := fmt.Sprintf("[%s] %s", f.Rule, f.Message)
+	if position := f.Position.String(); position != "" {
+		return position + ": " + message
+	}
+	return message
+}
+
+// Rule checks a configuration for one suspicious pattern. Cron schedules are
+// evaluated over the simulation window starting at start.
+type Rule struct {
+	Name        string
+	Description string
+	Check       func(cfg *config.Config, start time.Time) []Finding
+}
+
+// Rules are all the lint rules, in the order they are run
+var Rules = []Rule{
+	{
+		Name:        "duration-exceeds-timeout",
+		Description: "Job setup and duration exceed jobTimeoutDuration, so every run times out",
+		Check:       checkDurationExceedsTimeout,
+	},
+	{
+		Name:        "cron-never-fires",
+		Description: "Cron job never fires within simulationDuration",
+		Check:       checkCronNeverFires,
+	},
+	{
+		Name:        "release-controller-mismatch",
+		Description: "isReleaseController on a cron job has no effect without reservedLeases",
+		Check:       checkReleaseControllerMismatch,
+	},
+	{
+		Name:        "duplicate-schedule",
+		Description: "Several cron jobs start in the same minute",
+		Check:       checkDuplicateSchedule,
+	},
+	{
+		Name:        "version-without-jobs",
+		Description: "Version between the lowest and highest configured ones has no jobs",
+		Check:       checkVersionWithoutJobs,
+	},
+	{
+		Name:        "preemption-without-priorities",
+		Description: "Preemption is enabled but all jobs have the same priority, so none preempts",
+		Check:       checkPreemptionWithoutPriorities,
+	},
+}
+
+// Run runs all lint rules against a valid configuration
+func Run(cfg *config.Config, start time.Time) []Finding {
+	findings := []Finding{}
+	for _, rule := range Rules {
+		findings = append(findings, rule.Check(cfg, start)...)
+	}
+	return findings
+}
+
+func checkDurationExceedsTimeout(cfg *config.Config, start time.Time) []Finding {
+	findings := []Finding{}
+	for i := range cfg.Jobs {
+		job := &cfg.Jobs[i]
+		setup, _ := cfg.JobPhases(job)
+		if job.Duration.Duration+setup <= cfg.JobTimeoutDuration.Duration {
+			continue
+		}
+		message := fmt.Sprintf("job %s: duration %s exceeds jobTimeoutDuration %s", job.Name, job.Duration, cfg.JobTimeoutDuration)
+		if setup > 0 {
+			message = fmt.Sprintf("job %s: setup %s and duration %s exceed jobTimeoutDuration %s", job.Name, config.Duration{Duration: setup}, job.Duration, cfg.JobTimeoutDuration)
+		}
+		findings = append(findings, Finding{
+			Rule:     "duration-exceeds-timeout",
+			Position: job.Position("duration"),
+			Message:  message,
+		})
+	}
+	return findings
+}
+
+func checkCronNeverFires(cfg *config.Config, start time.Time) []Finding {
+	findings := []Finding{}
+	end := start.Add(cfg.SimulationDuration.Duration)
+
+	for i := range cfg.Jobs {
+		job := &cfg.Jobs[i]
+		if job.TriggerType != config.TriggerTypeCron {
+			continue
+		}
+
+		cronSchedule, err := schedule.ParseSchedule(job.CronSchedule)
+		if err != nil {
+			continue
+		}
+		if next := cronSchedule.Next(start.Add(-time.Minute)); next.IsZero() || next.After(end) {
+			findings = append(findings, Finding{
+				Rule:     "cron-never-fires",
+				Position: job.Position("cronSchedule"),
+				Message:  fmt.Sprintf("job %s: cronSchedule %q never fires within simulationDuration %s", job.Name, job.CronSchedule, cfg.SimulationDuration),
+			})
+		}
+	}
+	return findings
+}
+
+func checkReleaseControllerMismatch(cfg *config.Config, start time.Time) []Finding {
+	findings := []Finding{}
+	for i := range cfg.Jobs {
+		job := &cfg.Jobs[i]
+		// Validation rejects release-controller triggered jobs that are not release controller jobs
+		if job.IsReleaseController && job.TriggerType == config.TriggerTypeCron && cfg.ReservedLeases == 0 {
+			findings = append(findings, Finding{
+				Rule:     "release-controller-mismatch",
+				Position: job.Position("isReleaseController"),
+				Message:  fmt.Sprintf("job %s: isReleaseController is set on a cron-triggered job but no leases are reserved", job.Name),
+			})
+		}
+	}
+	return findings
+}
+
+func checkDuplicateSchedule(cfg *config.Config, start time.Time) []Finding {
+	findings := []Finding{}
+	end := start.Add(cfg.SimulationDuration.Duration)
+
+	// Start minutes of every cron job over the simulation window
+	startsByJob := make(map[int]map[time.Time]bool)
+	for i := range cfg.Jobs {
+		job := &cfg.Jobs[i]
+		if job.TriggerType != config.TriggerTypeCron {
+			continue
+		}
+		cronSchedule, err := schedule.ParseSchedule(job.CronSchedule)
+		if err != nil {
+			continue
+		}
+
+		starts := make(map[time.Time]bool)
+		for next := cronSchedule.Next(start.Add(-time.Minute)); !next.IsZero() && next.Before(end); next = cronSchedule.Next(next) {
+			starts[next] = true
+		}
+		startsByJob[i] = starts
+	}
+
+	for i := range cfg.Jobs {
+		for j := 0; j < i; j++ {
+			first, second := startsByJob[j], startsByJob[i]
+			if first == nil || second == nil {
+				continue
+			}
+
+			var shared time.Time
+			for t := range second {
+				if first[t] && (shared.IsZero() || t.Before(shared)) {
+					shared = t
+				}
+			}
+			if shared.IsZero() {
+				continue
+			}
+
+			findings = append(findings, Finding{
+				Rule:     "duplicate-schedule",
+				Position: cfg.Jobs[i].Position("cronSchedule"),
+				Message: fmt.Sprintf("job %s starts in the same minute as job %s (first at %s)",
+					cfg.Jobs[i].Name, cfg.Jobs[j].Name, shared.Format("Mon 15:04")),
+			})
+		}
+	}
+	return findings
+}
+
+func checkVersionWithoutJobs(cfg *config.Config, start time.Time) []Finding {
+	findings := []Finding{}
+
+	// Minor versions with jobs, per major version, for "major.minor" versions
+	minors := make(map[int]map[int]bool)
+	for _, job := range cfg.Jobs {
+		major, minor, ok := parseVersion(job.Version)
+		if !ok {
+			continue
+		}
+		if minors[major] == nil {
+			minors[major] = make(map[int]bool)
+		}
+		minors[major][minor] = true
+	}
+
+	majors := make([]int, 0, len(minors))
+	for major := range minors {
+		majors = append(majors, major)
+	}
+	sort.Ints(majors)
+
+	for _, major := range majors {
+		lowest, highest := -1, -1
+		for minor := range minors[major] {
+			if lowest < 0 || minor < lowest {
+				lowest = minor
+			}
+			if minor > highest {
+				highest = minor
+			}
+		}
+		for minor := lowest + 1; minor < highest; minor++ {
+			if !minors[major][minor] {
+				findings = append(findings, Finding{
+					Rule:     "version-without-jobs",
+					Position: cfg.Position("jobs"),
+					Message:  fmt.Sprintf("version %d.%d has no jobs, while %d.%d and %d.%d do", major, minor, major, lowest, major, highest),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkPreemptionWithoutPriorities(cfg *config.Config, start time.Time) []Finding {
+	if !cfg.Preemption.Enabled || len(cfg.Jobs) == 0 {
+		return []Finding{}
+	}
+	for _, job := range cfg.Jobs {
+		if job.Priority != cfg.Jobs[0].Priority {
+			return []Finding{}
+		}
+	}
+	return []Finding{{
+		Rule:     "preemption-without-priorities",
+		Position: cfg.Position("preemption"),
+		Message:  fmt.Sprintf("preemption is enabled but all jobs have priority %d, so no job preempts another", cfg.Jobs[0].Priority),
+	}}
+}
+
+// parseVersion parses a "major.minor" version
+func parseVersion(version string) (int, int, bool) {
+	majorPart, minorPart, found := strings.Cut(version, ".")
+	if !found {
+		return 0, 0, false
+	}
+	major, err1 := strconv.Atoi(majorPart)
+	minor, err2 := strconv.Atoi(minorPart)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
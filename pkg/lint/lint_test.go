@@ -0,0 +1,194 @@
+package lint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+)
+
+// testStart is a Monday
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const testHeader = `maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+`
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		config string
+		want   []string
+	}{
+		{
+			name: "duration exceeds timeout",
+			rule: "duration-exceeds-timeout",
+			config: `jobs:
+  - name: long
+    duration: 5h
+    triggerType: release-controller
+  - name: setup
+    duration: 4h
+    setupDuration: 30m
+    triggerType: release-controller
+  - name: fits
+    duration: 4h
+    triggerType: release-controller
+`,
+			want: []string{
+				"lint.yaml:7:15: [duration-exceeds-timeout] job long: duration 5h exceeds jobTimeoutDuration 4h",
+				"lint.yaml:10:15: [duration-exceeds-timeout] job setup: setup 30m and duration 4h exceed jobTimeoutDuration 4h",
+			},
+		},
+		{
+			name: "cron never fires",
+			rule: "cron-never-fires",
+			config: `jobs:
+  - name: leap-day
+    duration: 1h
+    triggerType: cron
+    cronSchedule: "0 0 29 2 *"
+  - name: daily
+    duration: 1h
+    triggerType: cron
+    cronSchedule: "0 0 * * *"
+`,
+			want: []string{
+				`lint.yaml:9:19: [cron-never-fires] job leap-day: cronSchedule "0 0 29 2 *" never fires within simulationDuration 7d`,
+			},
+		},
+		{
+			name: "release controller cron job without reserved leases",
+			rule: "release-controller-mismatch",
+			config: `jobs:
+  - name: fallback
+    duration: 1h
+    triggerType: cron
+    cronSchedule: "0 0 * * *"
+    isReleaseController: true
+`,
+			want: []string{
+				"lint.yaml:10:26: [release-controller-mismatch] job fallback: isReleaseController is set on a cron-triggered job but no leases are reserved",
+			},
+		},
+		{
+			name: "release controller cron job with reserved leases",
+			rule: "release-controller-mismatch",
+			config: `reservedLeases: 1
+jobs:
+  - name: fallback
+    duration: 1h
+    triggerType: cron
+    cronSchedule: "0 0 * * *"
+    isReleaseController: true
+`,
+		},
+		{
+			name: "duplicate schedule",
+			rule: "duplicate-schedule",
+			config: `jobs:
+  - name: daily
+    duration: 1h
+    triggerType: cron
+    cronSchedule: "0 1 * * *"
+  - name: tuesdays
+    duration: 1h
+    triggerType: cron
+    cronSchedule: "0 1 * * 2"
+  - name: later
+    duration: 1h
+    triggerType: cron
+    cronSchedule: "30 1 * * *"
+`,
+			want: []string{
+				`lint.yaml:13:19: [duplicate-schedule] job tuesdays starts in the same minute as job daily (first at Tue 01:00)`,
+			},
+		},
+		{
+			name: "version without jobs",
+			rule: "version-without-jobs",
+			config: `jobs:
+  - name: e2e-4.17
+    version: "4.17"
+    duration: 1h
+    triggerType: release-controller
+  - name: e2e-4.20
+    version: "4.20"
+    duration: 1h
+    triggerType: release-controller
+  - name: e2e-main
+    version: main
+    duration: 1h
+    triggerType: release-controller
+`,
+			want: []string{
+				"lint.yaml:6:3: [version-without-jobs] version 4.18 has no jobs, while 4.17 and 4.20 do",
+				"lint.yaml:6:3: [version-without-jobs] version 4.19 has no jobs, while 4.17 and 4.20 do",
+			},
+		},
+		{
+			name: "preemption without priorities",
+			rule: "preemption-without-priorities",
+			config: `preemption:
+  enabled: true
+jobs:
+  - name: a
+    duration: 1h
+    triggerType: release-controller
+  - name: b
+    duration: 1h
+    triggerType: release-controller
+`,
+			want: []string{
+				"lint.yaml:6:3: [preemption-without-priorities] preemption is enabled but all jobs have priority 0, so no job preempts another",
+			},
+		},
+		{
+			name: "preemption with priorities",
+			rule: "preemption-without-priorities",
+			config: `preemption:
+  enabled: true
+jobs:
+  - name: a
+    duration: 1h
+    priority: 10
+    triggerType: release-controller
+  - name: b
+    duration: 1h
+    triggerType: release-controller
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.Parse("lint.yaml", []byte(testHeader+tt.config))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			var check func(cfg *config.Config, start time.Time) []Finding
+			for _, rule := range Rules {
+				if rule.Name == tt.rule {
+					check = rule.Check
+				}
+			}
+			if check == nil {
+				t.Fatalf("no rule %s", tt.rule)
+			}
+
+			findings := check(cfg, testStart)
+			if len(findings) != len(tt.want) {
+				t.Fatalf("got findings %v, want %q", findings, tt.want)
+			}
+			for i, finding := range findings {
+				if finding.String() != tt.want[i] {
+					t.Errorf("finding %d = %q, want %q", i, finding.String(), tt.want[i])
+				}
+			}
+		})
+	}
+}
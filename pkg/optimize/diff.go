@@ -0,0 +1,28 @@
+package optimize
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diff returns a unified-style diff between two versions of a file whose
+// lines were edited in place, so both versions have the same line count
+func Diff(oldName, newName string, before, after []byte) string {
+	oldLines := strings.Split(string(before), "\n")
+	newLines := strings.Split(string(after), "\n")
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n", oldName))
+	sb.WriteString(fmt.Sprintf("+++ %s\n", newName))
+
+	for i := 0; i < len(oldLines) && i < len(newLines); i++ {
+		if oldLines[i] == newLines[i] {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("@@ -%d +%d @@\n", i+1, i+1))
+		sb.WriteString(fmt.Sprintf("-%s\n", oldLines[i]))
+		sb.WriteString(fmt.Sprintf("+%s\n", newLines[i]))
+	}
+
+	return sb.String()
+}
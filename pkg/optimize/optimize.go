@@ -0,0 +1,225 @@
+package optimize
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/schedule"
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// Options controls the schedule search
+type Options struct {
+	Seeds      int
+	BaseSeed   int64
+	StartTime  time.Time
+	Rounds     int
+	MinuteStep int
+}
+
+// Score is the objective minimised by the optimizer, summed over all seeded runs.
+// Scores are compared field by field in declaration order.
+type Score struct {
+	WaitTimeouts int
+	TotalWait    time.Duration
+	PeakDemand   int
+}
+
+// Less reports whether s is a better score than other
+func (s Score) Less(other Score) bool {
+	if s.WaitTimeouts != other.WaitTimeouts {
+		return s.WaitTimeouts < other.WaitTimeouts
+	}
+	if s.TotalWait != other.TotalWait {
+		return s.TotalWait < other.TotalWait
+	}
+	return s.PeakDemand < other.PeakDemand
+}
+
+// Change is a proposed schedule change for a single job
+type Change struct {
+	JobName     string
+	OldSchedule string
+	NewSchedule string
+}
+
+// Result is the outcome of a schedule search
+type Result struct {
+	Before  Score
+	After   Score
+	Changes []Change
+	Config  *config.Config
+}
+
+// Optimize searches alternative cron schedules for the movable jobs of the
+// configuration, minimising wait timeouts, wait time and peak demand under
+// the configured lease count. It moves one job at a time to its best
+// schedule and repeats until a round brings no improvement.
+func Optimize(ctx context.Context, cfg *config.Config, opts Options) (*Result, error) {
+	if opts.Seeds <= 0 {
+		return nil, fmt.Errorf("number of seeds must be greater than 0")
+	}
+	if opts.MinuteStep <= 0 || opts.MinuteStep > 60 {
+		return nil, fmt.Errorf("minute step must be between 1 and 60")
+	}
+
+	// Work on a copy so the caller's configuration is left untouched
+	optimized := *cfg
+	optimized.Jobs = append([]config.Job(nil), cfg.Jobs...)
+
+	candidates := make(map[int][]string)
+	for i, job := range optimized.Jobs {
+		if !job.Movable {
+			continue
+		}
+		jobCandidates, err := scheduleCandidates(job, opts.MinuteStep)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		candidates[i] = jobCandidates
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no movable jobs in the configuration")
+	}
+
+	before, err := evaluate(ctx, &optimized, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	best := before
+	for round := 0; round < opts.Rounds; round++ {
+		improved := false
+
+		for i := range optimized.Jobs {
+			current := optimized.Jobs[i].CronSchedule
+			for _, candidate := range candidates[i] {
+				if candidate == current {
+					continue
+				}
+
+				optimized.Jobs[i].CronSchedule = candidate
+				score, err := evaluate(ctx, &optimized, opts)
+				if err != nil {
+					return nil, err
+				}
+
+				if score.Less(best) {
+					best = score
+					current = candidate
+					improved = true
+				}
+			}
+			optimized.Jobs[i].CronSchedule = current
+		}
+
+		if !improved {
+			break
+		}
+	}
+
+	result := &Result{
+		Before: before,
+		After:  best,
+		Config: &optimized,
+	}
+	for i, job := range optimized.Jobs {
+		if job.CronSchedule != cfg.Jobs[i].CronSchedule {
+			result.Changes = append(result.Changes, Change{
+				JobName:     job.Name,
+				OldSchedule: cfg.Jobs[i].CronSchedule,
+				NewSchedule: job.CronSchedule,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// scheduleCandidates lists the schedules a movable job may be moved to: its
+// hours shifted around the clock and its minute moved in steps, keeping the
+// frequency and the day fields of the original schedule
+func scheduleCandidates(job config.Job, minuteStep int) ([]string, error) {
+	expr, err := schedule.Parse(job.CronSchedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron schedule: %w", err)
+	}
+
+	allowed := map[int]bool{}
+	if job.AllowedHours != "" {
+		hours, err := schedule.ExpandField(job.AllowedHours, 0, 23)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowedHours: %w", err)
+		}
+		for _, hour := range hours {
+			allowed[hour] = true
+		}
+	}
+
+	minutes := []int{}
+	if _, ok := expr.FixedMinute(); ok {
+		for minute := 0; minute < 60; minute += minuteStep {
+			minutes = append(minutes, minute)
+		}
+	}
+
+	seen := map[string]bool{job.CronSchedule: true}
+	candidates := []string{job.CronSchedule}
+
+	for delta := 0; delta < 24; delta++ {
+		shifted, err := expr.ShiftHours(delta)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(allowed) > 0 {
+			hours, err := shifted.Hours()
+			if err != nil {
+				return nil, err
+			}
+			fits := true
+			for _, hour := range hours {
+				fits = fits && allowed[hour]
+			}
+			if !fits {
+				continue
+			}
+		}
+
+		variants := []schedule.Expression{shifted}
+		for _, minute := range minutes {
+			variants = append(variants, shifted.WithMinute(minute))
+		}
+		for _, variant := range variants {
+			candidate := variant.String()
+			if !seen[candidate] {
+				seen[candidate] = true
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+
+	return candidates, nil
+}
+
+// evaluate simulates the configuration once per seed and sums the scores
+func evaluate(ctx context.Context, cfg *config.Config, opts Options) (Score, error) {
+	var score Score
+
+	for i := 0; i < opts.Seeds; i++ {
+		sim := simulation.NewSimulator(cfg, simulation.WithStartTime(opts.StartTime), simulation.WithSeed(opts.BaseSeed+int64(i)))
+		result, err := sim.Run(ctx)
+		if err != nil {
+			return score, fmt.Errorf("simulation failed: %w", err)
+		}
+
+		metrics := result.Metrics
+		score.WaitTimeouts += metrics.WaitTimeouts
+		score.TotalWait += metrics.TotalWait
+		score.PeakDemand += metrics.PeakDemand
+	}
+
+	return score, nil
+}
@@ -0,0 +1,164 @@
+package optimize
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testConfig has three periodic jobs triggered at 01:00 for a day under two
+// leases, so that one of them times out waiting unless c moves
+func testConfig() *config.Config {
+	job := func(name string) config.Job {
+		return config.Job{
+			Name:         name,
+			Version:      "4.19",
+			Duration:     config.Duration{Duration: 2 * time.Hour},
+			TriggerType:  config.TriggerTypeCron,
+			CronSchedule: "0 1 * * *",
+		}
+	}
+	cfg := &config.Config{
+		MaxActiveLeases:    2,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: time.Hour},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		Jobs:               []config.Job{job("a"), job("b"), job("c")},
+	}
+	cfg.Jobs[2].Movable = true
+	cfg.Jobs[2].AllowedHours = "1-6"
+	return cfg
+}
+
+func TestOptimize(t *testing.T) {
+	cfg := testConfig()
+
+	result, err := Optimize(context.Background(), cfg, Options{Seeds: 1, StartTime: testStart, Rounds: 3, MinuteStep: 60})
+	if err != nil {
+		t.Fatalf("Optimize() error = %v", err)
+	}
+
+	if result.Before.WaitTimeouts != 1 || result.After.WaitTimeouts != 0 {
+		t.Errorf("wait timeouts went from %d to %d, want from 1 to 0", result.Before.WaitTimeouts, result.After.WaitTimeouts)
+	}
+	// c moves to the first hour a lease is free, when a and b are done
+	want := []Change{{JobName: "c", OldSchedule: "0 1 * * *", NewSchedule: "0 3 * * *"}}
+	if !reflect.DeepEqual(result.Changes, want) {
+		t.Errorf("Changes = %+v, want %+v", result.Changes, want)
+	}
+	if cfg.Jobs[2].CronSchedule != "0 1 * * *" {
+		t.Errorf("Optimize() changed the schedule of the configuration to %q", cfg.Jobs[2].CronSchedule)
+	}
+}
+
+func TestOptimizeNoRounds(t *testing.T) {
+	result, err := Optimize(context.Background(), testConfig(), Options{Seeds: 1, StartTime: testStart, Rounds: 0, MinuteStep: 60})
+	if err != nil {
+		t.Fatalf("Optimize() error = %v", err)
+	}
+	if len(result.Changes) != 0 || result.After != result.Before {
+		t.Errorf("Optimize() without rounds changed %+v, want no change", result.Changes)
+	}
+}
+
+func TestOptimizeInvalid(t *testing.T) {
+	noMovable := testConfig()
+	noMovable.Jobs[2].Movable = false
+	badHours := testConfig()
+	badHours.Jobs[2].AllowedHours = "25"
+
+	tests := []struct {
+		name string
+		cfg  *config.Config
+		opts Options
+	}{
+		{name: "no seeds", cfg: testConfig(), opts: Options{Seeds: 0, MinuteStep: 30}},
+		{name: "no minute step", cfg: testConfig(), opts: Options{Seeds: 1, MinuteStep: 0}},
+		{name: "minute step above an hour", cfg: testConfig(), opts: Options{Seeds: 1, MinuteStep: 61}},
+		{name: "no movable jobs", cfg: noMovable, opts: Options{Seeds: 1, MinuteStep: 30}},
+		{name: "invalid allowed hours", cfg: badHours, opts: Options{Seeds: 1, MinuteStep: 30}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Optimize(context.Background(), tt.cfg, tt.opts); err == nil {
+				t.Error("Optimize() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestScheduleCandidates(t *testing.T) {
+	tests := []struct {
+		name       string
+		schedule   string
+		hours      string
+		minuteStep int
+		want       []string
+	}{
+		{
+			name:       "allowed hours",
+			schedule:   "0 1 * * *",
+			hours:      "1-2",
+			minuteStep: 30,
+			want:       []string{"0 1 * * *", "30 1 * * *", "0 2 * * *", "30 2 * * *"},
+		},
+		{
+			name:       "minute kept when not fixed",
+			schedule:   "*/15 1 * * *",
+			hours:      "1-2",
+			minuteStep: 30,
+			want:       []string{"*/15 1 * * *", "*/15 2 * * *"},
+		},
+		{
+			name:       "every hour of the day shifted together",
+			schedule:   "0 1,13 * * 1-5",
+			hours:      "0-3,12-14",
+			minuteStep: 60,
+			want:       []string{"0 1,13 * * 1-5", "0 2,14 * * 1-5", "0 0,12 * * 1-5"},
+		},
+		{
+			name:       "original schedule kept outside the allowed hours",
+			schedule:   "0 8 * * *",
+			hours:      "1",
+			minuteStep: 60,
+			want:       []string{"0 8 * * *", "0 1 * * *"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scheduleCandidates(config.Job{CronSchedule: tt.schedule, AllowedHours: tt.hours}, tt.minuteStep)
+			if err != nil {
+				t.Fatalf("scheduleCandidates() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scheduleCandidates() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreLess(t *testing.T) {
+	tests := []struct {
+		a, b Score
+		want bool
+	}{
+		{a: Score{WaitTimeouts: 0, TotalWait: time.Hour}, b: Score{WaitTimeouts: 1}, want: true},
+		{a: Score{WaitTimeouts: 1, TotalWait: time.Minute}, b: Score{WaitTimeouts: 1, TotalWait: time.Hour}, want: true},
+		{a: Score{TotalWait: time.Hour, PeakDemand: 2}, b: Score{TotalWait: time.Hour, PeakDemand: 3}, want: true},
+		{a: Score{PeakDemand: 3}, b: Score{PeakDemand: 3}, want: false},
+		{a: Score{WaitTimeouts: 2}, b: Score{WaitTimeouts: 1, TotalWait: 10 * time.Hour, PeakDemand: 9}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Less(tt.b); got != tt.want {
+			t.Errorf("%+v.Less(%+v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
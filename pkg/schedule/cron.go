@@ -0,0 +1,296 @@
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+)
+
+// parser is the cron parser used for job schedules (standard 5-field format)
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+
+// ParseSchedule parses a 5-field cron expression. As in standard cron, both
+// 0 and 7 represent Sunday in the day of week field.
+func ParseSchedule(expr string) (cron.Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 5 {
+		fields[4] = normalizeDayOfWeek(fields[4])
+		expr = strings.Join(fields, " ")
+	}
+	return parser.Parse(expr)
+}
+
+// normalizeDayOfWeek rewrites Sunday written as 7 to 0, which is the only
+// form accepted by the cron library
+func normalizeDayOfWeek(field string) string {
+	parts := strings.Split(field, ",")
+	for i, part := range parts {
+		switch {
+		case part == "7":
+			parts[i] = "0"
+		case strings.HasSuffix(part, "-7"):
+			start := strings.TrimSuffix(part, "-7")
+			if start == "7" {
+				parts[i] = "0"
+			} else {
+				parts[i] = start + "-6,0"
+			}
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Expression is a 5-field cron expression split into its fields
+type Expression struct {
+	Minute     string
+	Hour       string
+	DayOfMonth string
+	Month      string
+	DayOfWeek  string
+}
+
+// Parse splits and validates a 5-field cron expression
+func Parse(expr string) (Expression, error) {
+	if _, err := ParseSchedule(expr); err != nil {
+		return Expression{}, err
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Expression{}, fmt.Errorf("expected 5 fields, found %d: %s", len(fields), expr)
+	}
+
+	return Expression{
+		Minute:     fields[0],
+		Hour:       fields[1],
+		DayOfMonth: fields[2],
+		Month:      fields[3],
+		DayOfWeek:  fields[4],
+	}, nil
+}
+
+// String returns the expression in cron format
+func (e Expression) String() string {
+	return strings.Join([]string{e.Minute, e.Hour, e.DayOfMonth, e.Month, e.DayOfWeek}, " ")
+}
+
+// FixedMinute returns the minute of the expression if it fires at a single minute of the hour
+func (e Expression) FixedMinute() (int, bool) {
+	minute, err := strconv.Atoi(e.Minute)
+	if err != nil {
+		return 0, false
+	}
+	return minute, true
+}
+
+// Hours returns the hours of the day matched by the expression in ascending order
+func (e Expression) Hours() ([]int, error) {
+	return ExpandField(e.Hour, 0, 23)
+}
+
+// WithMinute returns the expression firing at the given minute of the hour
+func (e Expression) WithMinute(minute int) Expression {
+	e.Minute = strconv.Itoa(minute)
+	return e
+}
+
+// ShiftHours returns the expression with every matched hour moved by delta
+// hours, wrapping around midnight. The day fields are left untouched: this
+// rotates the hours of the day the expression fires at, use ShiftMinutes to
+// move its runs later.
+func (e Expression) ShiftHours(delta int) (Expression, error) {
+	delta = ((delta % 24) + 24) % 24
+	if delta == 0 || e.Hour == "*" {
+		return e, nil
+	}
+
+	hours, err := e.Hours()
+	if err != nil {
+		return e, err
+	}
+
+	shifted := make([]int, len(hours))
+	for i, hour := range hours {
+		shifted[i] = (hour + delta) % 24
+	}
+	e.Hour = FormatField(shifted, 0, 23)
+
+	return e, nil
+}
+
+// ShiftMinutes returns the expression with its runs moved later by the given
+// number of minutes, carrying into the hour field and, past midnight, into
+// the day of week field. Shifting by anything other than whole hours
+// requires the expression to fire at a single minute, and runs can only
+// move to another day when the day of month and month fields are "*" and
+// they all move to the same day.
+func (e Expression) ShiftMinutes(minutes int) (Expression, error) {
+	hourDelta := floorDiv(minutes, 60)
+	if minutes%60 != 0 {
+		minute, ok := e.FixedMinute()
+		if !ok {
+			return e, fmt.Errorf("cannot shift %q by %d minutes: minute field is not a single value", e.String(), minutes)
+		}
+		total := minute + minutes
+		hourDelta = floorDiv(total, 60)
+		e.Minute = strconv.Itoa(total - hourDelta*60)
+	}
+	if hourDelta == 0 {
+		return e, nil
+	}
+
+	hours, err := e.Hours()
+	if err != nil {
+		return e, err
+	}
+	shifted := make([]int, len(hours))
+	dayDeltas := make(map[int]bool)
+	for i, hour := range hours {
+		dayDelta := floorDiv(hour+hourDelta, 24)
+		dayDeltas[dayDelta] = true
+		shifted[i] = hour + hourDelta - dayDelta*24
+	}
+	original := e.String()
+	if e.Hour != "*" {
+		e.Hour = FormatField(shifted, 0, 23)
+	}
+
+	if len(dayDeltas) == 1 && dayDeltas[0] {
+		return e, nil
+	}
+	if e.DayOfMonth != "*" || e.Month != "*" {
+		return e, fmt.Errorf("cannot shift %q by %d minutes: its runs would move to another day of month", original, minutes)
+	}
+	if e.DayOfWeek == "*" {
+		return e, nil
+	}
+	if len(dayDeltas) > 1 {
+		return e, fmt.Errorf("cannot shift %q by %d minutes: its runs would move to different days of the week", original, minutes)
+	}
+
+	weekdays, err := ExpandField(normalizeDayOfWeek(e.DayOfWeek), 0, 6)
+	if err != nil {
+		return e, err
+	}
+	for dayDelta := range dayDeltas {
+		for i, weekday := range weekdays {
+			weekdays[i] = ((weekday+dayDelta)%7 + 7) % 7
+		}
+	}
+	e.DayOfWeek = FormatField(weekdays, 0, 6)
+
+	return e, nil
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// ExpandField returns the values matched by a single cron field in ascending order
+func ExpandField(field string, min, max int) ([]int, error) {
+	seen := make(map[int]bool)
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangePart, stepPart, found := strings.Cut(part, "/"); found {
+			s, err := strconv.Atoi(stepPart)
+			if err != nil || s <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			step = s
+			part = rangePart
+		}
+
+		low, high := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			lowPart, highPart, _ := strings.Cut(part, "-")
+			l, err1 := strconv.Atoi(lowPart)
+			h, err2 := strconv.Atoi(highPart)
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+			low, high = l, h
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			low = v
+			if step == 1 {
+				high = v
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return nil, fmt.Errorf("value out of range in %q", part)
+		}
+		for v := low; v <= high; v += step {
+			seen[v] = true
+		}
+	}
+
+	values := make([]int, 0, len(seen))
+	for v := range seen {
+		values = append(values, v)
+	}
+	sort.Ints(values)
+
+	return values, nil
+}
+
+// FormatField renders a set of values as a compact cron field, using ranges
+// for consecutive values and a step when the values are evenly spaced
+func FormatField(values []int, min, max int) string {
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+
+	if len(sorted) == 0 {
+		return "*"
+	}
+	if len(sorted) == max-min+1 {
+		return "*"
+	}
+
+	// Evenly spaced values running to the end of the range, e.g. "3-23/6"
+	if len(sorted) > 2 {
+		step := sorted[1] - sorted[0]
+		even := step > 1
+		for i := 2; i < len(sorted) && even; i++ {
+			even = sorted[i]-sorted[i-1] == step
+		}
+		if even && sorted[len(sorted)-1]+step > max {
+			if sorted[0] == min {
+				return fmt.Sprintf("*/%d", step)
+			}
+			return fmt.Sprintf("%d-%d/%d", sorted[0], max, step)
+		}
+	}
+
+	parts := []string{}
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] == sorted[j]+1 {
+			j++
+		}
+		if j-i >= 2 {
+			parts = append(parts, fmt.Sprintf("%d-%d", sorted[i], sorted[j]))
+		} else {
+			for k := i; k <= j; k++ {
+				parts = append(parts, strconv.Itoa(sorted[k]))
+			}
+		}
+		i = j + 1
+	}
+
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,169 @@
+package schedule
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// Monday
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr    string
+		want    time.Time
+		wantErr bool
+	}{
+		{expr: "0 3 * * *", want: time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)},
+		{expr: "30 2 * * 3", want: time.Date(2024, 1, 3, 2, 30, 0, 0, time.UTC)},
+		{expr: "0 1 * * 7", want: time.Date(2024, 1, 7, 1, 0, 0, 0, time.UTC)},
+		{expr: "0 1 * * 6-7", want: time.Date(2024, 1, 6, 1, 0, 0, 0, time.UTC)},
+		{expr: "0 1 * *", wantErr: true},
+		{expr: "0 25 * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSchedule(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !schedule.Next(start).Equal(tt.want) {
+			t.Errorf("ParseSchedule(%q) next run = %s, want %s", tt.expr, schedule.Next(start), tt.want)
+		}
+	}
+}
+
+func TestExpandField(t *testing.T) {
+	tests := []struct {
+		field   string
+		want    []int
+		wantErr bool
+	}{
+		{field: "*", want: []int{0, 1, 2, 3, 4, 5, 6}},
+		{field: "1-4", want: []int{1, 2, 3, 4}},
+		{field: "*/3", want: []int{0, 3, 6}},
+		{field: "2/2", want: []int{2, 4, 6}},
+		{field: "5,1,3-4", want: []int{1, 3, 4, 5}},
+		{field: "4-2", wantErr: true},
+		{field: "8", wantErr: true},
+		{field: "*/0", wantErr: true},
+		{field: "mon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ExpandField(tt.field, 0, 6)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExpandField(%q) error = %v, wantErr %v", tt.field, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandField(%q) = %v, want %v", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestFormatField(t *testing.T) {
+	tests := []struct {
+		values []int
+		want   string
+	}{
+		{values: nil, want: "*"},
+		{values: []int{5}, want: "5"},
+		{values: []int{1, 2}, want: "1,2"},
+		{values: []int{3, 1, 2, 4}, want: "1-4"},
+		{values: []int{0, 6, 12, 18}, want: "*/6"},
+		{values: []int{3, 9, 15, 21}, want: "3-23/6"},
+		{values: []int{1, 5, 9}, want: "1,5,9"},
+		{values: []int{0, 1, 2, 7, 9, 10, 11}, want: "0-2,7,9-11"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatField(tt.values, 0, 23); got != tt.want {
+			t.Errorf("FormatField(%v) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+
+	// Every value of the range
+	if got := FormatField([]int{0, 1, 2, 3, 4, 5, 6}, 0, 6); got != "*" {
+		t.Errorf("FormatField(all days) = %q, want *", got)
+	}
+}
+
+func TestShiftHours(t *testing.T) {
+	tests := []struct {
+		expr  string
+		delta int
+		want  string
+	}{
+		{expr: "0 3 * * *", delta: 2, want: "0 5 * * *"},
+		{expr: "0 22 * * 1-4", delta: 4, want: "0 2 * * 1-4"},
+		{expr: "0 1,13 * * *", delta: -2, want: "0 11,23 * * *"},
+		{expr: "0 */6 * * *", delta: 1, want: "0 1-23/6 * * *"},
+		{expr: "0 * * * *", delta: 5, want: "0 * * * *"},
+		{expr: "0 3 * * *", delta: 24, want: "0 3 * * *"},
+	}
+
+	for _, tt := range tests {
+		expr, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.expr, err)
+		}
+		got, err := expr.ShiftHours(tt.delta)
+		if err != nil {
+			t.Errorf("ShiftHours(%q, %d) error = %v", tt.expr, tt.delta, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ShiftHours(%q, %d) = %q, want %q", tt.expr, tt.delta, got, tt.want)
+		}
+	}
+}
+
+func TestShiftMinutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		minutes int
+		want    string
+		wantErr string
+	}{
+		{name: "whole hours", expr: "0 3 * * 1-4", minutes: 240, want: "0 7 * * 1-4"},
+		{name: "minutes carried into the hour", expr: "45 3 * * *", minutes: 30, want: "15 4 * * *"},
+		{name: "earlier", expr: "15 3 * * *", minutes: -30, want: "45 2 * * *"},
+		{name: "every day past midnight", expr: "0 22 * * *", minutes: 180, want: "0 1 * * *"},
+		{name: "weekdays past midnight", expr: "0 4 * * 1-4", minutes: 20 * 60, want: "0 0 * * 2-5"},
+		{name: "weekdays wrapping to sunday", expr: "30 23 * * 5,6", minutes: 60, want: "30 0 * * 0,6"},
+		{name: "weekdays before midnight", expr: "0 1 * * 1", minutes: -120, want: "0 23 * * 0"},
+		{name: "several days later", expr: "0 12 * * 1", minutes: 3 * 24 * 60, want: "0 12 * * 4"},
+		{name: "every hour of every day", expr: "0 * * * *", minutes: 90, want: "30 * * * *"},
+		{name: "minute not fixed", expr: "*/15 3 * * *", minutes: 30, wantErr: "minute field is not a single value"},
+		{name: "day of month past midnight", expr: "0 22 1 * *", minutes: 180, wantErr: "another day of month"},
+		{name: "runs split across midnight", expr: "0 12,23 * * 1-5", minutes: 120, wantErr: "different days of the week"},
+		{name: "every hour of some days", expr: "0 * * * 1", minutes: 60, wantErr: "different days of the week"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.expr, err)
+			}
+			got, err := expr.ShiftMinutes(tt.minutes)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ShiftMinutes(%q, %d) error = %v, want %q", tt.expr, tt.minutes, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ShiftMinutes(%q, %d) error = %v", tt.expr, tt.minutes, err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("ShiftMinutes(%q, %d) = %q, want %q", tt.expr, tt.minutes, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/sherine-k/leases/pkg/config"
+)
+
+// exampleConfig pre-fills the form
+const exampleConfig = `maxActiveLeases: 3
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 7d
+
+jobs:
+  - name: e2e-aws-4.19
+    version: "4.19"
+    duration: 3h
+    triggerType: cron
+    cronSchedule: "0 1 * * *"
+  - name: upgrade-4.19
+    version: "4.19"
+    duration: 5h
+    triggerType: cron
+    cronSchedule: "30 1 * * *"
+  - name: rc-e2e-4.20
+    version: "4.20"
+    duration: 2h
+    triggerType: release-controller
+`
+
+var formTemplate = template.Must(template.New("form").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>CI Job Lease Simulator</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+textarea { width: 100%; max-width: 60em; height: 30em; font-family: monospace; }
+</style>
+</head>
+<body>
+<h1>CI Job Lease Simulator</h1>
+<form method="post" action="/api/report">
+<p><textarea name="config" spellcheck="false">{{.Config}}</textarea></p>
+<p>
+<label>Seed <input name="seed" type="number" placeholder="random"></label>
+<button type="submit">HTML report</button>
+<button type="submit" formaction="/api/chart.svg">SVG chart</button>
+<button type="submit" formaction="/api/simulate">JSON</button>
+</p>
+</form>
+<p>Configurations up to {{.MaxBodyBytes}} bytes simulating at most {{.MaxSimulationDuration}} are accepted.
+Includes are not supported: paste a single file.</p>
+</body>
+</html>
+`))
+
+func (s *Server) handleForm(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := formTemplate.Execute(w, map[string]interface{}{
+		"Config":                exampleConfig,
+		"MaxBodyBytes":          s.opts.MaxBodyBytes,
+		"MaxSimulationDuration": config.Duration{Duration: s.opts.MaxSimulationDuration},
+	})
+	if err != nil {
+		s.logger.Warn("failed to write form", "error", err)
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// simulateResponse is the JSON result of a simulation. Durations use the
+// configuration format, e.g. "1h30m".
+type simulateResponse struct {
+	Seed       int64               `json:"seed"`
+	Start      time.Time           `json:"start"`
+	End        time.Time           `json:"end"`
+	Metrics    metricsResponse     `json:"metrics"`
+	TimePoints []timePointResponse `json:"timePoints"`
+	Warnings   []warningResponse   `json:"warnings"`
+	Runs       []runResponse       `json:"runs"`
+}
+
+type metricsResponse struct {
+	JobInstances                  int             `json:"jobInstances"`
+	WaitedJobs                    int             `json:"waitedJobs"`
+	WaitTimeouts                  int             `json:"waitTimeouts"`
+	ReleaseControllerWaitedJobs   int             `json:"releaseControllerWaitedJobs"`
+	ReleaseControllerWaitTimeouts int             `json:"releaseControllerWaitTimeouts"`
+	ExecTimeouts                  int             `json:"execTimeouts"`
+	Preemptions                   int             `json:"preemptions,omitempty"`
+	Retries                       int             `json:"retries,omitempty"`
+	MaxExceeded                   int             `json:"maxExceeded"`
+	PeakActiveLeases              int             `json:"peakActiveLeases"`
+	PeakDemand                    int             `json:"peakDemand"`
+	TotalWait                     config.Duration `json:"totalWait"`
+	P50Wait                       config.Duration `json:"p50Wait"`
+	P95Wait                       config.Duration `json:"p95Wait"`
+	MaxWait                       config.Duration `json:"maxWait"`
+	LeaseHours                    float64         `json:"leaseHours"`
+	SetupLeaseHours               float64         `json:"setupLeaseHours,omitempty"`
+	TeardownLeaseHours            float64         `json:"teardownLeaseHours,omitempty"`
+	PreemptedLeaseHours           float64         `json:"preemptedLeaseHours,omitempty"`
+	Utilization                   float64         `json:"utilization"`
+	TimeAtCapacity                config.Duration `json:"timeAtCapacity"`
+}
+
+type timePointResponse struct {
+	Time           time.Time `json:"time"`
+	ActiveLeases   int       `json:"activeLeases"`
+	WaitingJobs    int       `json:"waitingJobs"`
+	MaxLeases      int       `json:"maxLeases"`
+	DirtyLeases    int       `json:"dirtyLeases,omitempty"`
+	CleaningLeases int       `json:"cleaningLeases,omitempty"`
+}
+
+type warningResponse struct {
+	Time    time.Time            `json:"time"`
+	Type    simulation.EventType `json:"type"`
+	Job     string               `json:"job,omitempty"`
+	Message string               `json:"message"`
+}
+
+type runResponse struct {
+	Job               string          `json:"job"`
+	ScheduledTime     time.Time       `json:"scheduledTime"`
+	LeaseAcquiredTime time.Time       `json:"leaseAcquiredTime,omitzero"`
+	FinishedTime      time.Time       `json:"finishedTime,omitzero"`
+	LeaseWaitTime     config.Duration `json:"leaseWaitTime"`
+	Outcome           config.JobState `json:"outcome,omitempty"`
+}
+
+type errorResponse struct {
+	Error    string            `json:"error"`
+	Problems []problemResponse `json:"problems,omitempty"`
+}
+
+type problemResponse struct {
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func newSimulateResponse(run *simulationRun) simulateResponse {
+	result := run.result
+	m := result.Metrics
+	response := simulateResponse{
+		Seed:  run.seed,
+		Start: result.Start,
+		End:   result.End,
+		Metrics: metricsResponse{
+			JobInstances:                  m.JobInstances,
+			WaitedJobs:                    m.WaitedJobs,
+			WaitTimeouts:                  m.WaitTimeouts,
+			ReleaseControllerWaitedJobs:   m.ReleaseControllerWaitedJobs,
+			ReleaseControllerWaitTimeouts: m.ReleaseControllerWaitTimeouts,
+			ExecTimeouts:                  m.ExecTimeouts,
+			Preemptions:                   m.Preemptions,
+			Retries:                       m.Retries,
+			MaxExceeded:                   m.MaxExceeded,
+			PeakActiveLeases:              m.PeakActiveLeases,
+			PeakDemand:                    m.PeakDemand,
+			TotalWait:                     config.Duration{Duration: m.TotalWait},
+			P50Wait:                       config.Duration{Duration: m.P50Wait},
+			P95Wait:                       config.Duration{Duration: m.P95Wait},
+			MaxWait:                       config.Duration{Duration: m.MaxWait},
+			LeaseHours:                    m.LeaseHours,
+			SetupLeaseHours:               m.SetupLeaseHours,
+			TeardownLeaseHours:            m.TeardownLeaseHours,
+			PreemptedLeaseHours:           m.PreemptedLeaseHours,
+			Utilization:                   m.Utilization,
+			TimeAtCapacity:                config.Duration{Duration: m.TimeAtCapacity},
+		},
+		TimePoints: make([]timePointResponse, 0, len(result.TimePoints)),
+		Warnings:   make([]warningResponse, 0, len(result.Warnings)),
+		Runs:       make([]runResponse, 0, len(result.Instances)),
+	}
+
+	for _, tp := range result.TimePoints {
+		response.TimePoints = append(response.TimePoints, timePointResponse{
+			Time:           tp.Time,
+			ActiveLeases:   tp.ActiveLeases,
+			WaitingJobs:    tp.WaitingJobs,
+			MaxLeases:      tp.MaxLeases,
+			DirtyLeases:    tp.DirtyLeases,
+			CleaningLeases: tp.CleaningLeases,
+		})
+	}
+	for _, event := range result.Warnings {
+		warning := warningResponse{Time: event.Time, Type: event.Type, Message: event.Message}
+		if event.JobInstance != nil {
+			warning.Job = event.JobInstance.Job.Name
+		}
+		response.Warnings = append(response.Warnings, warning)
+	}
+	for _, instance := range result.Instances {
+		response.Runs = append(response.Runs, runResponse{
+			Job:               instance.Job.Name,
+			ScheduledTime:     instance.ScheduledTime,
+			LeaseAcquiredTime: instance.LeaseAcquiredTime,
+			FinishedTime:      instance.FinishedTime,
+			LeaseWaitTime:     config.Duration{Duration: instance.LeaseWaitTime},
+			Outcome:           instance.Outcome,
+		})
+	}
+
+	return response
+}
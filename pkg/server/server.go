@@ -0,0 +1,288 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/chart"
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// Options limits the simulations the server runs
+type Options struct {
+	// MaxBodyBytes is the largest configuration accepted
+	MaxBodyBytes int64
+	// MaxSimulationDuration is the longest simulationDuration accepted
+	MaxSimulationDuration time.Duration
+	// MaxJobs is the highest number of jobs accepted, after template expansion
+	MaxJobs int
+	// MaxInstances is the highest number of job runs a configuration may
+	// trigger, as schedules firing every minute make few jobs run many times
+	MaxInstances int
+	// Timeout is how long a simulation may run
+	Timeout time.Duration
+	// Logger reports the simulations run, discarded when nil
+	Logger *slog.Logger
+}
+
+// DefaultOptions returns limits suitable for a server shared by a team
+func DefaultOptions() Options {
+	return Options{
+		MaxBodyBytes:          1 << 20,
+		MaxSimulationDuration: 31 * config.Day,
+		MaxJobs:               2000,
+		MaxInstances:          200000,
+		Timeout:               30 * time.Second,
+	}
+}
+
+// Server simulates the configurations posted to its API:
+//
+//	GET  /                  form to paste a configuration
+//	POST /api/simulate      metrics, time points, warnings and runs as JSON
+//	POST /api/chart.svg     lease usage chart as SVG
+//	POST /api/report        HTML report with the chart, metrics and warnings
+//
+// The configuration is the YAML request body, or the "config" field of a
+// form. The optional "seed" and "start" (RFC 3339) parameters make runs
+// reproducible.
+type Server struct {
+	opts   Options
+	logger *slog.Logger
+	mux    *http.ServeMux
+}
+
+// New creates a server with the given limits
+func New(opts Options) *Server {
+	s := &Server{
+		opts:   opts,
+		logger: opts.Logger,
+		mux:    http.NewServeMux(),
+	}
+	if s.logger == nil {
+		s.logger = slog.New(slog.DiscardHandler)
+	}
+
+	s.mux.HandleFunc("GET /{$}", s.handleForm)
+	s.mux.HandleFunc("POST /api/simulate", s.handleSimulate)
+	s.mux.HandleFunc("POST /api/chart.svg", s.handleChart)
+	s.mux.HandleFunc("POST /api/report", s.handleReport)
+	return s
+}
+
+// ServeHTTP serves a request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// requestError is an error reported to the client with an HTTP status
+type requestError struct {
+	status int
+	err    error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// simulationRun is the outcome of simulating a posted configuration
+type simulationRun struct {
+	config *config.Config
+	seed   int64
+	result *simulation.Result
+}
+
+// simulate reads the configuration of a request and simulates it within the limits
+func (s *Server) simulate(w http.ResponseWriter, r *http.Request) (*simulationRun, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxBodyBytes)
+	data, params, err := readConfig(r, s.opts.MaxBodyBytes)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, &requestError{http.StatusRequestEntityTooLarge, fmt.Errorf("configuration larger than %d bytes", maxBytesErr.Limit)}
+		}
+		return nil, &requestError{http.StatusBadRequest, fmt.Errorf("failed to read configuration: %w", err)}
+	}
+
+	cfg, err := config.Parse("config.yaml", data)
+	if err != nil {
+		return nil, &requestError{http.StatusUnprocessableEntity, err}
+	}
+	if limit := s.opts.MaxSimulationDuration; limit > 0 && cfg.SimulationDuration.Duration > limit {
+		return nil, &requestError{http.StatusUnprocessableEntity, fmt.Errorf("simulationDuration %s is longer than the limit of %s", cfg.SimulationDuration, config.Duration{Duration: limit})}
+	}
+	if limit := s.opts.MaxJobs; limit > 0 && len(cfg.Jobs) > limit {
+		return nil, &requestError{http.StatusUnprocessableEntity, fmt.Errorf("%d jobs is more than the limit of %d", len(cfg.Jobs), limit)}
+	}
+
+	seed := time.Now().UnixNano()
+	if value := params.Get("seed"); value != "" {
+		if seed, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, &requestError{http.StatusBadRequest, fmt.Errorf("invalid seed %q", value)}
+		}
+	}
+	start := simulation.LastMonday(time.Now())
+	if value := params.Get("start"); value != "" {
+		if start, err = time.Parse(time.RFC3339, value); err != nil {
+			return nil, &requestError{http.StatusBadRequest, fmt.Errorf("invalid start %q, expected an RFC 3339 time", value)}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.opts.Timeout)
+	defer cancel()
+
+	began := time.Now()
+	sim := simulation.NewSimulator(cfg, simulation.WithStartTime(start), simulation.WithSeed(seed), simulation.WithLogger(s.logger), simulation.WithMaxInstances(s.opts.MaxInstances))
+	result, err := sim.Run(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, &requestError{http.StatusServiceUnavailable, fmt.Errorf("simulation took longer than %s", s.opts.Timeout)}
+		}
+		if errors.Is(err, simulation.ErrTooManyInstances) {
+			return nil, &requestError{http.StatusBadRequest, err}
+		}
+		return nil, fmt.Errorf("simulation failed: %w", err)
+	}
+	s.logger.Info("simulated configuration", "jobs", len(cfg.Jobs), "duration", cfg.SimulationDuration, "seed", seed, "elapsed", time.Since(began))
+
+	return &simulationRun{config: cfg, seed: seed, result: result}, nil
+}
+
+// readConfig returns the configuration of a request and its parameters,
+// from the query and the form fields. The configuration is the "config"
+// field or file of forms, or else the body, so that clients posting YAML with a
+// form content type, such as curl by default, are also understood.
+func readConfig(r *http.Request, maxMemory int64) ([]byte, url.Values, error) {
+	params := r.URL.Query()
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		// The body size is already limited, so the form can be kept in memory
+		if err := r.ParseMultipartForm(maxMemory); err != nil {
+			return nil, nil, err
+		}
+		for key, values := range r.MultipartForm.Value {
+			params[key] = values
+		}
+		if files := r.MultipartForm.File["config"]; len(files) > 0 {
+			file, err := files[0].Open()
+			if err != nil {
+				return nil, nil, err
+			}
+			defer file.Close()
+			data, err := io.ReadAll(file)
+			return data, params, err
+		}
+		return []byte(params.Get("config")), params, nil
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mediaType == "application/x-www-form-urlencoded" {
+		if form, err := url.ParseQuery(string(data)); err == nil && form.Has("config") {
+			for key, values := range form {
+				params[key] = values
+			}
+			return []byte(form.Get("config")), params, nil
+		}
+	}
+	return data, params, nil
+}
+
+func (s *Server) handleSimulate(w http.ResponseWriter, r *http.Request) {
+	run, err := s.simulate(w, r)
+	if err != nil {
+		s.writeJSONError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(newSimulateResponse(run)); err != nil {
+		s.logger.Warn("failed to write response", "error", err)
+	}
+}
+
+func (s *Server) handleChart(w http.ResponseWriter, r *http.Request) {
+	run, err := s.simulate(w, r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	svg := chart.NewGenerator().GenerateSVGChart(run.result.TimePoints, run.config.MaxActiveLeases, run.config.ReservedLeases)
+	w.Header().Set("Content-Type", "image/svg+xml")
+	io.WriteString(w, svg)
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	run, err := s.simulate(w, r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	report, err := chart.NewGenerator().GenerateHTMLReport(chart.Report{
+		Title:          fmt.Sprintf("Lease simulation (seed %d)", run.seed),
+		MaxLeases:      run.config.MaxActiveLeases,
+		ReservedLeases: run.config.ReservedLeases,
+		Result:         run.result,
+	})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, report)
+}
+
+// errorStatus returns the HTTP status of an error
+func (s *Server) errorStatus(err error) int {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return reqErr.status
+	}
+	s.logger.Error("request failed", "error", err)
+	return http.StatusInternalServerError
+}
+
+// writeError reports an error as text
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), s.errorStatus(err))
+}
+
+// writeJSONError reports an error as JSON, listing the configuration problems
+func (s *Server) writeJSONError(w http.ResponseWriter, err error) {
+	response := errorResponse{Error: err.Error()}
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		for _, problem := range validationErr.Problems {
+			response.Problems = append(response.Problems, problemResponse{
+				Line:    problem.Position.Line,
+				Column:  problem.Position.Column,
+				Message: problem.Message,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(s.errorStatus(err))
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testConfig = `maxActiveLeases: 1
+jobTimeoutDuration: 4h
+leaseWaitTimeout: 2h
+simulationDuration: 1d
+jobs:
+  - name: e2e
+    duration: 2h
+    triggerType: cron
+    cronSchedule: "0 1 * * *"
+  - name: upgrade
+    duration: 1h
+    triggerType: cron
+    cronSchedule: "0 1 * * *"
+`
+
+// post sends a request to a test server
+func post(t *testing.T, s *Server, target, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestSimulate(t *testing.T) {
+	s := New(DefaultOptions())
+	form := url.Values{"config": {testConfig}, "seed": {"7"}}.Encode()
+
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+	}{
+		{name: "yaml body", target: "/api/simulate?seed=7&start=2024-01-01T00:00:00Z", contentType: "application/yaml", body: testConfig},
+		{name: "yaml body posted as a form", target: "/api/simulate?seed=7&start=2024-01-01T00:00:00Z", contentType: "application/x-www-form-urlencoded", body: testConfig},
+		{name: "form field", target: "/api/simulate?start=2024-01-01T00:00:00Z", contentType: "application/x-www-form-urlencoded", body: form},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(t, s, tt.target, tt.contentType, tt.body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+			}
+
+			var response simulateResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("invalid JSON response: %v", err)
+			}
+			if response.Seed != 7 || response.Start.Format("2006-01-02") != "2024-01-01" {
+				t.Errorf("seed, start = %d, %v, want 7, 2024-01-01", response.Seed, response.Start)
+			}
+			// upgrade waits for e2e to release the single lease at 03:00
+			if m := response.Metrics; m.JobInstances != 2 || m.WaitedJobs != 1 || m.MaxWait.String() != "2h" {
+				t.Errorf("metrics = %+v, want 2 instances and 1 job waiting 2h", m)
+			}
+			if len(response.Runs) != 2 || len(response.TimePoints) == 0 {
+				t.Errorf("got %d runs and %d time points, want 2 runs and time points", len(response.Runs), len(response.TimePoints))
+			}
+		})
+	}
+}
+
+func TestSimulateErrors(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MaxBodyBytes = 1024
+	opts.MaxInstances = 500
+	s := New(opts)
+
+	tests := []struct {
+		name     string
+		target   string
+		body     string
+		status   int
+		problems int
+		message  string
+	}{
+		{
+			name:     "invalid configuration",
+			target:   "/api/simulate",
+			body:     "maxActiveLeases: 0\nunknown: 1\n",
+			status:   http.StatusUnprocessableEntity,
+			problems: 6,
+			message:  "field unknown not found",
+		},
+		{
+			name:    "too large",
+			target:  "/api/simulate",
+			body:    testConfig + strings.Repeat("#", 1024),
+			status:  http.StatusRequestEntityTooLarge,
+			message: "configuration larger than 1024 bytes",
+		},
+		{
+			name:    "simulation too long",
+			target:  "/api/simulate",
+			body:    strings.Replace(testConfig, "simulationDuration: 1d", "simulationDuration: 6w", 1),
+			status:  http.StatusUnprocessableEntity,
+			message: "simulationDuration 42d is longer than the limit of 31d",
+		},
+		{
+			name:    "too many runs",
+			target:  "/api/simulate",
+			body:    strings.Replace(testConfig, `cronSchedule: "0 1 * * *"`, `cronSchedule: "* * * * *"`, 1),
+			status:  http.StatusBadRequest,
+			message: "the configuration triggers more than 500 job runs",
+		},
+		{
+			name:    "invalid seed",
+			target:  "/api/simulate?seed=abc",
+			body:    testConfig,
+			status:  http.StatusBadRequest,
+			message: `invalid seed "abc"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(t, s, tt.target, "application/yaml", tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			var response errorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("invalid JSON response: %v", err)
+			}
+			if !strings.Contains(response.Error, tt.message) {
+				t.Errorf("error = %q, want it to contain %q", response.Error, tt.message)
+			}
+			if len(response.Problems) != tt.problems {
+				t.Errorf("got %d problems, want %d: %+v", len(response.Problems), tt.problems, response.Problems)
+			}
+		})
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	s := New(DefaultOptions())
+
+	tests := []struct {
+		method      string
+		target      string
+		contentType string
+		contains    string
+	}{
+		{method: http.MethodGet, target: "/", contentType: "text/html; charset=utf-8", contains: `<form method="post" action="/api/report">`},
+		{method: http.MethodPost, target: "/api/chart.svg?seed=1", contentType: "image/svg+xml", contains: "<svg"},
+		{method: http.MethodPost, target: "/api/report?seed=1", contentType: "text/html; charset=utf-8", contains: "Lease simulation (seed 1)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(testConfig))
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if !strings.Contains(w.Body.String(), tt.contains) {
+				t.Errorf("body does not contain %q", tt.contains)
+			}
+		})
+	}
+}
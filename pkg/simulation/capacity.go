@@ -0,0 +1,186 @@
+package simulation
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/schedule"
+)
+
+// capacity is the lease capacity in effect at some time of a run
+type capacity struct {
+	maxLeases int
+	policy    config.CapacityPolicy
+	// label names the capacity change in effect, empty for maxActiveLeases
+	label string
+}
+
+// capacityPeriod is a period during which a capacity change applies. The
+// periods of the changes made from a point in time on have no end.
+type capacityPeriod struct {
+	capacity
+	start time.Time
+	end   time.Time
+}
+
+// capacitySchedule gives the capacity in effect at any time of a run
+type capacitySchedule struct {
+	base capacity
+	// changes are the periods without end, sorted by start
+	changes []capacityPeriod
+	// windows are the periods with an end
+	windows []capacityPeriod
+}
+
+// newCapacitySchedule expands the capacity schedule of a configuration into
+// the periods overlapping the simulation window from start to end
+func (r *run) newCapacitySchedule(start, end time.Time) *capacitySchedule {
+	s := &capacitySchedule{
+		base: capacity{maxLeases: r.config.MaxActiveLeases, policy: config.CapacityPolicyDrain},
+	}
+
+	for i := range r.config.CapacitySchedule {
+		change := &r.config.CapacitySchedule[i]
+		c := capacity{maxLeases: change.MaxActiveLeases, policy: change.Policy, label: change.Label(i)}
+		if c.policy == "" {
+			c.policy = config.CapacityPolicyDrain
+		}
+		duration := change.Duration.Duration
+
+		switch {
+		case !change.IsWindow():
+			s.changes = append(s.changes, capacityPeriod{capacity: c, start: change.At})
+		case change.CronSchedule == "":
+			s.windows = append(s.windows, capacityPeriod{capacity: c, start: change.At, end: change.At.Add(duration)})
+		default:
+			cronSchedule, err := schedule.ParseSchedule(change.CronSchedule)
+			if err != nil {
+				r.logger.Warn("failed to parse capacity cron schedule", "change", c.label, "error", err)
+				continue
+			}
+			// Windows started before the simulation may still be open at its start
+			for t := cronSchedule.Next(start.Add(-duration)); !t.IsZero() && t.Before(end); t = cronSchedule.Next(t) {
+				s.windows = append(s.windows, capacityPeriod{capacity: c, start: t, end: t.Add(duration)})
+			}
+		}
+	}
+
+	sort.SliceStable(s.changes, func(i, j int) bool {
+		return s.changes[i].start.Before(s.changes[j].start)
+	})
+
+	return s
+}
+
+// at returns the capacity in effect at a time: the one of the last change
+// made by then, or maxActiveLeases, unless windows are open, the lowest
+// capacity of the open windows then applying
+func (s *capacitySchedule) at(t time.Time) capacity {
+	current := s.base
+	for _, change := range s.changes {
+		if change.start.After(t) {
+			break
+		}
+		current = change.capacity
+	}
+
+	var lowest *capacityPeriod
+	for i := range s.windows {
+		window := &s.windows[i]
+		if window.start.After(t) || !t.Before(window.end) {
+			continue
+		}
+		if lowest == nil || window.maxLeases < lowest.maxLeases {
+			lowest = window
+		}
+	}
+	if lowest != nil {
+		return lowest.capacity
+	}
+	return current
+}
+
+// updateCapacity applies the capacity in effect at the current time. Jobs are
+// preempted when the leases in use exceed a capacity with the preempt policy,
+// and waiting jobs acquire the leases a capacity increase frees.
+func (r *run) updateCapacity(state *leaseState, currentTime time.Time) error {
+	previous := state.capacity
+	state.capacity = r.capacity.at(currentTime)
+
+	if state.capacity.maxLeases != previous.maxLeases {
+		message := fmt.Sprintf("Capacity changed to %d leases (%s)", state.capacity.maxLeases, state.capacity.label)
+		if state.capacity.label == "" {
+			message = fmt.Sprintf("Capacity back to %d leases", state.capacity.maxLeases)
+		}
+		r.addEvent(Event{
+			Time:         currentTime,
+			Type:         EventTypeCapacityChanged,
+			ActiveLeases: state.activeLeases,
+			Message:      message,
+			MaxLeases:    state.capacity.maxLeases,
+		})
+	}
+
+	if state.capacity.policy == config.CapacityPolicyPreempt {
+		if err := r.preempt(state, currentTime); err != nil {
+			return err
+		}
+	}
+
+	if state.capacity.maxLeases > previous.maxLeases {
+		for r.nextWaitingJob(state) >= 0 {
+			if err := r.handOff(state, currentTime); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// preempt stops running jobs until the leases in use or being cleaned up fit
+// the capacity, the lowest priority first, then periodic jobs first and the
+// most recently started first
+func (r *run) preempt(state *leaseState, currentTime time.Time) error {
+	if state.unavailableLeases() <= state.capacity.maxLeases {
+		return nil
+	}
+
+	candidates := append([]*config.JobInstance{}, state.running...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Job.Priority != b.Job.Priority {
+			return a.Job.Priority < b.Job.Priority
+		}
+		if a.Job.ReleaseController() != b.Job.ReleaseController() {
+			return !a.Job.ReleaseController()
+		}
+		return a.LeaseAcquiredTime.After(b.LeaseAcquiredTime)
+	})
+
+	preempted := []*config.JobInstance{}
+	for _, job := range candidates {
+		if state.unavailableLeases() <= state.capacity.maxLeases {
+			break
+		}
+
+		reason := fmt.Sprintf("Job '%s' preempted, capacity reduced to %d leases (%s)", job.Job.Name, state.capacity.maxLeases, state.capacity.label)
+		if err := r.evict(state, job, nil, reason, currentTime); err != nil {
+			return err
+		}
+		if err := r.freeLease(state, job, currentTime); err != nil {
+			return err
+		}
+		preempted = append(preempted, job)
+	}
+
+	for _, job := range preempted {
+		if err := r.afterPreemption(state, job, currentTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -15,6 +15,10 @@ const (
 	EventTypeJobWaiting    EventType = "job-waiting"
 	EventTypeJobTimeout    EventType = "job-timeout"
 	EventTypeMaxExceeded   EventType = "max-exceeded"
+	EventTypeLeaseCleaned  EventType = "lease-cleaned"
+
+	EventTypeCapacityChanged EventType = "capacity-changed"
+	EventTypeJobPreempted    EventType = "job-preempted"
 )
 
 // Event represents a point-in-time event in the simulation
@@ -25,6 +29,9 @@ type Event struct {
 	ActiveLeases int
 	Message      string
 	IsWarning    bool
+
+	// MaxLeases is the capacity in effect from a capacity-changed event on
+	MaxLeases int
 }
 
 // TimePoint represents the state at a specific point in time
@@ -32,4 +39,23 @@ type TimePoint struct {
 	Time         time.Time
 	ActiveLeases int
 	WaitingJobs  int
+
+	// MaxLeases is the capacity in effect, which the capacity schedule may
+	// change from maxActiveLeases
+	MaxLeases int
+
+	// DirtyLeases were released since the last tick and wait for the janitor,
+	// CleaningLeases are being cleaned up; neither can be acquired
+	DirtyLeases    int
+	CleaningLeases int
+
+	// Pools and Versions break the usage down by job pool and job version
+	Pools    map[string]Usage
+	Versions map[string]Usage
+}
+
+// Usage is the number of leases in use and of jobs waiting for one
+type Usage struct {
+	ActiveLeases int
+	WaitingJobs  int
 }
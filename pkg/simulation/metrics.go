@@ -0,0 +1,199 @@
+package simulation
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+)
+
+// Metrics summarises the outcome of a simulation run
+type Metrics struct {
+	JobInstances int
+	WaitedJobs   int
+	WaitTimeouts int
+
+	// Release controller jobs are also counted in the totals above
+	ReleaseControllerWaitedJobs   int
+	ReleaseControllerWaitTimeouts int
+
+	ExecTimeouts     int
+	Preemptions      int // Jobs evicted by higher priority jobs or stopped to fit a reduced capacity
+	MaxExceeded      int
+	PeakActiveLeases int
+	PeakDemand       int // Highest number of active plus waiting jobs
+	TotalWait        time.Duration
+	P50Wait          time.Duration
+	P95Wait          time.Duration
+	MaxWait          time.Duration
+	LeaseHours       float64
+	Utilization      float64 // Fraction of the available lease capacity in use
+	TimeAtCapacity   time.Duration
+
+	// Lease-hours spent setting up and tearing down, also counted in LeaseHours
+	SetupLeaseHours    float64
+	TeardownLeaseHours float64
+
+	// PriorityPreemptions are the preemptions by higher priority jobs, and
+	// Retries the runs retrying preempted ones
+	PriorityPreemptions int
+	Retries             int
+	// PreemptedLeaseHours is the lease time held by the preempted runs,
+	// wasted since they did not complete, also counted in LeaseHours
+	PreemptedLeaseHours float64
+}
+
+// usageStats accumulates per-tick lease usage during the simulation
+type usageStats struct {
+	tick             time.Duration
+	leaseTime        time.Duration
+	capacityTime     time.Duration // Lease capacity over time, in lease-time
+	timeAtCapacity   time.Duration
+	peakActiveLeases int
+	peakDemand       int
+	maxExceeded      int
+}
+
+// record accounts for one simulation tick starting at t. Only ticks inside
+// the simulation window contribute to utilisation and time at capacity, which
+// includes the time without a free lease because of cleanups.
+func (u *usageStats) record(t, end time.Time, activeLeases, cleanupLeases, waitingJobs, maxLeases int) {
+	if u.tick == 0 {
+		u.tick = 5 * time.Minute
+	}
+
+	if activeLeases > u.peakActiveLeases {
+		u.peakActiveLeases = activeLeases
+	}
+	if demand := activeLeases + waitingJobs; demand > u.peakDemand {
+		u.peakDemand = demand
+	}
+
+	if !t.Before(end) {
+		return
+	}
+	u.leaseTime += time.Duration(activeLeases) * u.tick
+	u.capacityTime += time.Duration(maxLeases) * u.tick
+	if activeLeases+cleanupLeases >= maxLeases {
+		u.timeAtCapacity += u.tick
+	}
+}
+
+// computeMetrics derives the run metrics from the job instances and usage
+// statistics of the simulation window from start to end
+func computeMetrics(instances []*config.JobInstance, stats usageStats, start, end time.Time) Metrics {
+	m := Metrics{
+		JobInstances:     len(instances),
+		PeakActiveLeases: stats.peakActiveLeases,
+		PeakDemand:       stats.peakDemand,
+		LeaseHours:       stats.leaseTime.Hours(),
+		TimeAtCapacity:   stats.timeAtCapacity,
+		MaxExceeded:      stats.maxExceeded,
+	}
+
+	waits := make([]time.Duration, 0, len(instances))
+	for _, instance := range instances {
+		releaseController := instance.Job.ReleaseController()
+		switch instance.State {
+		case config.JobStateExecTimeout:
+			m.ExecTimeouts++
+		case config.JobStatePreempted:
+			m.Preemptions++
+			if instance.PreemptedBy != nil {
+				m.PriorityPreemptions++
+			}
+		case config.JobStateWaitTimeout:
+			m.WaitTimeouts++
+			if releaseController {
+				m.ReleaseControllerWaitTimeouts++
+			}
+		}
+		if instance.LeaseWaitTime > 0 {
+			m.WaitedJobs++
+			if releaseController {
+				m.ReleaseControllerWaitedJobs++
+			}
+		}
+		m.TotalWait += instance.LeaseWaitTime
+		waits = append(waits, instance.LeaseWaitTime)
+
+		if instance.RetryOf != nil {
+			m.Retries++
+		}
+
+		setup, test, teardown := LeasePhases(instance, start, end)
+		m.SetupLeaseHours += setup.Hours()
+		m.TeardownLeaseHours += teardown.Hours()
+		if instance.State == config.JobStatePreempted {
+			m.PreemptedLeaseHours += (setup + test + teardown).Hours()
+		}
+	}
+
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+	m.P50Wait = percentile(waits, 0.50)
+	m.P95Wait = percentile(waits, 0.95)
+	if len(waits) > 0 {
+		m.MaxWait = waits[len(waits)-1]
+	}
+
+	if capacity := stats.capacityTime.Hours(); capacity > 0 {
+		m.Utilization = m.LeaseHours / capacity
+	}
+
+	return m
+}
+
+// LeasePhases returns how long a job run held its lease to set up, run its
+// tests and tear down, within the window from start to end
+func LeasePhases(instance *config.JobInstance, start, end time.Time) (setup, test, teardown time.Duration) {
+	if instance.LeaseAcquiredTime.IsZero() {
+		return 0, 0, 0
+	}
+
+	acquired, released := instance.LeaseAcquiredTime, instance.FinishedTime
+	if released.IsZero() {
+		released = end
+	}
+	teardownStart := released
+	if at, ok := instance.StateTimes[config.JobStateTearingDown]; ok {
+		teardownStart = at
+	}
+	setupEnd := acquired.Add(instance.SetupDuration)
+	if setupEnd.After(teardownStart) {
+		setupEnd = teardownStart
+	}
+
+	return overlap(acquired, setupEnd, start, end),
+		overlap(setupEnd, teardownStart, start, end),
+		overlap(teardownStart, released, start, end)
+}
+
+// overlap returns how long the period from a to b overlaps the one from start to end
+func overlap(a, b, start, end time.Time) time.Duration {
+	if a.Before(start) {
+		a = start
+	}
+	if b.After(end) {
+		b = end
+	}
+	if !b.After(a) {
+		return 0
+	}
+	return b.Sub(a)
+}
+
+// percentile returns the p-th percentile (nearest rank) of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
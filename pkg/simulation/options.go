@@ -0,0 +1,108 @@
+package simulation
+
+import (
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+)
+
+// Option configures a Simulator
+type Option func(*Simulator)
+
+// WithStartTime sets the time at which the simulation starts
+func WithStartTime(start time.Time) Option {
+	return func(s *Simulator) {
+		s.start = start
+	}
+}
+
+// WithClock sets the clock used for the default start time and random seed
+func WithClock(now func() time.Time) Option {
+	return func(s *Simulator) {
+		s.clock = now
+	}
+}
+
+// WithRand sets the random source used for release controller triggers. The
+// simulators created with the option share the source.
+func WithRand(rng *rand.Rand) Option {
+	return func(s *Simulator) {
+		s.rng = rng
+		s.seed = nil
+	}
+}
+
+// WithSeed seeds the random source used for release controller triggers,
+// making runs reproducible. Each simulator created with the option has its
+// own source, so that the option can be reused.
+func WithSeed(seed int64) Option {
+	return func(s *Simulator) {
+		s.rng = nil
+		s.seed = &seed
+	}
+}
+
+// WithLogger sets the logger receiving the problems met while simulating
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Simulator) {
+		s.logger = logger
+	}
+}
+
+// WithTick sets the time step of the simulation
+func WithTick(tick time.Duration) Option {
+	return func(s *Simulator) {
+		s.tick = tick
+	}
+}
+
+// WithSampleInterval sets the interval between the time points of the
+// result, 30 minutes by default. Time points sampled more often than the
+// tick repeat the usage of the last tick.
+func WithSampleInterval(interval time.Duration) Option {
+	return func(s *Simulator) {
+		s.sampleInterval = interval
+	}
+}
+
+// WithEventSink sends the events of the runs to a sink instead of keeping
+// them in Result.Events, so that long runs do not retain every event. Use
+// FanOut with a MemorySink to do both.
+func WithEventSink(sink EventSink) Option {
+	return func(s *Simulator) {
+		s.sink = sink
+	}
+}
+
+// WithMaxInstances limits the job runs a run generates, Run failing with an
+// error wrapping ErrTooManyInstances when a configuration triggers more, so
+// that a schedule firing every minute cannot exhaust the memory. There is no
+// limit by default.
+func WithMaxInstances(n int) Option {
+	return func(s *Simulator) {
+		s.maxInstances = n
+	}
+}
+
+// Result is the outcome of a simulation run
+type Result struct {
+	// Start and End delimit the simulated window; jobs still running or
+	// waiting at End are simulated until they finish
+	Start time.Time
+	End   time.Time
+	// Events are all the events of the run in chronological order, unless
+	// they were sent to the sink of WithEventSink
+	Events []Event
+	// TimePoints sample the lease usage over the window for charting
+	TimePoints []TimePoint
+	// Instances are all the job instances, by scheduled time
+	Instances []*config.JobInstance
+	// Warnings are the warning events, kept with Events unless they were
+	// sent to the sink of WithEventSink
+	Warnings []Event
+	// WarningCount is the number of warning events, always counted
+	WarningCount int
+	Metrics      Metrics
+}
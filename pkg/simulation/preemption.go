@@ -0,0 +1,81 @@
+package simulation
+
+import (
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+)
+
+// preemptionVictim returns the running job a job finding no free lease may
+// evict to take its lease: the one of the lowest priority below the job's,
+// the most recently started among equals, or nil if there is none. Jobs
+// tearing down are not evicted, and neither are jobs whose lease the job
+// could not use because of the reserved leases.
+func (r *run) preemptionVictim(state *leaseState, job *config.JobInstance) *config.JobInstance {
+	// Evicting a single job cannot make room while the capacity is draining
+	if state.unavailableLeases() > state.capacity.maxLeases {
+		return nil
+	}
+
+	var victim *config.JobInstance
+	for _, candidate := range state.running {
+		if candidate.CurrentState() != config.JobStateRunning || candidate.Job.Priority >= job.Job.Priority {
+			continue
+		}
+
+		periodicLeases := state.periodicLeases
+		if !candidate.Job.ReleaseController() {
+			periodicLeases--
+		}
+		if !job.Job.ReleaseController() && periodicLeases >= state.capacity.maxLeases-r.config.ReservedLeases {
+			continue
+		}
+
+		if victim == nil || candidate.Job.Priority < victim.Job.Priority ||
+			(candidate.Job.Priority == victim.Job.Priority && candidate.LeaseAcquiredTime.After(victim.LeaseAcquiredTime)) {
+			victim = candidate
+		}
+	}
+	return victim
+}
+
+// evict stops a running job, by a job of a higher priority or by a capacity
+// change when by is nil, and releases its lease. The caller frees the lease.
+func (r *run) evict(state *leaseState, job, by *config.JobInstance, reason string, currentTime time.Time) error {
+	if err := job.Transition(config.JobStatePreempted, currentTime); err != nil {
+		return err
+	}
+	job.PreemptedBy = by
+
+	stillRunning := make([]*config.JobInstance, 0, len(state.running))
+	for _, running := range state.running {
+		if running != job {
+			stillRunning = append(stillRunning, running)
+		}
+	}
+	state.running = stillRunning
+	state.release(job)
+
+	r.addEvent(Event{
+		Time:         currentTime,
+		Type:         EventTypeJobPreempted,
+		JobInstance:  job,
+		ActiveLeases: state.activeLeases,
+		Message:      reason,
+		IsWarning:    true,
+	})
+	return nil
+}
+
+// afterPreemption retries a preempted job when the preemption settings ask
+// for it, the retry keeping the schedule of the preempted run
+func (r *run) afterPreemption(state *leaseState, job *config.JobInstance, currentTime time.Time) error {
+	if r.config.Preemption.OnPreempt != config.PreemptActionRetry {
+		return nil
+	}
+
+	retry := r.newInstance(job.Job, job.ScheduledTime)
+	retry.RetryOf = job
+	r.retries = append(r.retries, retry)
+	return r.enqueue(state, retry, currentTime)
+}
@@ -1,29 +1,71 @@
 package simulation
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"math/rand"
 	"sort"
 	"time"
 
-	"github.com/robfig/cron/v3"
 	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/schedule"
 )
 
-// Simulator runs the lease simulation
+// Simulator runs the lease simulation of a configuration. It holds no state
+// of its own between runs, other than its random source.
 type Simulator struct {
-	config          *config.Config
-	events          []Event
-	timePoints      []TimePoint
-	currentTime     time.Time
-	simulationStart time.Time
-	simulationEnd   time.Time
+	config *config.Config
+	start  time.Time
+	clock  func() time.Time
+	rng    *rand.Rand
+	seed   *int64 // Seed of rng when set by WithSeed
+	logger *slog.Logger
+	tick   time.Duration
+	sink   EventSink
+
+	sampleInterval time.Duration
+	maxInstances   int
+}
+
+// NewSimulator creates a new simulator. By default the simulation starts on
+// the last Monday before now, release controller triggers are random, nothing
+// is logged and time advances in 5-minute ticks.
+func NewSimulator(cfg *config.Config, opts ...Option) *Simulator {
+	s := &Simulator{
+		config: cfg,
+		clock:  time.Now,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		tick:   5 * time.Minute,
+
+		sampleInterval: defaultSampleInterval,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.start.IsZero() {
+		s.start = LastMonday(s.clock())
+	}
+	if s.rng == nil {
+		seed := s.clock().UnixNano()
+		if s.seed != nil {
+			seed = *s.seed
+		}
+		s.rng = rand.New(rand.NewSource(seed))
+	}
+
+	return s
 }
 
-// NewSimulator creates a new simulator
-func NewSimulator(cfg *config.Config) *Simulator {
-	// Calculate last Monday at midnight
-	now := time.Now()
+// ErrTooManyInstances is returned by Run when a configuration triggers more
+// job runs than the limit set by WithMaxInstances
+var ErrTooManyInstances = errors.New("too many job runs")
+
+// LastMonday returns midnight of the most recent Monday on or before now
+func LastMonday(now time.Time) time.Time {
 	weekday := now.Weekday()
 	var daysBack int
 	if weekday == time.Sunday {
@@ -32,49 +74,129 @@ func NewSimulator(cfg *config.Config) *Simulator {
 		daysBack = int(weekday) - 1 // Days since Monday
 	}
 	lastMondayDate := now.AddDate(0, 0, -daysBack)
-	lastMonday := time.Date(lastMondayDate.Year(), lastMondayDate.Month(), lastMondayDate.Day(), 0, 0, 0, 0, time.Local)
+	return time.Date(lastMondayDate.Year(), lastMondayDate.Month(), lastMondayDate.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// defaultSampleInterval is the default interval between the time points
+// sampled for charting
+const defaultSampleInterval = 30 * time.Minute
+
+// run holds the state of one simulation run
+type run struct {
+	*Simulator
+	ctx             context.Context
+	sink            EventSink
+	sinkErr         error
+	warningCount    int
+	timePoints      []TimePoint
+	nextSample      time.Time
+	stats           usageStats
+	capacity        *capacitySchedule
+	retries         []*config.JobInstance
+	generated       int
+	simulationStart time.Time
+	simulationEnd   time.Time
+}
+
+// Run executes the simulation. It stops with the context error when the
+// context is cancelled, or with the error of the event sink.
+func (s *Simulator) Run(ctx context.Context) (*Result, error) {
+	if s.tick <= 0 {
+		return nil, fmt.Errorf("tick must be greater than 0, got %s", s.tick)
+	}
+	if s.sampleInterval <= 0 {
+		return nil, fmt.Errorf("sample interval must be greater than 0, got %s", s.sampleInterval)
+	}
 
-	return &Simulator{
-		config:          cfg,
-		events:          []Event{},
+	var memory *MemorySink
+	sink := s.sink
+	if sink == nil {
+		memory = NewMemorySink()
+		sink = memory
+	}
+
+	r := &run{
+		Simulator:       s,
+		ctx:             ctx,
+		sink:            sink,
 		timePoints:      []TimePoint{},
-		currentTime:     lastMonday,
-		simulationStart: lastMonday,
-		simulationEnd:   lastMonday.Add(cfg.SimulationDuration),
+		nextSample:      s.start,
+		stats:           usageStats{tick: s.tick},
+		simulationStart: s.start,
+		simulationEnd:   s.start.Add(s.config.SimulationDuration.Duration),
 	}
-}
+	r.capacity = r.newCapacitySchedule(r.simulationStart, r.simulationEnd)
 
-// Run executes the simulation
-func (s *Simulator) Run() error {
 	// Generate all job instances for the simulation period
-	jobInstances := s.generateJobInstances()
+	jobInstances, err := r.generateJobInstances()
+	if err != nil {
+		return nil, err
+	}
 
-	// Sort job instances by start time
-	sort.Slice(jobInstances, func(i, j int) bool {
-		return jobInstances[i].StartTime.Before(jobInstances[j].StartTime)
+	// Sort job instances by scheduled time
+	sort.SliceStable(jobInstances, func(i, j int) bool {
+		return jobInstances[i].ScheduledTime.Before(jobInstances[j].ScheduledTime)
 	})
 
 	// Run the simulation
-	s.simulateLeaseUsage(jobInstances)
+	if err := r.simulateLeaseUsage(jobInstances); err != nil {
+		return nil, err
+	}
 
-	// Generate time points for charting
-	s.generateTimePoints()
+	// Retries of preempted runs follow the runs they retry
+	if len(r.retries) > 0 {
+		jobInstances = append(jobInstances, r.retries...)
+		sort.SliceStable(jobInstances, func(i, j int) bool {
+			return jobInstances[i].ScheduledTime.Before(jobInstances[j].ScheduledTime)
+		})
+	}
 
-	return nil
+	result := &Result{
+		Start:        r.simulationStart,
+		End:          r.simulationEnd,
+		TimePoints:   r.timePoints,
+		Instances:    jobInstances,
+		Warnings:     []Event{},
+		WarningCount: r.warningCount,
+		Metrics:      computeMetrics(jobInstances, r.stats, r.simulationStart, r.simulationEnd),
+	}
+	if memory != nil {
+		result.Events = memory.Events
+		for _, event := range memory.Events {
+			if event.IsWarning {
+				result.Warnings = append(result.Warnings, event)
+			}
+		}
+	}
+	return result, nil
+}
+
+// generate counts n job instances about to be generated. It fails when the
+// context is done or the instances exceed the limit of WithMaxInstances, so
+// that generating the runs of a schedule firing too often stops early.
+func (r *run) generate(n int) error {
+	r.generated += n
+	if r.maxInstances > 0 && r.generated > r.maxInstances {
+		return fmt.Errorf("%w: the configuration triggers more than %d job runs", ErrTooManyInstances, r.maxInstances)
+	}
+	return r.ctx.Err()
 }
 
 // generateJobInstances generates all job instances for the simulation period
-func (s *Simulator) generateJobInstances() []*config.JobInstance {
+func (r *run) generateJobInstances() ([]*config.JobInstance, error) {
 	instances := []*config.JobInstance{}
 	releaseControllerJobs := []*config.Job{}
 
-	for i := range s.config.Jobs {
-		job := &s.config.Jobs[i]
+	for i := range r.config.Jobs {
+		job := &r.config.Jobs[i]
 
 		switch job.TriggerType {
 		case config.TriggerTypeCron:
 			// Parse cron schedule and generate instances
-			cronInstances := s.generateCronInstances(job)
+			cronInstances, err := r.generateCronInstances(job)
+			if err != nil {
+				return nil, err
+			}
 			instances = append(instances, cronInstances...)
 		case config.TriggerTypeReleaseController:
 			// Collect all release controller jobs to process together
@@ -84,64 +206,73 @@ func (s *Simulator) generateJobInstances() []*config.JobInstance {
 
 	// Generate instances for all release controller jobs at the same release events
 	if len(releaseControllerJobs) > 0 {
-		rcInstances := s.generateReleaseControllerInstances(releaseControllerJobs)
+		rcInstances, err := r.generateReleaseControllerInstances(releaseControllerJobs)
+		if err != nil {
+			return nil, err
+		}
 		instances = append(instances, rcInstances...)
 	}
 
-	return instances
+	return instances, nil
 }
 
 // generateCronInstances generates job instances based on cron schedule
-func (s *Simulator) generateCronInstances(job *config.Job) []*config.JobInstance {
+func (r *run) generateCronInstances(job *config.Job) ([]*config.JobInstance, error) {
 	instances := []*config.JobInstance{}
 
-	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
-	schedule, err := parser.Parse(job.CronSchedule)
+	cronSchedule, err := schedule.ParseSchedule(job.CronSchedule)
 	if err != nil {
-		fmt.Printf("Warning: failed to parse cron schedule for job %s: %v\n", job.Name, err)
-		return instances
+		r.logger.Warn("failed to parse cron schedule", "job", job.Name, "error", err)
+		return instances, nil
 	}
 
-	currentTime := s.simulationStart
-	for currentTime.Before(s.simulationEnd) {
-		nextRun := schedule.Next(currentTime)
-		if nextRun.After(s.simulationEnd) {
+	currentTime := r.simulationStart
+	for currentTime.Before(r.simulationEnd) {
+		nextRun := cronSchedule.Next(currentTime)
+		if nextRun.After(r.simulationEnd) {
 			break
 		}
 
-		instances = append(instances, &config.JobInstance{
-			Job:       job,
-			StartTime: nextRun,
-			EndTime:   nextRun.Add(job.Duration),
-		})
+		if err := r.generate(1); err != nil {
+			return nil, err
+		}
+		instances = append(instances, r.newInstance(job, nextRun))
 
 		currentTime = nextRun.Add(time.Minute) // Move forward to find next occurrence
 	}
 
-	return instances
+	return instances, nil
 }
 
-// generateReleaseEvents generates release trigger times for a specific version
-func (s *Simulator) generateReleaseEvents() []time.Time {
+// generateReleaseEvents generates release trigger times for a specific
+// version, each triggering the given number of job instances
+func (r *run) generateReleaseEvents(instancesPerEvent int) ([]time.Time, error) {
 	releaseEvents := []time.Time{}
 
-	// Generate release events at somewhat random intervals
-	// Average of one release every 6 hours
-	currentTime := s.simulationStart
+	// Generate release events at random intervals within the configured
+	// bounds, in whole hours when the bounds allow it
+	minInterval, maxInterval := r.config.ReleaseInterval.Bounds()
+	step := time.Hour
+	if (maxInterval-minInterval)%time.Hour != 0 {
+		step = time.Minute
+	}
+	steps := int((maxInterval - minInterval) / step)
 
-	for currentTime.Before(s.simulationEnd) {
+	currentTime := r.simulationStart
+	for currentTime.Before(r.simulationEnd) {
+		if err := r.generate(instancesPerEvent); err != nil {
+			return nil, err
+		}
 		releaseEvents = append(releaseEvents, currentTime)
-
-		// Next release in 4-8 hours (random interval, averaging ~6 hours)
-		currentTime = currentTime.Add(4*time.Hour + time.Duration(rand.Intn(5))*time.Hour)
+		currentTime = currentTime.Add(minInterval + time.Duration(r.rng.Intn(steps+1))*step)
 	}
 
-	return releaseEvents
+	return releaseEvents, nil
 }
 
 // generateReleaseControllerInstances generates job instances for all release controller jobs
 // Jobs are grouped by version, and each version has independent release events
-func (s *Simulator) generateReleaseControllerInstances(jobs []*config.Job) []*config.JobInstance {
+func (r *run) generateReleaseControllerInstances(jobs []*config.Job) ([]*config.JobInstance, error) {
 	instances := []*config.JobInstance{}
 
 	// Group jobs by version
@@ -150,242 +281,475 @@ func (s *Simulator) generateReleaseControllerInstances(jobs []*config.Job) []*co
 		jobsByVersion[job.Version] = append(jobsByVersion[job.Version], job)
 	}
 
+	// Iterate versions in a stable order so that a seeded run is reproducible
+	versions := make([]string, 0, len(jobsByVersion))
+	for version := range jobsByVersion {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+
 	// For each version, generate independent release events
-	for version, versionJobs := range jobsByVersion {
+	for _, version := range versions {
+		versionJobs := jobsByVersion[version]
+
 		// Generate release event times for this version
-		releaseEvents := s.generateReleaseEvents()
+		releaseEvents, err := r.generateReleaseEvents(len(versionJobs))
+		if err != nil {
+			return nil, err
+		}
 
 		// For each release event, create instances for ALL jobs in this version
 		for _, releaseTime := range releaseEvents {
 			for _, job := range versionJobs {
-				instances = append(instances, &config.JobInstance{
-					Job:       job,
-					StartTime: releaseTime,
-					EndTime:   releaseTime.Add(job.Duration),
-				})
+				instances = append(instances, r.newInstance(job, releaseTime))
 			}
 		}
+	}
+
+	return instances, nil
+}
 
-		// Optional: log the number of release events generated for this version
-		_ = version // Use version if needed for debugging
+// newInstance returns a pending instance of a job triggered at the given time
+func (r *run) newInstance(job *config.Job, scheduled time.Time) *config.JobInstance {
+	setup, teardown := r.config.JobPhases(job)
+	return &config.JobInstance{
+		Job:              job,
+		State:            config.JobStatePending,
+		ScheduledTime:    scheduled,
+		StartTime:        scheduled,
+		EndTime:          scheduled.Add(setup + job.Duration.Duration),
+		SetupDuration:    setup,
+		TeardownDuration: teardown,
 	}
+}
+
+// leaseState holds the leases in use and the job instances holding or waiting
+// for them, and the capacity in effect
+type leaseState struct {
+	capacity       capacity
+	activeLeases   int
+	periodicLeases int // Leases held by jobs that are not release controller jobs
+	running        []*config.JobInstance
+	waiting        []*config.JobInstance
+
+	// cleanups are the released leases that are not free again yet
+	cleanups []*cleanup
+}
+
+// cleanup is a released lease of a pool with a cleanup delay. It is dirty
+// until the janitor picks it up at the next tick, then cleaning until it is
+// free again, the cleanup delay after its release.
+type cleanup struct {
+	job      *config.JobInstance // The job instance that released the lease
+	cleaning bool
+	freeAt   time.Time
+}
 
-	return instances
+// unavailableLeases returns the number of leases that cannot be acquired
+func (l *leaseState) unavailableLeases() int {
+	return l.activeLeases + len(l.cleanups)
 }
 
-// simulateLeaseUsage simulates the lease usage over time
-func (s *Simulator) simulateLeaseUsage(jobInstances []*config.JobInstance) {
-	activeLeases := 0
-	activeJobs := []*config.JobInstance{}
-	waitingJobs := []*config.JobInstance{}
+// simulateLeaseUsage simulates the lease usage over time. At every tick, the
+// capacity in effect is applied, then cleaned up leases are freed, then
+// leases are released by completed and timed out jobs, the free ones being
+// handed to waiting jobs in order, then waiting jobs time out, then newly
+// triggered jobs acquire a free lease or start waiting.
+func (r *run) simulateLeaseUsage(jobInstances []*config.JobInstance) error {
+	state := &leaseState{capacity: r.capacity.base}
 
 	// Process all job instances
 	jobIndex := 0
-	currentTime := s.simulationStart
+	currentTime := r.simulationStart
 
-	for currentTime.Before(s.simulationEnd) || len(activeJobs) > 0 || len(waitingJobs) > 0 {
-		// Check for jobs that should start
-		for jobIndex < len(jobInstances) && (jobInstances[jobIndex].StartTime.Before(currentTime) || jobInstances[jobIndex].StartTime.Equal(currentTime)) {
-			job := jobInstances[jobIndex]
-			jobIndex++
+	for currentTime.Before(r.simulationEnd) || len(state.running) > 0 || len(state.waiting) > 0 {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		if r.sinkErr != nil {
+			return fmt.Errorf("failed to emit event: %w", r.sinkErr)
+		}
 
-			// Try to acquire a lease
-			availableLeases := s.config.MaxActiveLeases - activeLeases
+		if err := r.updateCapacity(state, currentTime); err != nil {
+			return err
+		}
+		if err := r.cleanLeases(state, currentTime); err != nil {
+			return err
+		}
 
-			if availableLeases > 0 {
-				// Lease acquired
-				activeLeases++
-				job.LeaseAcquired = true
-				activeJobs = append(activeJobs, job)
+		// Check for jobs that should finish or exceeded the job timeout. The
+		// job timeout applies to the setup and the tests, after which jobs
+		// with a teardown keep their lease until it is over.
+		stillRunning := []*config.JobInstance{}
+		finished := []*config.JobInstance{}
+		for _, job := range state.running {
+			if job.CurrentState() == config.JobStateTearingDown {
+				if job.Since(currentTime) < job.TeardownDuration {
+					stillRunning = append(stillRunning, job)
+					continue
+				}
+				outcome := config.JobStateCompleted
+				if job.TimedOut {
+					outcome = config.JobStateExecTimeout
+				}
+				if err := job.Transition(outcome, currentTime); err != nil {
+					return err
+				}
+				finished = append(finished, job)
+				continue
+			}
 
-				s.addEvent(Event{
-					Time:         currentTime,
-					Type:         EventTypeLeaseAcquired,
-					JobInstance:  job,
-					ActiveLeases: activeLeases,
-					Message:      fmt.Sprintf("Job '%s' acquired lease", job.Job.Name),
-				})
+			var outcome config.JobState
+			switch {
+			case !currentTime.Before(job.EndTime):
+				outcome = config.JobStateCompleted
+			case job.Since(currentTime) >= r.config.JobTimeoutDuration.Duration:
+				outcome = config.JobStateExecTimeout
+			default:
+				stillRunning = append(stillRunning, job)
+				continue
+			}
 
-				// Check if max exceeded
-				if activeLeases > s.config.MaxActiveLeases {
-					s.addEvent(Event{
-						Time:         currentTime,
-						Type:         EventTypeMaxExceeded,
-						JobInstance:  job,
-						ActiveLeases: activeLeases,
-						Message:      fmt.Sprintf("Max active leases exceeded: %d/%d", activeLeases, s.config.MaxActiveLeases),
-						IsWarning:    true,
-					})
-				}
+			if job.TeardownDuration > 0 {
+				outcome = config.JobStateTearingDown
+			}
+			if err := job.Transition(outcome, currentTime); err != nil {
+				return err
+			}
+			if outcome == config.JobStateTearingDown {
+				stillRunning = append(stillRunning, job)
 			} else {
-				// No lease available, job must wait
-				waitingJobs = append(waitingJobs, job)
-				job.LeaseWaitTime = 0
-
-				s.addEvent(Event{
-					Time:         currentTime,
-					Type:         EventTypeJobWaiting,
-					JobInstance:  job,
-					ActiveLeases: activeLeases,
-					Message:      fmt.Sprintf("Job '%s' waiting for lease", job.Job.Name),
-					IsWarning:    true,
-				})
+				finished = append(finished, job)
 			}
 		}
+		state.running = stillRunning
 
-		// Check for jobs that should finish
-		remainingJobs := []*config.JobInstance{}
-		for _, job := range activeJobs {
-			if currentTime.After(job.EndTime) || currentTime.Equal(job.EndTime) {
-				// Job completed, release lease
-				activeLeases--
+		for _, job := range finished {
+			state.release(job)
 
-				s.addEvent(Event{
+			if job.State == config.JobStateCompleted {
+				r.addEvent(Event{
 					Time:         currentTime,
 					Type:         EventTypeLeaseReleased,
 					JobInstance:  job,
-					ActiveLeases: activeLeases,
+					ActiveLeases: state.activeLeases,
 					Message:      fmt.Sprintf("Job '%s' completed and released lease", job.Job.Name),
 				})
-
-				// Try to assign the released lease to a waiting job
-				if len(waitingJobs) > 0 {
-					waitingJob := waitingJobs[0]
-					waitingJobs = waitingJobs[1:]
-
-					waitingJob.LeaseAcquired = true
-					// waitingJob.StartTime = currentTime
-					waitingJob.EndTime = currentTime.Add(waitingJob.Job.Duration)
-					activeLeases++
-					remainingJobs = append(remainingJobs, waitingJob)
-
-					s.addEvent(Event{
-						Time:         currentTime,
-						Type:         EventTypeLeaseAcquired,
-						JobInstance:  waitingJob,
-						ActiveLeases: activeLeases,
-						Message:      fmt.Sprintf("Job '%s' acquired lease after waiting %s", waitingJob.Job.Name, waitingJob.LeaseWaitTime),
-					})
-				}
 			} else {
-				remainingJobs = append(remainingJobs, job)
-			}
-		}
-		activeJobs = remainingJobs
-
-		// Check for waiting job timeouts
-		remainingWaitingJobs := []*config.JobInstance{}
-		for _, job := range waitingJobs {
-			job.LeaseWaitTime += 5 * time.Minute
-
-			if job.LeaseWaitTime >= s.config.LeaseWaitTimeout {
-				job.TimedOut = true
-
-				s.addEvent(Event{
+				r.addEvent(Event{
 					Time:         currentTime,
 					Type:         EventTypeJobTimeout,
 					JobInstance:  job,
-					ActiveLeases: activeLeases,
-					Message:      fmt.Sprintf("Job '%s' timed out waiting for lease (waited %s) - lease released", job.Job.Name, job.LeaseWaitTime),
+					ActiveLeases: state.activeLeases,
+					Message:      fmt.Sprintf("Job '%s' exceeded execution timeout (%s)", job.Job.Name, r.config.JobTimeoutDuration),
 					IsWarning:    true,
 				})
-			} else {
-				remainingWaitingJobs = append(remainingWaitingJobs, job)
+			}
+
+			if err := r.freeLease(state, job, currentTime); err != nil {
+				return err
 			}
 		}
-		waitingJobs = remainingWaitingJobs
 
-		// Check for job execution timeouts
-		stillRunning := []*config.JobInstance{}
-		for _, job := range activeJobs {
-			if currentTime.Sub(job.StartTime) >= s.config.JobTimeoutDuration && !job.TimedOut {
-				job.TimedOut = true
-				activeLeases--
-				s.addEvent(Event{
-					Time:         currentTime,
-					Type:         EventTypeJobTimeout,
-					JobInstance:  job,
-					ActiveLeases: activeLeases,
-					Message:      fmt.Sprintf("Job '%s' exceeded execution timeout (%s)", job.Job.Name, s.config.JobTimeoutDuration),
-					IsWarning:    true,
-				})
-			} else {
-				stillRunning = append(stillRunning, job)
+		// Check for waiting job timeouts
+		stillWaiting := []*config.JobInstance{}
+		for _, job := range state.waiting {
+			if job.Since(currentTime) < r.config.LeaseWaitTimeout.Duration {
+				stillWaiting = append(stillWaiting, job)
+				continue
 			}
+
+			if err := job.Transition(config.JobStateWaitTimeout, currentTime); err != nil {
+				return err
+			}
+			r.addEvent(Event{
+				Time:         currentTime,
+				Type:         EventTypeJobTimeout,
+				JobInstance:  job,
+				ActiveLeases: state.activeLeases,
+				Message:      fmt.Sprintf("Job '%s' timed out waiting for lease (waited %s)", job.Job.Name, job.LeaseWaitTime),
+				IsWarning:    true,
+			})
 		}
-		activeJobs = stillRunning
+		state.waiting = stillWaiting
 
-		// Move to next time step (5 minute intervals)
-		currentTime = currentTime.Add(5 * time.Minute)
+		// Check for jobs that should start
+		for jobIndex < len(jobInstances) && !jobInstances[jobIndex].ScheduledTime.After(currentTime) {
+			job := jobInstances[jobIndex]
+			jobIndex++
 
-		if jobIndex >= len(jobInstances) && len(activeJobs) == 0 && len(waitingJobs) == 0 {
+			if err := r.enqueue(state, job, currentTime); err != nil {
+				return err
+			}
+		}
+
+		r.stats.record(currentTime, r.simulationEnd, state.activeLeases, len(state.cleanups), len(state.waiting), state.capacity.maxLeases)
+
+		// Move to next time step
+		currentTime = currentTime.Add(r.tick)
+		r.sample(currentTime, state)
+
+		if jobIndex >= len(jobInstances) && len(state.running) == 0 && len(state.waiting) == 0 {
 			break
 		}
 	}
+
+	// Run the rest of the window idle once all jobs are done, following the
+	// capacity schedule and cleaning up the last released leases
+	for ; currentTime.Before(r.simulationEnd); currentTime = currentTime.Add(r.tick) {
+		if err := r.updateCapacity(state, currentTime); err != nil {
+			return err
+		}
+		if err := r.cleanLeases(state, currentTime); err != nil {
+			return err
+		}
+		r.stats.record(currentTime, r.simulationEnd, state.activeLeases, len(state.cleanups), len(state.waiting), state.capacity.maxLeases)
+		r.sample(currentTime.Add(r.tick), state)
+	}
+	r.sample(r.simulationEnd.Add(time.Nanosecond), state)
+
+	return r.sinkErr
 }
 
-// generateTimePoints generates time points for charting
-func (s *Simulator) generateTimePoints() {
-	if len(s.events) == 0 {
-		return
+// enqueue has a triggered job acquire a free lease, or preempt a running job
+// of a lower priority to take its lease, or else wait for a lease
+func (r *run) enqueue(state *leaseState, job *config.JobInstance, currentTime time.Time) error {
+	if r.canAcquire(job, state) {
+		return r.acquire(state, job, currentTime)
 	}
 
-	// Create time points at regular intervals
-	currentTime := s.simulationStart
-	activeLeases := 0
-	waitingJobs := 0
+	var victim *config.JobInstance
+	if r.config.Preemption.Enabled {
+		victim = r.preemptionVictim(state, job)
+	}
+	if victim != nil {
+		reason := fmt.Sprintf("Job '%s' preempted by higher priority job '%s'", victim.Job.Name, job.Job.Name)
+		if err := r.evict(state, victim, job, reason, currentTime); err != nil {
+			return err
+		}
+		// The lease goes straight to the job when it is clean. Otherwise it is
+		// cleaned up like any released lease while the job waits, the job
+		// getting it then before the waiting jobs of a lower priority.
+		if r.config.CleanupDelay(victim.Job.PoolName()) == 0 && r.canAcquire(job, state) {
+			if err := r.acquire(state, job, currentTime); err != nil {
+				return err
+			}
+			return r.afterPreemption(state, victim, currentTime)
+		}
+		if err := r.freeLease(state, victim, currentTime); err != nil {
+			return err
+		}
+	}
 
-	eventIndex := 0
+	// No lease available, job must wait
+	if err := job.Transition(config.JobStateWaiting, currentTime); err != nil {
+		return err
+	}
+	state.waiting = append(state.waiting, job)
 
-	for currentTime.Before(s.simulationEnd) || currentTime.Equal(s.simulationEnd) {
-		// Process all events up to current time
-		for eventIndex < len(s.events) && (s.events[eventIndex].Time.Before(currentTime) || s.events[eventIndex].Time.Equal(currentTime)) {
-			event := s.events[eventIndex]
-			activeLeases = event.ActiveLeases
+	message := fmt.Sprintf("Job '%s' waiting for lease", job.Job.Name)
+	if job.RetryOf != nil {
+		message = fmt.Sprintf("Job '%s' retried after preemption, waiting for lease", job.Job.Name)
+	}
+	r.addEvent(Event{
+		Time:         currentTime,
+		Type:         EventTypeJobWaiting,
+		JobInstance:  job,
+		ActiveLeases: state.activeLeases,
+		Message:      message,
+		IsWarning:    true,
+	})
 
-			if event.Type == EventTypeJobWaiting {
-				waitingJobs++
-			} else if event.Type == EventTypeLeaseAcquired {
-				if waitingJobs > 0 {
-					waitingJobs--
-				}
+	if victim != nil {
+		return r.afterPreemption(state, victim, currentTime)
+	}
+	return nil
+}
+
+// sample records the time points due before the given time, up to the end of
+// the simulation window, with the leases in use and waiting jobs of the last tick
+func (r *run) sample(before time.Time, state *leaseState) {
+	for r.nextSample.Before(before) && !r.nextSample.After(r.simulationEnd) {
+		tp := TimePoint{
+			Time:         r.nextSample,
+			ActiveLeases: state.activeLeases,
+			WaitingJobs:  len(state.waiting),
+			MaxLeases:    state.capacity.maxLeases,
+			Pools:        make(map[string]Usage),
+			Versions:     make(map[string]Usage),
+		}
+		for _, lease := range state.cleanups {
+			if lease.cleaning {
+				tp.CleaningLeases++
+			} else {
+				tp.DirtyLeases++
 			}
+		}
+		for _, job := range state.running {
+			tp.addUsage(job.Job, 1, 0)
+		}
+		for _, job := range state.waiting {
+			tp.addUsage(job.Job, 0, 1)
+		}
+		r.timePoints = append(r.timePoints, tp)
+		r.nextSample = r.nextSample.Add(r.sampleInterval)
+	}
+}
+
+// addUsage adds a job to the usage of its pool and version
+func (tp *TimePoint) addUsage(job *config.Job, activeLeases, waitingJobs int) {
+	pool := tp.Pools[job.PoolName()]
+	pool.ActiveLeases += activeLeases
+	pool.WaitingJobs += waitingJobs
+	tp.Pools[job.PoolName()] = pool
+
+	version := tp.Versions[job.Version]
+	version.ActiveLeases += activeLeases
+	version.WaitingJobs += waitingJobs
+	tp.Versions[job.Version] = version
+}
+
+// acquire gives a lease to a job, which starts running
+func (r *run) acquire(state *leaseState, job *config.JobInstance, currentTime time.Time) error {
+	waited := job.CurrentState() == config.JobStateWaiting
+	if err := job.Transition(config.JobStateRunning, currentTime); err != nil {
+		return err
+	}
 
-			eventIndex++
+	state.activeLeases++
+	if !job.Job.ReleaseController() {
+		state.periodicLeases++
+	}
+	state.running = append(state.running, job)
+
+	message := fmt.Sprintf("Job '%s' acquired lease", job.Job.Name)
+	if waited {
+		message = fmt.Sprintf("Job '%s' acquired lease after waiting %s", job.Job.Name, job.LeaseWaitTime)
+	}
+	r.addEvent(Event{
+		Time:         currentTime,
+		Type:         EventTypeLeaseAcquired,
+		JobInstance:  job,
+		ActiveLeases: state.activeLeases,
+		Message:      message,
+	})
+
+	// Check if max exceeded
+	if state.activeLeases > state.capacity.maxLeases {
+		r.addEvent(Event{
+			Time:         currentTime,
+			Type:         EventTypeMaxExceeded,
+			JobInstance:  job,
+			ActiveLeases: state.activeLeases,
+			Message:      fmt.Sprintf("Max active leases exceeded: %d/%d", state.activeLeases, state.capacity.maxLeases),
+			IsWarning:    true,
+		})
+	}
+
+	return nil
+}
+
+// cleanLeases frees the leases whose cleanup is over, handing them to waiting
+// jobs, and has the janitor pick up the leases that were left dirty
+func (r *run) cleanLeases(state *leaseState, currentTime time.Time) error {
+	pending := []*cleanup{}
+	cleaned := []*cleanup{}
+	for _, lease := range state.cleanups {
+		if currentTime.Before(lease.freeAt) {
+			lease.cleaning = true
+			pending = append(pending, lease)
+		} else {
+			cleaned = append(cleaned, lease)
 		}
+	}
+	state.cleanups = pending
 
-		s.timePoints = append(s.timePoints, TimePoint{
+	for _, lease := range cleaned {
+		r.addEvent(Event{
 			Time:         currentTime,
-			ActiveLeases: activeLeases,
-			WaitingJobs:  waitingJobs,
+			Type:         EventTypeLeaseCleaned,
+			JobInstance:  lease.job,
+			ActiveLeases: state.activeLeases,
+			Message:      fmt.Sprintf("Lease released by job '%s' cleaned up and free", lease.job.Job.Name),
 		})
+		if err := r.handOff(state, currentTime); err != nil {
+			return err
+		}
+	}
 
-		currentTime = currentTime.Add(30 * time.Minute) // Sample every 30 minutes
+	return nil
+}
+
+// freeLease returns the lease released by a job to the free leases, handing
+// it to a waiting job, or leaves it dirty until cleaned up when its pool has
+// a cleanup delay
+func (r *run) freeLease(state *leaseState, job *config.JobInstance, currentTime time.Time) error {
+	if delay := r.config.CleanupDelay(job.Job.PoolName()); delay > 0 {
+		state.cleanups = append(state.cleanups, &cleanup{job: job, freeAt: currentTime.Add(delay)})
+		return nil
 	}
+	return r.handOff(state, currentTime)
 }
 
-// addEvent adds an event to the event list
-func (s *Simulator) addEvent(event Event) {
-	s.events = append(s.events, event)
+// handOff gives a free lease to the first waiting job allowed to use it
+func (r *run) handOff(state *leaseState, currentTime time.Time) error {
+	next := r.nextWaitingJob(state)
+	if next < 0 {
+		return nil
+	}
+
+	waitingJob := state.waiting[next]
+	state.waiting = append(state.waiting[:next:next], state.waiting[next+1:]...)
+	return r.acquire(state, waitingJob, currentTime)
 }
 
-// GetEvents returns all events
-func (s *Simulator) GetEvents() []Event {
-	return s.events
+// release returns the lease held by a job that stopped running
+func (l *leaseState) release(job *config.JobInstance) {
+	l.activeLeases--
+	if !job.Job.ReleaseController() {
+		l.periodicLeases--
+	}
 }
 
-// GetTimePoints returns all time points
-func (s *Simulator) GetTimePoints() []TimePoint {
-	return s.timePoints
+// canAcquire reports whether a job may acquire a lease given the capacity in
+// effect and the leases in use or being cleaned up. Jobs that are not release
+// controller jobs cannot use the leases reserved for release controller jobs.
+func (s *Simulator) canAcquire(job *config.JobInstance, state *leaseState) bool {
+	if state.unavailableLeases() >= state.capacity.maxLeases {
+		return false
+	}
+	return job.Job.ReleaseController() || state.periodicLeases < state.capacity.maxLeases-s.config.ReservedLeases
 }
 
-// GetWarnings returns all warning events
-func (s *Simulator) GetWarnings() []Event {
-	warnings := []Event{}
-	for _, event := range s.events {
-		if event.IsWarning {
-			warnings = append(warnings, event)
+// nextWaitingJob returns the index of the first waiting job that may acquire
+// a lease, or -1 if none can. With preemption enabled, it is the one of the
+// highest priority, the first one that started waiting among equals.
+func (s *Simulator) nextWaitingJob(state *leaseState) int {
+	next := -1
+	for i, job := range state.waiting {
+		if !s.canAcquire(job, state) {
+			continue
+		}
+		if !s.config.Preemption.Enabled {
+			return i
+		}
+		if next < 0 || job.Job.Priority > state.waiting[next].Job.Priority {
+			next = i
 		}
 	}
-	return warnings
+	return next
+}
+
+// addEvent sends an event to the sink, counting the warnings and keeping
+// the statistics that do not depend on retaining events
+func (r *run) addEvent(event Event) {
+	if event.IsWarning {
+		r.warningCount++
+	}
+	if event.Type == EventTypeMaxExceeded {
+		r.stats.maxExceeded++
+	}
+	if r.sinkErr == nil {
+		r.sinkErr = r.sink.Emit(event)
+	}
 }
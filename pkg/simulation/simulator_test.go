@@ -0,0 +1,1019 @@
+package simulation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+)
+
+// testStart is a Monday, so that tests do not depend on the current date
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// at returns the time of the given "15:04" clock time on the test start day
+func at(clock string) time.Time {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		panic(err)
+	}
+	return testStart.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+}
+
+// cronJob returns a daily cron job starting at 00:30
+func cronJob(name string, duration time.Duration) config.Job {
+	return config.Job{
+		Name:         name,
+		Version:      "4.19",
+		Duration:     config.Duration{Duration: duration},
+		TriggerType:  config.TriggerTypeCron,
+		CronSchedule: "30 0 * * *",
+	}
+}
+
+// runSimulation runs a one day simulation of the jobs starting on testStart
+func runSimulation(t *testing.T, maxLeases int, jobTimeout, waitTimeout time.Duration, jobs ...config.Job) []*config.JobInstance {
+	t.Helper()
+
+	cfg := &config.Config{
+		MaxActiveLeases:    maxLeases,
+		JobTimeoutDuration: config.Duration{Duration: jobTimeout},
+		LeaseWaitTimeout:   config.Duration{Duration: waitTimeout},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		Jobs:               jobs,
+	}
+	return runConfig(t, cfg, 1).Instances
+}
+
+// runConfig runs a simulation of the configuration starting on testStart
+func runConfig(t *testing.T, cfg *config.Config, seed int64) *Result {
+	t.Helper()
+
+	result, err := NewSimulator(cfg, WithStartTime(testStart), WithSeed(seed)).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	return result
+}
+
+// scheduledTimes returns the scheduled times of the instances of a job
+func scheduledTimes(instances []*config.JobInstance, name string) []time.Time {
+	times := []time.Time{}
+	for _, instance := range instances {
+		if instance.Job.Name == name {
+			times = append(times, instance.ScheduledTime)
+		}
+	}
+	return times
+}
+
+func TestJobLifecycle(t *testing.T) {
+	type expectation struct {
+		state     config.JobState
+		times     map[config.JobState]string
+		leaseWait time.Duration
+	}
+
+	tests := []struct {
+		name        string
+		maxLeases   int
+		jobTimeout  time.Duration
+		waitTimeout time.Duration
+		jobs        []config.Job
+		want        map[string]expectation
+	}{
+		{
+			name:        "pending to running to completed",
+			maxLeases:   1,
+			jobTimeout:  4 * time.Hour,
+			waitTimeout: 2 * time.Hour,
+			jobs:        []config.Job{cronJob("a", time.Hour)},
+			want: map[string]expectation{
+				"a": {
+					state: config.JobStateCompleted,
+					times: map[config.JobState]string{config.JobStateRunning: "00:30", config.JobStateCompleted: "01:30"},
+				},
+			},
+		},
+		{
+			name:        "pending to waiting to running",
+			maxLeases:   1,
+			jobTimeout:  4 * time.Hour,
+			waitTimeout: 2 * time.Hour,
+			jobs:        []config.Job{cronJob("a", time.Hour), cronJob("b", time.Hour)},
+			want: map[string]expectation{
+				"a": {
+					state: config.JobStateCompleted,
+					times: map[config.JobState]string{config.JobStateRunning: "00:30", config.JobStateCompleted: "01:30"},
+				},
+				"b": {
+					state:     config.JobStateCompleted,
+					times:     map[config.JobState]string{config.JobStateWaiting: "00:30", config.JobStateRunning: "01:30", config.JobStateCompleted: "02:30"},
+					leaseWait: time.Hour,
+				},
+			},
+		},
+		{
+			name:        "waiting to wait-timeout after exactly the lease wait timeout",
+			maxLeases:   1,
+			jobTimeout:  4 * time.Hour,
+			waitTimeout: time.Hour,
+			jobs:        []config.Job{cronJob("a", 3*time.Hour), cronJob("b", time.Hour)},
+			want: map[string]expectation{
+				"a": {
+					state: config.JobStateCompleted,
+					times: map[config.JobState]string{config.JobStateRunning: "00:30", config.JobStateCompleted: "03:30"},
+				},
+				"b": {
+					state:     config.JobStateWaitTimeout,
+					times:     map[config.JobState]string{config.JobStateWaiting: "00:30", config.JobStateWaitTimeout: "01:30"},
+					leaseWait: time.Hour,
+				},
+			},
+		},
+		{
+			name:        "running to exec-timeout",
+			maxLeases:   1,
+			jobTimeout:  2 * time.Hour,
+			waitTimeout: 2 * time.Hour,
+			jobs:        []config.Job{cronJob("a", 3*time.Hour)},
+			want: map[string]expectation{
+				"a": {
+					state: config.JobStateExecTimeout,
+					times: map[config.JobState]string{config.JobStateRunning: "00:30", config.JobStateExecTimeout: "02:30"},
+				},
+			},
+		},
+		{
+			name:        "exec-timeout hands the lease to a waiting job",
+			maxLeases:   1,
+			jobTimeout:  2 * time.Hour,
+			waitTimeout: 3 * time.Hour,
+			jobs:        []config.Job{cronJob("a", 3*time.Hour), cronJob("b", time.Hour)},
+			want: map[string]expectation{
+				"a": {
+					state: config.JobStateExecTimeout,
+					times: map[config.JobState]string{config.JobStateRunning: "00:30", config.JobStateExecTimeout: "02:30"},
+				},
+				"b": {
+					state:     config.JobStateCompleted,
+					times:     map[config.JobState]string{config.JobStateWaiting: "00:30", config.JobStateRunning: "02:30", config.JobStateCompleted: "03:30"},
+					leaseWait: 2 * time.Hour,
+				},
+			},
+		},
+		{
+			name:        "exec-timeout is measured from the lease acquisition",
+			maxLeases:   1,
+			jobTimeout:  2 * time.Hour,
+			waitTimeout: 2 * time.Hour,
+			jobs:        []config.Job{cronJob("a", time.Hour), cronJob("b", 90*time.Minute)},
+			want: map[string]expectation{
+				"a": {
+					state: config.JobStateCompleted,
+					times: map[config.JobState]string{config.JobStateRunning: "00:30", config.JobStateCompleted: "01:30"},
+				},
+				"b": {
+					state:     config.JobStateCompleted,
+					times:     map[config.JobState]string{config.JobStateWaiting: "00:30", config.JobStateRunning: "01:30", config.JobStateCompleted: "03:00"},
+					leaseWait: time.Hour,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instances := runSimulation(t, tt.maxLeases, tt.jobTimeout, tt.waitTimeout, tt.jobs...)
+			if len(instances) != len(tt.want) {
+				t.Fatalf("got %d instances, want %d", len(instances), len(tt.want))
+			}
+
+			for _, instance := range instances {
+				want, ok := tt.want[instance.Job.Name]
+				if !ok {
+					t.Fatalf("unexpected instance of job %s", instance.Job.Name)
+				}
+				if instance.State != want.state {
+					t.Errorf("job %s: state = %s, want %s", instance.Job.Name, instance.State, want.state)
+				}
+				if instance.LeaseWaitTime != want.leaseWait {
+					t.Errorf("job %s: LeaseWaitTime = %s, want %s", instance.Job.Name, instance.LeaseWaitTime, want.leaseWait)
+				}
+				if len(instance.StateTimes) != len(want.times) {
+					t.Errorf("job %s: StateTimes = %v, want %v", instance.Job.Name, instance.StateTimes, want.times)
+				}
+				if !instance.ScheduledTime.Equal(at("00:30")) {
+					t.Errorf("job %s: ScheduledTime = %s, want 00:30", instance.Job.Name, instance.ScheduledTime.Format("15:04"))
+				}
+				if instance.Outcome != want.state || !instance.FinishedTime.Equal(at(want.times[want.state])) {
+					t.Errorf("job %s: finished %s at %s, want %s at %s", instance.Job.Name,
+						instance.Outcome, instance.FinishedTime.Format("15:04"), want.state, want.times[want.state])
+				}
+				if running, ok := want.times[config.JobStateRunning]; ok {
+					if !instance.LeaseAcquiredTime.Equal(at(running)) || !instance.StartTime.Equal(at(running)) {
+						t.Errorf("job %s: acquired lease at %s and started at %s, want %s", instance.Job.Name,
+							instance.LeaseAcquiredTime.Format("15:04"), instance.StartTime.Format("15:04"), running)
+					}
+				} else if !instance.LeaseAcquiredTime.IsZero() {
+					t.Errorf("job %s: LeaseAcquiredTime = %s, want zero", instance.Job.Name, instance.LeaseAcquiredTime)
+				}
+				for state, clock := range want.times {
+					if got := instance.StateTimes[state]; !got.Equal(at(clock)) {
+						t.Errorf("job %s: entered %s at %s, want %s", instance.Job.Name, state, got.Format("15:04"), clock)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCronExpansion(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule string
+		duration time.Duration
+		want     []string
+	}{
+		{
+			name:     "every six hours, including the end of the window",
+			schedule: "0 */6 * * *",
+			duration: 24 * time.Hour,
+			want:     []string{"Mon 06:00", "Mon 12:00", "Mon 18:00", "Tue 00:00"},
+		},
+		{
+			name:     "weekdays",
+			schedule: "0 8 * * 1-5",
+			duration: 7 * 24 * time.Hour,
+			want:     []string{"Mon 08:00", "Tue 08:00", "Wed 08:00", "Thu 08:00", "Fri 08:00"},
+		},
+		{
+			name:     "weekend with Sunday written as 7",
+			schedule: "15 2 * * 6-7",
+			duration: 7 * 24 * time.Hour,
+			want:     []string{"Sat 02:15", "Sun 02:15"},
+		},
+		{
+			name:     "nothing within the window",
+			schedule: "0 8 * * 5",
+			duration: 24 * time.Hour,
+			want:     []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := cronJob("job", time.Hour)
+			job.CronSchedule = tt.schedule
+			cfg := &config.Config{
+				MaxActiveLeases:    10,
+				JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+				LeaseWaitTimeout:   config.Duration{Duration: 2 * time.Hour},
+				SimulationDuration: config.Duration{Duration: tt.duration},
+				Jobs:               []config.Job{job},
+			}
+
+			got := []string{}
+			for _, scheduled := range scheduledTimes(runConfig(t, cfg, 1).Instances, "job") {
+				got = append(got, scheduled.Format("Mon 15:04"))
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("scheduled times = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseControllerGrouping(t *testing.T) {
+	releaseJob := func(name, version string) config.Job {
+		return config.Job{
+			Name:                name,
+			Version:             version,
+			Duration:            config.Duration{Duration: time.Hour},
+			TriggerType:         config.TriggerTypeReleaseController,
+			IsReleaseController: true,
+		}
+	}
+	cfg := &config.Config{
+		MaxActiveLeases:    10,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: 2 * time.Hour},
+		SimulationDuration: config.Duration{Duration: 3 * 24 * time.Hour},
+		Jobs: []config.Job{
+			releaseJob("a-4.19", "4.19"),
+			releaseJob("b-4.19", "4.19"),
+			releaseJob("a-4.20", "4.20"),
+			releaseJob("b-4.20", "4.20"),
+		},
+	}
+
+	instances := runConfig(t, cfg, 42).Instances
+
+	for _, version := range []string{"4.19", "4.20"} {
+		a, b := scheduledTimes(instances, "a-"+version), scheduledTimes(instances, "b-"+version)
+		if len(a) == 0 || len(a) != len(b) {
+			t.Fatalf("version %s: %d and %d releases, want the same non-zero count", version, len(a), len(b))
+		}
+		if !a[0].Equal(testStart) {
+			t.Errorf("version %s: first release at %s, want the simulation start", version, a[0])
+		}
+		for i := range a {
+			if !a[i].Equal(b[i]) {
+				t.Errorf("version %s: release %d triggered jobs at %s and %s, want the same time", version, i, a[i], b[i])
+			}
+			if i > 0 {
+				if interval := a[i].Sub(a[i-1]); interval < 4*time.Hour || interval > 8*time.Hour {
+					t.Errorf("version %s: %s between releases, want 4h to 8h", version, interval)
+				}
+			}
+		}
+	}
+
+	// Versions get independent release events
+	if first, second := scheduledTimes(instances, "a-4.19"), scheduledTimes(instances, "a-4.20"); fmt.Sprint(first) == fmt.Sprint(second) {
+		t.Errorf("versions 4.19 and 4.20 have the same release times %v", first)
+	}
+}
+
+func TestReleaseControllerWithoutFlag(t *testing.T) {
+	// Configurations built without Parse may not set IsReleaseController on
+	// release controller triggered jobs, which still use the reserved lease
+	release := cronJob("rc", 3*time.Hour)
+	release.TriggerType = config.TriggerTypeReleaseController
+	release.CronSchedule = ""
+	first := cronJob("first", 3*time.Hour)
+	first.CronSchedule = "0 1 * * *"
+	second := cronJob("second", time.Hour)
+	second.CronSchedule = "0 1 * * *"
+
+	cfg := &config.Config{
+		MaxActiveLeases:    2,
+		ReservedLeases:     1,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: 4 * time.Hour},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		// A single release at the start of the day
+		ReleaseInterval: config.ReleaseInterval{Min: config.Duration{Duration: 24 * time.Hour}, Max: config.Duration{Duration: 24 * time.Hour}},
+		Jobs:            []config.Job{release, first, second},
+	}
+	result := runConfig(t, cfg, 1)
+
+	want := map[string]string{"rc": "00:00", "first": "01:00", "second": "04:00"}
+	for _, instance := range result.Instances {
+		if got := instance.LeaseAcquiredTime.Format("15:04"); got != want[instance.Job.Name] {
+			t.Errorf("%s acquired its lease at %s, want %s", instance.Job.Name, got, want[instance.Job.Name])
+		}
+	}
+	if result.Metrics.ReleaseControllerWaitedJobs != 0 || result.Metrics.WaitedJobs != 1 {
+		t.Errorf("WaitedJobs = %d, ReleaseControllerWaitedJobs = %d, want 1, 0", result.Metrics.WaitedJobs, result.Metrics.ReleaseControllerWaitedJobs)
+	}
+}
+
+func TestSeedReproducibility(t *testing.T) {
+	cfg := func() *config.Config {
+		return &config.Config{
+			MaxActiveLeases:    2,
+			JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+			LeaseWaitTimeout:   config.Duration{Duration: 2 * time.Hour},
+			SimulationDuration: config.Duration{Duration: 2 * 24 * time.Hour},
+			Jobs: []config.Job{
+				{Name: "rc", Version: "4.19", Duration: config.Duration{Duration: 3 * time.Hour}, TriggerType: config.TriggerTypeReleaseController, IsReleaseController: true},
+				cronJob("cron", 2*time.Hour),
+			},
+		}
+	}
+
+	messages := func(result *Result) string {
+		var sb strings.Builder
+		for _, event := range result.Events {
+			sb.WriteString(event.Time.Format(time.RFC3339) + " " + event.Message + "\n")
+		}
+		return sb.String()
+	}
+
+	first, second := messages(runConfig(t, cfg(), 7)), messages(runConfig(t, cfg(), 7))
+	if first != second {
+		t.Errorf("runs with the same seed differ:\n%s\nand\n%s", first, second)
+	}
+
+	// Options are reused across simulators without sharing the random source
+	opts := []Option{WithStartTime(testStart), WithSeed(7)}
+	for i := 0; i < 3; i++ {
+		result, err := NewSimulator(cfg(), opts...).Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if got := messages(result); got != first {
+			t.Errorf("run %d with reused options differs:\n%s\nand\n%s", i, got, first)
+		}
+	}
+}
+
+func TestWaitingHandOff(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxLeases      int
+		reservedLeases int
+		jobs           []config.Job
+		// want is the order in which the jobs acquire a lease
+		want []string
+	}{
+		{
+			name:      "waiting jobs acquire released leases in order",
+			maxLeases: 1,
+			jobs:      []config.Job{cronJob("a", time.Hour), cronJob("b", time.Hour), cronJob("c", time.Hour), cronJob("d", time.Hour)},
+			want:      []string{"a", "b", "c", "d"},
+		},
+		{
+			name:           "periodic jobs cannot use reserved leases",
+			maxLeases:      2,
+			reservedLeases: 1,
+			jobs: []config.Job{
+				cronJob("periodic-1", time.Hour),
+				cronJob("periodic-2", time.Hour),
+				func() config.Job {
+					job := cronJob("fallback", time.Hour)
+					job.IsReleaseController = true
+					return job
+				}(),
+			},
+			want: []string{"periodic-1", "fallback", "periodic-2"},
+		},
+		{
+			name:           "a release controller job skips periodic jobs waiting for a periodic lease",
+			maxLeases:      2,
+			reservedLeases: 1,
+			jobs: []config.Job{
+				cronJob("periodic-1", 2*time.Hour),
+				cronJob("periodic-2", time.Hour),
+				func() config.Job {
+					job := cronJob("fallback", time.Hour)
+					job.CronSchedule = "45 0 * * *"
+					job.IsReleaseController = true
+					return job
+				}(),
+			},
+			want: []string{"periodic-1", "fallback", "periodic-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				MaxActiveLeases:    tt.maxLeases,
+				ReservedLeases:     tt.reservedLeases,
+				JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+				LeaseWaitTimeout:   config.Duration{Duration: 6 * time.Hour},
+				SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+				Jobs:               tt.jobs,
+			}
+			result := runConfig(t, cfg, 1)
+
+			got := []string{}
+			for _, event := range result.Events {
+				if event.Type == EventTypeLeaseAcquired {
+					got = append(got, event.JobInstance.Job.Name)
+				}
+				if event.ActiveLeases > tt.maxLeases {
+					t.Errorf("%d active leases at %s, above the maximum of %d", event.ActiveLeases, event.Time, tt.maxLeases)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("lease acquisition order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	// a holds the lease past the job timeout, b times out waiting and c gets the lease after a
+	jobs := []config.Job{cronJob("a", 3*time.Hour), cronJob("b", time.Hour), cronJob("c", time.Hour)}
+	jobs[2].CronSchedule = "30 2 * * *"
+	cfg := &config.Config{
+		MaxActiveLeases:    1,
+		JobTimeoutDuration: config.Duration{Duration: 2 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: time.Hour},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		Jobs:               jobs,
+	}
+
+	metrics := runConfig(t, cfg, 1).Metrics
+
+	want := Metrics{
+		JobInstances:     3,
+		WaitedJobs:       1,
+		WaitTimeouts:     1,
+		ExecTimeouts:     1,
+		PeakActiveLeases: 1,
+		PeakDemand:       2,
+		TotalWait:        time.Hour,
+		P50Wait:          0,
+		P95Wait:          time.Hour,
+		MaxWait:          time.Hour,
+		LeaseHours:       3,
+		Utilization:      3.0 / 24,
+		TimeAtCapacity:   3 * time.Hour,
+	}
+	if metrics != want {
+		t.Errorf("GetMetrics() = %+v, want %+v", metrics, want)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	cfg := &config.Config{
+		MaxActiveLeases:    1,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: 2 * time.Hour},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		Jobs:               []config.Job{cronJob("a", time.Hour)},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewSimulator(cfg, WithStartTime(testStart)).Run(ctx); err != context.Canceled {
+		t.Errorf("Run() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestMaxInstances(t *testing.T) {
+	release := cronJob("rc", time.Hour)
+	release.TriggerType = config.TriggerTypeReleaseController
+	release.IsReleaseController = true
+	cfg := &config.Config{
+		MaxActiveLeases:    1,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: 2 * time.Hour},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		// A release every millisecond must not be generated before failing
+		ReleaseInterval: config.ReleaseInterval{Min: config.Duration{Duration: time.Millisecond}, Max: config.Duration{Duration: time.Millisecond}},
+		Jobs:            []config.Job{cronJob("a", time.Hour), release},
+	}
+
+	_, err := NewSimulator(cfg, WithStartTime(testStart), WithSeed(1), WithMaxInstances(1000)).Run(context.Background())
+	if !errors.Is(err, ErrTooManyInstances) {
+		t.Errorf("Run() error = %v, want %v", err, ErrTooManyInstances)
+	}
+
+	cfg.ReleaseInterval = config.ReleaseInterval{}
+	if _, err := NewSimulator(cfg, WithStartTime(testStart), WithSeed(1), WithMaxInstances(1000)).Run(context.Background()); err != nil {
+		t.Errorf("Run() error = %v, want none below the limit", err)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	cfg := &config.Config{
+		MaxActiveLeases:    1,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: 2 * time.Hour},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		Jobs:               []config.Job{cronJob("a", time.Hour)},
+	}
+
+	// A Wednesday: the simulation starts on the Monday before
+	clock := func() time.Time { return time.Date(2024, 1, 3, 15, 4, 5, 0, time.UTC) }
+	result, err := NewSimulator(cfg, WithClock(clock), WithTick(time.Minute)).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !result.Start.Equal(testStart) {
+		t.Errorf("Start = %s, want %s", result.Start, testStart)
+	}
+	if result.Metrics.LeaseHours != 1 {
+		t.Errorf("LeaseHours = %v, want 1", result.Metrics.LeaseHours)
+	}
+
+	if _, err := NewSimulator(cfg, WithTick(0)).Run(context.Background()); err == nil {
+		t.Error("Run() with a zero tick succeeded, want an error")
+	}
+}
+
+func TestTimePointBreakdown(t *testing.T) {
+	aws := cronJob("aws", 2*time.Hour)
+	aws.Pool = "aws-quota"
+	gcp := cronJob("gcp", 2*time.Hour)
+	gcp.Version = "4.20"
+	upgrade := cronJob("upgrade", time.Hour)
+	upgrade.Pool = "aws-quota"
+
+	cfg := &config.Config{
+		MaxActiveLeases:    2,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: 4 * time.Hour},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		Jobs:               []config.Job{aws, gcp, upgrade},
+	}
+	result, err := NewSimulator(cfg, WithStartTime(testStart), WithSeed(1), WithSampleInterval(15*time.Minute)).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got, want := len(result.TimePoints), 24*4+1; got != want {
+		t.Errorf("got %d time points, want %d", got, want)
+	}
+
+	// At 01:00, aws and gcp run and upgrade waits
+	tp := result.TimePoints[4]
+	if !tp.Time.Equal(at("01:00")) {
+		t.Fatalf("time point 4 at %s, want 01:00", tp.Time)
+	}
+	wantPools := map[string]Usage{"aws-quota": {ActiveLeases: 1, WaitingJobs: 1}, config.DefaultPool: {ActiveLeases: 1}}
+	wantVersions := map[string]Usage{"4.19": {ActiveLeases: 1, WaitingJobs: 1}, "4.20": {ActiveLeases: 1}}
+	if !reflect.DeepEqual(tp.Pools, wantPools) {
+		t.Errorf("Pools = %v, want %v", tp.Pools, wantPools)
+	}
+	if !reflect.DeepEqual(tp.Versions, wantVersions) {
+		t.Errorf("Versions = %v, want %v", tp.Versions, wantVersions)
+	}
+
+	if _, err := NewSimulator(cfg, WithSampleInterval(0)).Run(context.Background()); err == nil {
+		t.Error("Run() with a zero sample interval succeeded, want an error")
+	}
+}
+
+func TestLeaseCleanup(t *testing.T) {
+	first := cronJob("first", time.Hour)
+	first.Pool = "aws-quota"
+	second := cronJob("second", time.Hour)
+	second.Pool = "aws-quota"
+	gcp := cronJob("gcp", time.Hour)
+	gcp.CronSchedule = "0 3 * * *"
+
+	cfg := &config.Config{
+		MaxActiveLeases:    1,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: 4 * time.Hour},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		Pools:              []config.Pool{{Name: "aws-quota", CleanupDelay: config.Duration{Duration: 30 * time.Minute}}},
+		Jobs:               []config.Job{first, second, gcp},
+	}
+	result, err := NewSimulator(cfg, WithStartTime(testStart), WithSeed(1), WithSampleInterval(5*time.Minute)).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// The lease released at 01:30 is only free again at 02:00
+	instances := map[string]*config.JobInstance{}
+	for _, instance := range result.Instances {
+		instances[instance.Job.Name] = instance
+	}
+	if got := instances["second"].LeaseAcquiredTime; !got.Equal(at("02:00")) {
+		t.Errorf("second acquired its lease at %s, want 02:00", got.Format("15:04"))
+	}
+	if got := instances["second"].LeaseWaitTime; got != 90*time.Minute {
+		t.Errorf("second waited %s, want 1h30m", got)
+	}
+
+	// Leases of pools without a cleanup delay are free right away
+	if got := instances["gcp"].LeaseAcquiredTime; !got.Equal(at("03:30")) {
+		t.Errorf("gcp acquired its lease at %s, want 03:30", got.Format("15:04"))
+	}
+
+	tests := []struct {
+		clock                                            string
+		wantActive, wantDirty, wantCleaning, wantWaiting int
+	}{
+		{clock: "01:30", wantDirty: 1, wantWaiting: 1},
+		{clock: "01:35", wantCleaning: 1, wantWaiting: 1},
+		{clock: "01:55", wantCleaning: 1, wantWaiting: 1},
+		{clock: "02:00", wantActive: 1},
+		{clock: "03:00", wantDirty: 1, wantWaiting: 1},
+		{clock: "03:30", wantActive: 1},
+	}
+	for _, tt := range tests {
+		index := int(at(tt.clock).Sub(testStart) / (5 * time.Minute))
+		tp := result.TimePoints[index]
+		if tp.ActiveLeases != tt.wantActive || tp.DirtyLeases != tt.wantDirty || tp.CleaningLeases != tt.wantCleaning || tp.WaitingJobs != tt.wantWaiting {
+			t.Errorf("at %s: %d active, %d dirty, %d cleaning, %d waiting, want %d, %d, %d, %d", tt.clock,
+				tp.ActiveLeases, tp.DirtyLeases, tp.CleaningLeases, tp.WaitingJobs,
+				tt.wantActive, tt.wantDirty, tt.wantCleaning, tt.wantWaiting)
+		}
+	}
+
+	cleaned := 0
+	for _, event := range result.Events {
+		if event.Type == EventTypeLeaseCleaned {
+			cleaned++
+		}
+	}
+	if cleaned != 2 {
+		t.Errorf("got %d lease-cleaned events, want 2", cleaned)
+	}
+	if result.Metrics.TimeAtCapacity != 4*time.Hour {
+		t.Errorf("TimeAtCapacity = %s, want 4h including the cleanups", result.Metrics.TimeAtCapacity)
+	}
+}
+
+func TestLeaseCleanupAfterLastJob(t *testing.T) {
+	// The lease of the last job is still cleaned up once no job is left
+	probe := cronJob("probe", time.Hour)
+	probe.CronSchedule = "0 1 * * *"
+	probe.Pool = "aws-quota"
+
+	cfg := &config.Config{
+		MaxActiveLeases:    1,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: 4 * time.Hour},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		Pools:              []config.Pool{{Name: "aws-quota", CleanupDelay: config.Duration{Duration: 30 * time.Minute}}},
+		Jobs:               []config.Job{probe},
+	}
+	result, err := NewSimulator(cfg, WithStartTime(testStart), WithSeed(1), WithSampleInterval(5*time.Minute)).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for clock, want := range map[string][2]int{"02:00": {1, 0}, "02:05": {0, 1}, "02:30": {0, 0}, "23:55": {0, 0}} {
+		tp := result.TimePoints[int(at(clock).Sub(testStart)/(5*time.Minute))]
+		if tp.DirtyLeases != want[0] || tp.CleaningLeases != want[1] {
+			t.Errorf("at %s: %d dirty, %d cleaning, want %d, %d", clock, tp.DirtyLeases, tp.CleaningLeases, want[0], want[1])
+		}
+	}
+
+	cleaned := 0
+	for _, event := range result.Events {
+		if event.Type == EventTypeLeaseCleaned {
+			cleaned++
+		}
+	}
+	if cleaned != 1 {
+		t.Errorf("got %d lease-cleaned events, want 1", cleaned)
+	}
+	if result.Metrics.TimeAtCapacity != 90*time.Minute {
+		t.Errorf("TimeAtCapacity = %s, want 1h30m including the cleanup", result.Metrics.TimeAtCapacity)
+	}
+}
+
+func TestSetupAndTeardown(t *testing.T) {
+	// Setup extends the run and the teardown holds the lease after it
+	slow := cronJob("slow", time.Hour)
+	slow.SetupDuration = config.Duration{Duration: 30 * time.Minute}
+	slow.TeardownDuration = config.Duration{Duration: 15 * time.Minute}
+	// The timeout covers setup and tests, the teardown still runs after it
+	stuck := cronJob("stuck", 3*time.Hour)
+	stuck.CronSchedule = "0 6 * * *"
+	stuck.Pool = "aws-quota"
+	waiter := cronJob("waiter", time.Hour)
+	waiter.CronSchedule = "30 8 * * *"
+
+	cfg := &config.Config{
+		MaxActiveLeases:    1,
+		JobTimeoutDuration: config.Duration{Duration: 2 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: 2 * time.Hour},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		Pools: []config.Pool{{
+			Name:             "aws-quota",
+			SetupDuration:    config.Duration{Duration: 45 * time.Minute},
+			TeardownDuration: config.Duration{Duration: time.Hour},
+		}},
+		Jobs: []config.Job{slow, stuck, waiter},
+	}
+	result := runConfig(t, cfg, 1)
+
+	instances := map[string]*config.JobInstance{}
+	for _, instance := range result.Instances {
+		instances[instance.Job.Name] = instance
+	}
+
+	tests := []struct {
+		name                              string
+		wantState                         config.JobState
+		wantAcquired, wantEnd, wantFinish string
+	}{
+		{name: "slow", wantState: config.JobStateCompleted, wantAcquired: "00:30", wantEnd: "02:00", wantFinish: "02:15"},
+		{name: "stuck", wantState: config.JobStateExecTimeout, wantAcquired: "06:00", wantEnd: "08:00", wantFinish: "09:00"},
+		{name: "waiter", wantState: config.JobStateCompleted, wantAcquired: "09:00", wantEnd: "10:00", wantFinish: "10:00"},
+	}
+	for _, tt := range tests {
+		instance := instances[tt.name]
+		if instance.State != tt.wantState {
+			t.Errorf("%s: state = %s, want %s", tt.name, instance.State, tt.wantState)
+		}
+		for _, check := range []struct {
+			field string
+			got   time.Time
+			want  string
+		}{
+			{"LeaseAcquiredTime", instance.LeaseAcquiredTime, tt.wantAcquired},
+			{"EndTime", instance.EndTime, tt.wantEnd},
+			{"FinishedTime", instance.FinishedTime, tt.wantFinish},
+		} {
+			if !check.got.Equal(at(check.want)) {
+				t.Errorf("%s: %s = %s, want %s", tt.name, check.field, check.got.Format("15:04"), check.want)
+			}
+		}
+	}
+
+	// slow: 30m setup, 15m teardown; stuck: 45m setup, 1h teardown
+	if got, want := result.Metrics.SetupLeaseHours, 1.25; got != want {
+		t.Errorf("SetupLeaseHours = %g, want %g", got, want)
+	}
+	if got, want := result.Metrics.TeardownLeaseHours, 1.25; got != want {
+		t.Errorf("TeardownLeaseHours = %g, want %g", got, want)
+	}
+	if got, want := result.Metrics.LeaseHours, 5.75; got != want {
+		t.Errorf("LeaseHours = %g, want %g", got, want)
+	}
+}
+
+func TestCapacitySchedule(t *testing.T) {
+	tests := []struct {
+		policy          config.CapacityPolicy
+		wantOutcomes    map[string]config.JobState
+		wantCAcquired   string
+		wantPreemptions int
+	}{
+		{
+			// Running jobs finish, the waiting job only starts once both did
+			policy:        config.CapacityPolicyDrain,
+			wantOutcomes:  map[string]config.JobState{"periodic": config.JobStateCompleted, "rc-fallback": config.JobStateCompleted, "late": config.JobStateCompleted},
+			wantCAcquired: "03:30",
+		},
+		{
+			// The periodic job is stopped although it started first
+			policy:          config.CapacityPolicyPreempt,
+			wantOutcomes:    map[string]config.JobState{"periodic": config.JobStatePreempted, "rc-fallback": config.JobStateCompleted, "late": config.JobStateCompleted},
+			wantCAcquired:   "03:30",
+			wantPreemptions: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			periodic := cronJob("periodic", 2*time.Hour)
+			periodic.CronSchedule = "0 1 * * *"
+			rcFallback := cronJob("rc-fallback", 2*time.Hour)
+			rcFallback.CronSchedule = "30 1 * * *"
+			rcFallback.IsReleaseController = true
+			late := cronJob("late", time.Hour)
+			late.CronSchedule = "30 2 * * *"
+
+			cfg := &config.Config{
+				MaxActiveLeases:    2,
+				JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+				LeaseWaitTimeout:   config.Duration{Duration: 2 * time.Hour},
+				SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+				CapacitySchedule: []config.CapacityChange{
+					{Name: "maintenance", MaxActiveLeases: 1, CronSchedule: "0 2 * * *", Duration: config.Duration{Duration: 2 * time.Hour}, Policy: tt.policy},
+					{Name: "growth", MaxActiveLeases: 3, At: at("12:00")},
+				},
+				Jobs: []config.Job{periodic, rcFallback, late},
+			}
+			result := runConfig(t, cfg, 1)
+
+			for _, instance := range result.Instances {
+				if want := tt.wantOutcomes[instance.Job.Name]; instance.State != want {
+					t.Errorf("%s: state = %s, want %s", instance.Job.Name, instance.State, want)
+				}
+				if instance.Job.Name == "late" && !instance.LeaseAcquiredTime.Equal(at(tt.wantCAcquired)) {
+					t.Errorf("late acquired its lease at %s, want %s", instance.LeaseAcquiredTime.Format("15:04"), tt.wantCAcquired)
+				}
+			}
+
+			for clock, want := range map[string]int{"01:30": 2, "02:00": 1, "03:30": 1, "04:00": 2, "12:00": 3, "23:30": 3} {
+				index := int(at(clock).Sub(testStart) / (30 * time.Minute))
+				if got := result.TimePoints[index].MaxLeases; got != want {
+					t.Errorf("capacity at %s = %d, want %d", clock, got, want)
+				}
+			}
+
+			if result.Metrics.Preemptions != tt.wantPreemptions {
+				t.Errorf("Preemptions = %d, want %d", result.Metrics.Preemptions, tt.wantPreemptions)
+			}
+
+			changes := []int{}
+			for _, event := range result.Events {
+				if event.Type == EventTypeCapacityChanged {
+					changes = append(changes, event.MaxLeases)
+				}
+			}
+			if want := []int{1, 2, 3}; !reflect.DeepEqual(changes, want) {
+				t.Errorf("capacity-changed events to %v leases, want %v", changes, want)
+			}
+
+			// 2 leases for 10h, 1 for 2h and 3 for 12h
+			if capacityHours := result.Metrics.LeaseHours / result.Metrics.Utilization; capacityHours < 57.99 || capacityHours > 58.01 {
+				t.Errorf("capacity = %g lease-hours, want 58", capacityHours)
+			}
+		})
+	}
+}
+
+func TestPreemption(t *testing.T) {
+	tests := []struct {
+		name       string
+		preemption config.Preemption
+		pools      []config.Pool
+		// wantAcquired holds when each run acquired its lease, in run order
+		wantAcquired map[string][]string
+		wantOutcomes map[string][]config.JobState
+		wantWasted   float64
+		wantCleaned  int
+	}{
+		{
+			// The priority has no effect, waiting jobs are served in order
+			name:         "disabled",
+			wantAcquired: map[string][]string{"low": {"01:00"}, "queued": {"04:00"}, "high": {"05:00"}},
+			wantOutcomes: map[string][]config.JobState{"low": {config.JobStateCompleted}, "queued": {config.JobStateCompleted}, "high": {config.JobStateCompleted}},
+		},
+		{
+			// The job of the same priority waiting does not preempt
+			name:         "drop",
+			preemption:   config.Preemption{Enabled: true},
+			wantAcquired: map[string][]string{"low": {"01:00"}, "queued": {"03:00"}, "high": {"02:00"}},
+			wantOutcomes: map[string][]config.JobState{"low": {config.JobStatePreempted}, "queued": {config.JobStateCompleted}, "high": {config.JobStateCompleted}},
+			wantWasted:   1,
+		},
+		{
+			// The retry waits behind the job of the same priority queued first
+			name:         "retry",
+			preemption:   config.Preemption{Enabled: true, OnPreempt: config.PreemptActionRetry},
+			wantAcquired: map[string][]string{"low": {"01:00", "04:00"}, "queued": {"03:00"}, "high": {"02:00"}},
+			wantOutcomes: map[string][]config.JobState{"low": {config.JobStatePreempted, config.JobStateCompleted}, "queued": {config.JobStateCompleted}, "high": {config.JobStateCompleted}},
+			wantWasted:   1,
+		},
+		{
+			// The lease of the preempted job is cleaned up before high gets it,
+			// ahead of queued which started waiting first
+			name:         "cleanup",
+			preemption:   config.Preemption{Enabled: true},
+			pools:        []config.Pool{{Name: "aws-quota", CleanupDelay: config.Duration{Duration: 30 * time.Minute}}},
+			wantAcquired: map[string][]string{"low": {"01:00"}, "queued": {"03:30"}, "high": {"02:30"}},
+			wantOutcomes: map[string][]config.JobState{"low": {config.JobStatePreempted}, "queued": {config.JobStateCompleted}, "high": {config.JobStateCompleted}},
+			wantWasted:   1,
+			wantCleaned:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			low := cronJob("low", 3*time.Hour)
+			low.CronSchedule = "0 1 * * *"
+			low.Pool = "aws-quota"
+			queued := cronJob("queued", time.Hour)
+			queued.CronSchedule = "30 1 * * *"
+			high := cronJob("high", time.Hour)
+			high.CronSchedule = "0 2 * * *"
+			high.Priority = 10
+
+			cfg := &config.Config{
+				MaxActiveLeases:    1,
+				JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+				LeaseWaitTimeout:   config.Duration{Duration: 6 * time.Hour},
+				SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+				Preemption:         tt.preemption,
+				Pools:              tt.pools,
+				Jobs:               []config.Job{low, queued, high},
+			}
+			result := runConfig(t, cfg, 1)
+
+			acquired := map[string][]string{}
+			outcomes := map[string][]config.JobState{}
+			for _, instance := range result.Instances {
+				acquired[instance.Job.Name] = append(acquired[instance.Job.Name], instance.LeaseAcquiredTime.Format("15:04"))
+				outcomes[instance.Job.Name] = append(outcomes[instance.Job.Name], instance.State)
+
+				if instance.State == config.JobStatePreempted && (instance.PreemptedBy == nil || instance.PreemptedBy.Job.Name != "high") {
+					t.Errorf("%s was not preempted by high", instance.Job.Name)
+				}
+				if instance.RetryOf != nil && (instance.RetryOf.State != config.JobStatePreempted || !instance.ScheduledTime.Equal(instance.RetryOf.ScheduledTime)) {
+					t.Errorf("%s retries a run that was not preempted or scheduled at another time", instance.Job.Name)
+				}
+			}
+			if !reflect.DeepEqual(acquired, tt.wantAcquired) {
+				t.Errorf("leases acquired at %v, want %v", acquired, tt.wantAcquired)
+			}
+			if !reflect.DeepEqual(outcomes, tt.wantOutcomes) {
+				t.Errorf("outcomes = %v, want %v", outcomes, tt.wantOutcomes)
+			}
+
+			wantPreemptions, wantRetries := 0, 0
+			if tt.preemption.Enabled {
+				wantPreemptions = 1
+			}
+			if tt.preemption.OnPreempt == config.PreemptActionRetry {
+				wantRetries = 1
+			}
+			m := result.Metrics
+			if m.Preemptions != wantPreemptions || m.PriorityPreemptions != wantPreemptions || m.Retries != wantRetries {
+				t.Errorf("Preemptions = %d, PriorityPreemptions = %d, Retries = %d, want %d, %d, %d",
+					m.Preemptions, m.PriorityPreemptions, m.Retries, wantPreemptions, wantPreemptions, wantRetries)
+			}
+			if m.PreemptedLeaseHours != tt.wantWasted {
+				t.Errorf("PreemptedLeaseHours = %g, want %g", m.PreemptedLeaseHours, tt.wantWasted)
+			}
+
+			cleaned := 0
+			for _, event := range result.Events {
+				if event.Type == EventTypeLeaseCleaned {
+					cleaned++
+				}
+			}
+			if cleaned != tt.wantCleaned {
+				t.Errorf("got %d lease-cleaned events, want %d", cleaned, tt.wantCleaned)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package simulation
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// EventSink receives the events of a simulation as they happen
+type EventSink interface {
+	Emit(event Event) error
+}
+
+// MemorySink keeps the events in memory, only the first Limit events when
+// Limit is greater than 0
+type MemorySink struct {
+	Events []Event
+	Limit  int
+}
+
+// NewMemorySink creates an empty in-memory sink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{Events: []Event{}}
+}
+
+// Emit appends the event, unless the sink holds Limit events
+func (m *MemorySink) Emit(event Event) error {
+	if m.Limit > 0 && len(m.Events) >= m.Limit {
+		return nil
+	}
+	m.Events = append(m.Events, event)
+	return nil
+}
+
+// eventRecord is the JSON representation of an event
+type eventRecord struct {
+	Time                time.Time `json:"time"`
+	Type                EventType `json:"type"`
+	Job                 string    `json:"job,omitempty"`
+	Version             string    `json:"version,omitempty"`
+	IsReleaseController bool      `json:"isReleaseController,omitempty"`
+	ScheduledTime       time.Time `json:"scheduledTime,omitzero"`
+	ActiveLeases        int       `json:"activeLeases"`
+	Message             string    `json:"message"`
+	IsWarning           bool      `json:"isWarning,omitempty"`
+}
+
+// JSONLinesSink writes every event as one JSON object per line
+type JSONLinesSink struct {
+	encoder *json.Encoder
+}
+
+// NewJSONLinesSink creates a sink writing to w. Writes are not buffered.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{encoder: json.NewEncoder(w)}
+}
+
+// Emit writes the event
+func (j *JSONLinesSink) Emit(event Event) error {
+	record := eventRecord{
+		Time:         event.Time,
+		Type:         event.Type,
+		ActiveLeases: event.ActiveLeases,
+		Message:      event.Message,
+		IsWarning:    event.IsWarning,
+	}
+	if instance := event.JobInstance; instance != nil {
+		record.Job = instance.Job.Name
+		record.Version = instance.Job.Version
+		record.IsReleaseController = instance.Job.ReleaseController()
+		record.ScheduledTime = instance.ScheduledTime
+	}
+	return j.encoder.Encode(record)
+}
+
+// StatsSink counts events without retaining them
+type StatsSink struct {
+	// Counts holds the number of events of each type
+	Counts map[EventType]int
+	// ReleaseControllerCounts holds the number of events of each type of release controller jobs
+	ReleaseControllerCounts map[EventType]int
+	// Warnings is the number of warning events
+	Warnings int
+	// PeakActiveLeases is the highest number of leases in use after an event
+	PeakActiveLeases int
+	// First and Last are the times of the first and last events
+	First time.Time
+	Last  time.Time
+}
+
+// NewStatsSink creates an empty statistics sink
+func NewStatsSink() *StatsSink {
+	return &StatsSink{
+		Counts:                  make(map[EventType]int),
+		ReleaseControllerCounts: make(map[EventType]int),
+	}
+}
+
+// Emit accounts for the event
+func (s *StatsSink) Emit(event Event) error {
+	s.Counts[event.Type]++
+	if event.JobInstance != nil && event.JobInstance.Job.ReleaseController() {
+		s.ReleaseControllerCounts[event.Type]++
+	}
+	if event.IsWarning {
+		s.Warnings++
+	}
+	if event.ActiveLeases > s.PeakActiveLeases {
+		s.PeakActiveLeases = event.ActiveLeases
+	}
+	if s.First.IsZero() {
+		s.First = event.Time
+	}
+	s.Last = event.Time
+	return nil
+}
+
+// Total returns the number of events
+func (s *StatsSink) Total() int {
+	total := 0
+	for _, count := range s.Counts {
+		total += count
+	}
+	return total
+}
+
+// fanOut forwards events to several sinks
+type fanOut []EventSink
+
+// FanOut returns a sink forwarding every event to all the sinks, in order,
+// stopping at the first error
+func FanOut(sinks ...EventSink) EventSink {
+	return fanOut(sinks)
+}
+
+// Emit forwards the event
+func (f fanOut) Emit(event Event) error {
+	for _, sink := range f {
+		if err := sink.Emit(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filter forwards the events a function keeps to a sink
+type filter struct {
+	sink EventSink
+	keep func(Event) bool
+}
+
+// Filter returns a sink forwarding to the sink only the events keep
+// returns true for
+func Filter(sink EventSink, keep func(Event) bool) EventSink {
+	return filter{sink: sink, keep: keep}
+}
+
+// Emit forwards the event when it is kept
+func (f filter) Emit(event Event) error {
+	if !f.keep(event) {
+		return nil
+	}
+	return f.sink.Emit(event)
+}
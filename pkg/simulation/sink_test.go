@@ -0,0 +1,124 @@
+package simulation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+)
+
+// failingSink fails on the nth event
+type failingSink struct {
+	n, emitted int
+}
+
+func (f *failingSink) Emit(event Event) error {
+	f.emitted++
+	if f.emitted == f.n {
+		return errors.New("disk full")
+	}
+	return nil
+}
+
+func sinkTestConfig() *config.Config {
+	return &config.Config{
+		MaxActiveLeases:    1,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: time.Hour},
+		SimulationDuration: config.Duration{Duration: 24 * time.Hour},
+		Jobs:               []config.Job{cronJob("a", 3*time.Hour), cronJob("b", time.Hour)},
+	}
+}
+
+func TestEventSinks(t *testing.T) {
+	memory := NewMemorySink()
+	stats := NewStatsSink()
+	var buf bytes.Buffer
+
+	sim := NewSimulator(sinkTestConfig(), WithStartTime(testStart), WithEventSink(FanOut(memory, stats, NewJSONLinesSink(&buf))))
+	result, err := sim.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if result.Events != nil {
+		t.Errorf("Result.Events = %v, want nil when events go to a sink", result.Events)
+	}
+	// a acquires, b waits, b times out, a is released
+	if len(memory.Events) != 4 || stats.Total() != 4 {
+		t.Fatalf("memory sink got %d events and stats sink %d, want 4", len(memory.Events), stats.Total())
+	}
+	if len(result.Warnings) != 0 || result.WarningCount != 2 || stats.Warnings != 2 {
+		t.Errorf("got %d warnings kept and %d and %d counted, want none kept and 2 counted", len(result.Warnings), result.WarningCount, stats.Warnings)
+	}
+	if stats.Counts[EventTypeJobTimeout] != 1 || stats.PeakActiveLeases != 1 {
+		t.Errorf("stats = %+v, want 1 timeout and a peak of 1 lease", stats)
+	}
+	if !stats.First.Equal(at("00:30")) || !stats.Last.Equal(at("03:30")) {
+		t.Errorf("stats cover %s to %s, want 00:30 to 03:30", stats.First, stats.Last)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d JSON lines, want 4", len(lines))
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["job"] != "b" || record["type"] != string(EventTypeJobWaiting) || record["isWarning"] != true {
+		t.Errorf("second JSON line = %s, want b waiting", lines[1])
+	}
+}
+
+func TestFilteredLimitedSink(t *testing.T) {
+	warnings := &MemorySink{Limit: 1}
+	result, err := NewSimulator(sinkTestConfig(), WithStartTime(testStart), WithEventSink(Filter(warnings, func(event Event) bool {
+		return event.IsWarning
+	}))).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// b waits then times out, only the wait is kept
+	if len(warnings.Events) != 1 || warnings.Events[0].Type != EventTypeJobWaiting {
+		t.Errorf("kept %v, want the first warning only", warnings.Events)
+	}
+	if result.WarningCount != 2 {
+		t.Errorf("WarningCount = %d, want 2", result.WarningCount)
+	}
+}
+
+func TestEventSinkError(t *testing.T) {
+	sink := &failingSink{n: 2}
+	_, err := NewSimulator(sinkTestConfig(), WithStartTime(testStart), WithEventSink(sink)).Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Run() error = %v, want the sink error", err)
+	}
+}
+
+func TestTimePointsWithoutEvents(t *testing.T) {
+	cfg := sinkTestConfig()
+	cfg.Jobs = []config.Job{cronJob("a", time.Hour)}
+
+	result := runConfig(t, cfg, 1)
+
+	// The whole window is sampled every 30 minutes, even after the last job
+	if len(result.TimePoints) != 49 {
+		t.Fatalf("got %d time points, want 49", len(result.TimePoints))
+	}
+	for _, point := range result.TimePoints {
+		want := 0
+		if !point.Time.Before(at("00:30")) && point.Time.Before(at("01:30")) {
+			want = 1
+		}
+		if point.ActiveLeases != want {
+			t.Errorf("%d active leases at %s, want %d", point.ActiveLeases, point.Time.Format("15:04"), want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package tui
+
+// Key is an explorer command read from the terminal
+type Key int
+
+const (
+	KeyNone Key = iota
+	KeyQuit
+	KeyLeft
+	KeyRight
+	KeyPageUp
+	KeyPageDown
+	KeyHome
+	KeyEnd
+	KeyZoomIn
+	KeyZoomOut
+	KeyVersion
+	KeyScenario
+)
+
+// escapeKeys are the escape sequences of the special keys
+var escapeKeys = map[string]Key{
+	"\x1b[D":  KeyLeft,
+	"\x1b[C":  KeyRight,
+	"\x1b[5~": KeyPageUp,
+	"\x1b[6~": KeyPageDown,
+	"\x1b[H":  KeyHome,
+	"\x1b[1~": KeyHome,
+	"\x1bOH":  KeyHome,
+	"\x1b[F":  KeyEnd,
+	"\x1b[4~": KeyEnd,
+	"\x1bOF":  KeyEnd,
+}
+
+// runeKeys are the keys bound to printable and control characters
+var runeKeys = map[byte]Key{
+	'q':  KeyQuit,
+	0x03: KeyQuit, // Ctrl-C, since raw mode disables signals
+	'h':  KeyLeft,
+	'l':  KeyRight,
+	'H':  KeyPageUp,
+	'L':  KeyPageDown,
+	'g':  KeyHome,
+	'G':  KeyEnd,
+	'+':  KeyZoomIn,
+	'=':  KeyZoomIn,
+	']':  KeyZoomIn,
+	'-':  KeyZoomOut,
+	'[':  KeyZoomOut,
+	'v':  KeyVersion,
+	's':  KeyScenario,
+}
+
+// ParseKeys returns the keys read from a terminal in raw mode. Unknown keys
+// and escape sequences are dropped.
+func ParseKeys(input []byte) []Key {
+	var keys []Key
+	for len(input) > 0 {
+		if input[0] == 0x1b && len(input) > 1 {
+			matched := false
+			for sequence, key := range escapeKeys {
+				if len(input) >= len(sequence) && string(input[:len(sequence)]) == sequence {
+					keys = append(keys, key)
+					input = input[len(sequence):]
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				// Skip the unknown sequence up to its final byte
+				i := 2
+				for i < len(input) && (input[i] < 0x40 || input[i] > 0x7e) {
+					i++
+				}
+				input = input[min(i+1, len(input)):]
+			}
+			continue
+		}
+
+		if key, ok := runeKeys[input[0]]; ok {
+			keys = append(keys, key)
+		} else if input[0] == 0x1b {
+			keys = append(keys, KeyQuit)
+		}
+		input = input[1:]
+	}
+	return keys
+}
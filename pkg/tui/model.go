@@ -0,0 +1,309 @@
+package tui
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+// zoomLevels are the durations covered by one chart column
+var zoomLevels = []time.Duration{
+	5 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	2 * time.Hour,
+	4 * time.Hour,
+}
+
+// Model is the state of the explorer: the simulation events, the visible
+// window of the chart, the cursor and the job filters
+type Model struct {
+	events    []simulation.Event
+	start     time.Time
+	end       time.Time
+	maxLeases int
+	title     string
+
+	// versions and scenarios are the filter values, the first one being "" for all
+	versions      []string
+	scenarios     []string
+	versionIndex  int
+	scenarioIndex int
+
+	zoom   int
+	offset int // Column of the left edge of the view
+	cursor int // Column of the cursor
+
+	width  int
+	height int
+
+	// changes are the lease usage of the filtered jobs after each event time
+	changes []usage
+
+	// capacities are the changes of the capacity schedule, in time order
+	capacities []capacityChange
+}
+
+// usage is the number of leases in use and waiting jobs from a given time
+type usage struct {
+	time    time.Time
+	active  int
+	waiting int
+}
+
+// capacityChange is the number of leases available from a given time
+type capacityChange struct {
+	time      time.Time
+	maxLeases int
+}
+
+// NewModel creates a model exploring the events of a simulation, maxLeases
+// being the capacity until the capacity schedule changes it
+func NewModel(title string, result *simulation.Result, maxLeases int) *Model {
+	m := &Model{
+		events:    result.Events,
+		start:     result.Start,
+		end:       result.End,
+		maxLeases: maxLeases,
+		title:     title,
+		versions:  []string{""},
+		scenarios: []string{""},
+		zoom:      2,
+		width:     80,
+		height:    24,
+	}
+
+	versions := make(map[string]bool)
+	scenarios := make(map[string]bool)
+	for _, instance := range result.Instances {
+		versions[instance.Job.Version] = true
+		scenarios[instance.Job.Scenario] = true
+	}
+	m.versions = append(m.versions, sortedKeys(versions)...)
+	m.scenarios = append(m.scenarios, sortedKeys(scenarios)...)
+
+	for _, event := range result.Events {
+		if event.Type == simulation.EventTypeCapacityChanged {
+			m.capacities = append(m.capacities, capacityChange{time: event.Time, maxLeases: event.MaxLeases})
+		}
+	}
+
+	m.computeUsage()
+	return m
+}
+
+// SetSize sets the size of the screen
+func (m *Model) SetSize(width, height int) {
+	m.width, m.height = width, height
+	m.scrollToCursor()
+}
+
+// Update applies a key and reports whether the explorer should quit
+func (m *Model) Update(key Key) bool {
+	switch key {
+	case KeyQuit:
+		return true
+	case KeyLeft:
+		m.moveCursor(-1)
+	case KeyRight:
+		m.moveCursor(1)
+	case KeyPageUp:
+		m.moveCursor(-m.chartWidth())
+	case KeyPageDown:
+		m.moveCursor(m.chartWidth())
+	case KeyHome:
+		m.moveCursor(-m.columns())
+	case KeyEnd:
+		m.moveCursor(m.columns())
+	case KeyZoomIn:
+		m.setZoom(m.zoom - 1)
+	case KeyZoomOut:
+		m.setZoom(m.zoom + 1)
+	case KeyVersion:
+		m.versionIndex = (m.versionIndex + 1) % len(m.versions)
+		m.computeUsage()
+	case KeyScenario:
+		m.scenarioIndex = (m.scenarioIndex + 1) % len(m.scenarios)
+		m.computeUsage()
+	}
+	return false
+}
+
+// CursorTime returns the instant under the cursor
+func (m *Model) CursorTime() time.Time {
+	return m.columnTime(m.cursor)
+}
+
+// Holders returns the job instances holding a lease at the cursor and those
+// waiting for one, in the order they acquired or started waiting
+func (m *Model) Holders() ([]*config.JobInstance, []*config.JobInstance) {
+	return m.stateAt(m.CursorTime())
+}
+
+// columnDuration returns the duration covered by a chart column
+func (m *Model) columnDuration() time.Duration {
+	return zoomLevels[m.zoom]
+}
+
+// columns returns the number of columns covering the simulation window
+func (m *Model) columns() int {
+	return int((m.end.Sub(m.start) + m.columnDuration() - 1) / m.columnDuration())
+}
+
+// columnTime returns the start time of a column
+func (m *Model) columnTime(column int) time.Time {
+	return m.start.Add(time.Duration(column) * m.columnDuration())
+}
+
+func (m *Model) moveCursor(delta int) {
+	m.cursor += delta
+	if m.cursor >= m.columns() {
+		m.cursor = m.columns() - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+	m.scrollToCursor()
+}
+
+// setZoom changes the zoom level keeping the cursor on the same instant
+func (m *Model) setZoom(zoom int) {
+	if zoom < 0 || zoom >= len(zoomLevels) {
+		return
+	}
+	cursorTime := m.CursorTime()
+	m.zoom = zoom
+	m.cursor = int(cursorTime.Sub(m.start) / m.columnDuration())
+	m.offset = m.cursor - m.chartWidth()/2
+	m.moveCursor(0)
+}
+
+// scrollToCursor moves the view so that the cursor is visible
+func (m *Model) scrollToCursor() {
+	width := m.chartWidth()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+width {
+		m.offset = m.cursor - width + 1
+	}
+	if max := m.columns() - width; m.offset > max {
+		m.offset = max
+	}
+	if m.offset < 0 {
+		m.offset = 0
+	}
+}
+
+// matches reports whether a job passes the version and scenario filters
+func (m *Model) matches(job *config.Job) bool {
+	if version := m.versions[m.versionIndex]; version != "" && job.Version != version {
+		return false
+	}
+	if scenario := m.scenarios[m.scenarioIndex]; scenario != "" && job.Scenario != scenario {
+		return false
+	}
+	return true
+}
+
+// replay applies the events of the filtered jobs in order, calling visit
+// after the last event of every instant, and stops after the given time
+func (m *Model) replay(until time.Time, visit func(t time.Time, holders, waiters []*config.JobInstance)) {
+	holders := []*config.JobInstance{}
+	waiters := []*config.JobInstance{}
+
+	for i, event := range m.events {
+		if event.Time.After(until) {
+			break
+		}
+		if event.JobInstance != nil && m.matches(event.JobInstance.Job) {
+			switch event.Type {
+			case simulation.EventTypeLeaseAcquired:
+				waiters = remove(waiters, event.JobInstance)
+				holders = append(holders, event.JobInstance)
+			case simulation.EventTypeJobWaiting:
+				waiters = append(waiters, event.JobInstance)
+			case simulation.EventTypeLeaseReleased, simulation.EventTypeJobTimeout, simulation.EventTypeJobPreempted:
+				holders = remove(holders, event.JobInstance)
+				waiters = remove(waiters, event.JobInstance)
+			}
+		}
+
+		if visit != nil && (i+1 == len(m.events) || !m.events[i+1].Time.Equal(event.Time)) {
+			visit(event.Time, holders, waiters)
+		}
+	}
+}
+
+// stateAt returns the filtered job instances holding and waiting for a lease at a time
+func (m *Model) stateAt(t time.Time) ([]*config.JobInstance, []*config.JobInstance) {
+	var holders, waiters []*config.JobInstance
+	m.replay(t, func(_ time.Time, h, w []*config.JobInstance) {
+		holders, waiters = h, w
+	})
+	return holders, waiters
+}
+
+// computeUsage computes the lease usage of the filtered jobs over time
+func (m *Model) computeUsage() {
+	m.changes = []usage{{time: m.start}}
+	m.replay(m.end, func(t time.Time, holders, waiters []*config.JobInstance) {
+		m.changes = append(m.changes, usage{time: t, active: len(holders), waiting: len(waiters)})
+	})
+}
+
+// peakUsage returns the highest number of leases in use and of waiting jobs
+// during a column
+func (m *Model) peakUsage(column int) (int, int) {
+	from, to := m.columnTime(column), m.columnTime(column+1)
+
+	// Usage at the start of the column, then every change within it
+	i := sort.Search(len(m.changes), func(i int) bool { return m.changes[i].time.After(from) }) - 1
+	if i < 0 {
+		i = 0
+	}
+	active, waiting := m.changes[i].active, m.changes[i].waiting
+	for i++; i < len(m.changes) && m.changes[i].time.Before(to); i++ {
+		if m.changes[i].active > active {
+			active = m.changes[i].active
+		}
+		if m.changes[i].waiting > waiting {
+			waiting = m.changes[i].waiting
+		}
+	}
+	return active, waiting
+}
+
+// maxLeasesAt returns the capacity in effect at a time, after the capacity
+// changes made then
+func (m *Model) maxLeasesAt(t time.Time) int {
+	i := sort.Search(len(m.capacities), func(i int) bool { return m.capacities[i].time.After(t) })
+	if i == 0 {
+		return m.maxLeases
+	}
+	return m.capacities[i-1].maxLeases
+}
+
+// remove returns the instances without the given one
+func remove(instances []*config.JobInstance, instance *config.JobInstance) []*config.JobInstance {
+	for i, candidate := range instances {
+		if candidate == instance {
+			return append(instances[:i:i], instances[i+1:]...)
+		}
+	}
+	return instances
+}
+
+func sortedKeys(values map[string]bool) []string {
+	keys := make([]string, 0, len(values))
+	for value := range values {
+		if value != "" {
+			keys = append(keys, value)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,217 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sherine-k/leases/pkg/chart"
+	"github.com/sherine-k/leases/pkg/config"
+)
+
+// ANSI escape sequences used to render the explorer
+const (
+	ansiReset   = "\x1b[0m"
+	ansiReverse = "\x1b[7m"
+	ansiBold    = "\x1b[1m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiRed     = "\x1b[31m"
+	ansiDim     = "\x1b[2m"
+)
+
+// gutterWidth is the width of the lease count labels left of the chart
+const gutterWidth = 5
+
+// minChartRows is the lowest height of the chart
+const minChartRows = 4
+
+// help lists the key bindings
+const help = "←/→ h/l move  H/L page  g/G start/end  +/- zoom  v version  s scenario  q quit"
+
+// chartWidth returns the number of columns shown
+func (m *Model) chartWidth() int {
+	return max(m.width-gutterWidth, 1)
+}
+
+// Render returns the screen contents, one line per terminal row
+func (m *Model) Render() string {
+	lines := []string{m.renderHeader()}
+
+	// The chart leaves room for the title, axis, status and help lines and
+	// at least two lines of jobs, and needs no more than a row per lease
+	active, waiting, limits, scale := m.visibleUsage()
+	chartRows := max(min(m.height-8, scale), minChartRows)
+	lines = append(lines, m.renderChart(chartRows, active, waiting, limits, scale)...)
+	lines = append(lines, m.renderAxis()...)
+	lines = append(lines, m.renderCursor()...)
+
+	detailRows := max(m.height-len(lines)-1, 0)
+	lines = append(lines, m.renderJobs(detailRows)...)
+	lines = append(lines, ansiDim+truncate(help, m.width)+ansiReset)
+
+	return strings.Join(lines, "\n")
+}
+
+func (m *Model) renderHeader() string {
+	version := m.versions[m.versionIndex]
+	if version == "" {
+		version = "all"
+	}
+	scenario := m.scenarios[m.scenarioIndex]
+	if scenario == "" {
+		scenario = "all"
+	}
+
+	header := fmt.Sprintf(" %s   %s/column   version: %s   scenario: %s",
+		m.title, chart.FormatDuration(m.columnDuration()), version, scenario)
+	header = truncate(header, m.width)
+	return ansiReverse + header + strings.Repeat(" ", max(m.width-len([]rune(header)), 0)) + ansiReset
+}
+
+// visibleUsage returns the peak usage and the capacity at the start of the
+// visible columns, and the highest number of leases and waiting jobs the
+// chart must show
+func (m *Model) visibleUsage() ([]int, []int, []int, int) {
+	width := m.chartWidth()
+	active := make([]int, width)
+	waiting := make([]int, width)
+	limits := make([]int, width)
+	scale := 1
+	for i := range active {
+		if column := m.offset + i; column < m.columns() {
+			active[i], waiting[i] = m.peakUsage(column)
+			limits[i] = m.maxLeasesAt(m.columnTime(column))
+			scale = max(scale, active[i]+waiting[i], limits[i])
+		}
+	}
+	return active, waiting, limits, scale
+}
+
+// renderChart draws the leases in use as bars with the waiting jobs stacked
+// above them, and a line at the capacity of each column
+func (m *Model) renderChart(rows int, active, waiting, limits []int, scale int) []string {
+	width := m.chartWidth()
+	lines := make([]string, 0, rows)
+	for row := rows; row >= 1; row-- {
+		// Each row stands for the leases up to this level
+		level := (row*scale + rows - 1) / rows
+		below := ((row-1)*scale + rows - 1) / rows
+		isLimit := func(i int) bool { return below < limits[i] && level >= limits[i] }
+		labelled := row == rows || row == 1
+		for i := range limits {
+			labelled = labelled || (m.offset+i < m.columns() && isLimit(i))
+		}
+
+		var sb strings.Builder
+		if labelled {
+			sb.WriteString(fmt.Sprintf("%3d ┤", level))
+		} else {
+			sb.WriteString("    │")
+		}
+
+		for i := 0; i < width; i++ {
+			cell := " "
+			switch {
+			case m.offset+i >= m.columns():
+			case active[i] >= level:
+				color := ansiGreen
+				if active[i] >= limits[i] {
+					color = ansiRed
+				}
+				cell = color + "█" + ansiReset
+			case active[i]+waiting[i] >= level:
+				cell = ansiYellow + "░" + ansiReset
+			case isLimit(i):
+				cell = ansiDim + "─" + ansiReset
+			}
+			if m.offset+i == m.cursor {
+				cell = ansiReverse + cell + ansiReset
+			}
+			sb.WriteString(cell)
+		}
+		lines = append(lines, sb.String())
+	}
+	return lines
+}
+
+// renderAxis draws the time axis with a label about every 12 columns
+func (m *Model) renderAxis() []string {
+	width := m.chartWidth()
+	axis := []rune("    └" + strings.Repeat("─", width-1))
+	labels := []rune(strings.Repeat(" ", width+gutterWidth))
+
+	spacing := 12
+	for i := 0; i < width; i++ {
+		column := m.offset + i
+		if column%spacing != 0 || column >= m.columns() {
+			continue
+		}
+		label := []rune(m.columnTime(column).Format("Mon 15:04"))
+		position := gutterWidth + i
+		if position+len(label) > len(labels) {
+			break
+		}
+		axis[position] = '┬'
+		copy(labels[position:], label)
+	}
+	return []string{string(axis), string(labels)}
+}
+
+// renderCursor describes the lease usage at the cursor
+func (m *Model) renderCursor() []string {
+	holders, waiters := m.Holders()
+	status := fmt.Sprintf("%s  leases: %d/%d  waiting: %d",
+		m.CursorTime().Format("Mon 2006-01-02 15:04"), len(holders), m.maxLeasesAt(m.CursorTime()), len(waiters))
+	return []string{ansiBold + truncate(status, m.width) + ansiReset}
+}
+
+// renderJobs lists the jobs holding a lease on the left and the waiting
+// jobs on the right
+func (m *Model) renderJobs(rows int) []string {
+	if rows == 0 {
+		return nil
+	}
+	holders, waiters := m.Holders()
+	columnWidth := m.width / 2
+
+	left := jobLines(fmt.Sprintf("Holding (%d)", len(holders)), holders, config.JobStateRunning, rows, columnWidth-1)
+	right := jobLines(fmt.Sprintf("Waiting (%d)", len(waiters)), waiters, config.JobStateWaiting, rows, m.width-columnWidth)
+
+	lines := make([]string, rows)
+	for i := range lines {
+		lines[i] = left[i] + strings.Repeat(" ", columnWidth-len([]rune(left[i]))) + right[i]
+	}
+	return lines
+}
+
+// jobLines lists job instances with the time they entered a state, under a title
+func jobLines(title string, instances []*config.JobInstance, state config.JobState, rows, width int) []string {
+	lines := []string{truncate(title, width)}
+	for i, instance := range instances {
+		if len(lines) == rows-1 && i < len(instances)-1 {
+			lines = append(lines, truncate(fmt.Sprintf("  … %d more", len(instances)-i), width))
+			break
+		}
+		if len(lines) == rows {
+			break
+		}
+		since := instance.StateTimes[state].Format("Mon 15:04")
+		lines = append(lines, truncate(fmt.Sprintf("  %s  %s", since, instance.Job.Name), width))
+	}
+	for len(lines) < rows {
+		lines = append(lines, "")
+	}
+	return lines[:rows]
+}
+
+// truncate shortens a line to a width, marking cut lines with an ellipsis
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,238 @@
+package tui
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sherine-k/leases/pkg/config"
+	"github.com/sherine-k/leases/pkg/simulation"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ansiSequence matches the escape sequences of the rendered screen
+var ansiSequence = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// testJob returns a cron job triggered at 01:00
+func testJob(name, version, scenario string, duration time.Duration) config.Job {
+	return config.Job{
+		Name:         name,
+		Version:      version,
+		Scenario:     scenario,
+		Duration:     config.Duration{Duration: duration},
+		TriggerType:  config.TriggerTypeCron,
+		CronSchedule: "0 1 * * *",
+	}
+}
+
+// newTestModel simulates a configuration starting on testStart
+func newTestModel(t *testing.T, cfg *config.Config) *Model {
+	t.Helper()
+
+	result, err := simulation.NewSimulator(cfg, simulation.WithStartTime(testStart), simulation.WithSeed(1)).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	model := NewModel("test.yaml", result, cfg.MaxActiveLeases)
+	model.SetSize(80, 24)
+	return model
+}
+
+// testModel simulates two leases shared by three jobs triggered at 01:00, so
+// that job-c waits for job-b to finish at 03:00
+func testModel(t *testing.T) *Model {
+	t.Helper()
+
+	return newTestModel(t, &config.Config{
+		MaxActiveLeases:    2,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: 4 * time.Hour},
+		SimulationDuration: config.Duration{Duration: 12 * time.Hour},
+		Jobs: []config.Job{
+			testJob("job-a", "4.19", "e2e", 3*time.Hour),
+			testJob("job-b", "4.19", "upgrade", 2*time.Hour),
+			testJob("job-c", "4.20", "e2e", time.Hour),
+		},
+	})
+}
+
+func names(instances []*config.JobInstance) []string {
+	result := []string{}
+	for _, instance := range instances {
+		result = append(result, instance.Job.Name)
+	}
+	return result
+}
+
+// moveTo moves the cursor right until it reaches a time
+func moveTo(t *testing.T, m *Model, at time.Time) {
+	t.Helper()
+	m.Update(KeyHome)
+	for m.CursorTime().Before(at) {
+		m.Update(KeyRight)
+	}
+	if !m.CursorTime().Equal(at) {
+		t.Fatalf("cursor at %v, want %v", m.CursorTime(), at)
+	}
+}
+
+func TestHolders(t *testing.T) {
+	tests := []struct {
+		at      string
+		holders []string
+		waiters []string
+		filter  []Key
+	}{
+		{at: "00:30", holders: []string{}, waiters: []string{}},
+		{at: "02:00", holders: []string{"job-a", "job-b"}, waiters: []string{"job-c"}},
+		{at: "03:00", holders: []string{"job-a", "job-c"}, waiters: []string{}},
+		{at: "05:00", holders: []string{}, waiters: []string{}},
+		// The first version is 4.19, then the first scenario is e2e
+		{at: "02:00", holders: []string{"job-a", "job-b"}, waiters: []string{}, filter: []Key{KeyVersion}},
+		{at: "02:00", holders: []string{"job-a"}, waiters: []string{"job-c"}, filter: []Key{KeyScenario}},
+		{at: "02:00", holders: []string{"job-a"}, waiters: []string{}, filter: []Key{KeyVersion, KeyScenario}},
+	}
+
+	for _, tt := range tests {
+		m := testModel(t)
+		for _, key := range tt.filter {
+			m.Update(key)
+		}
+		at, _ := time.Parse("15:04", tt.at)
+		moveTo(t, m, testStart.Add(at.Sub(at.Truncate(24*time.Hour))))
+
+		holders, waiters := m.Holders()
+		if got := strings.Join(names(holders), ","); got != strings.Join(tt.holders, ",") {
+			t.Errorf("at %s with %d filters: holders = %s, want %v", tt.at, len(tt.filter), got, tt.holders)
+		}
+		if got := strings.Join(names(waiters), ","); got != strings.Join(tt.waiters, ",") {
+			t.Errorf("at %s with %d filters: waiters = %s, want %v", tt.at, len(tt.filter), got, tt.waiters)
+		}
+	}
+}
+
+// maintenanceModel simulates two leases shared by two jobs triggered at 01:00,
+// with a maintenance window down to one lease from 01:30 to 02:30 preempting
+// job-a, the first of the two to start
+func maintenanceModel(t *testing.T) *Model {
+	t.Helper()
+
+	return newTestModel(t, &config.Config{
+		MaxActiveLeases:    2,
+		JobTimeoutDuration: config.Duration{Duration: 4 * time.Hour},
+		LeaseWaitTimeout:   config.Duration{Duration: 4 * time.Hour},
+		SimulationDuration: config.Duration{Duration: 12 * time.Hour},
+		CapacitySchedule: []config.CapacityChange{
+			{Name: "maintenance", MaxActiveLeases: 1, At: testStart.Add(90 * time.Minute), Duration: config.Duration{Duration: time.Hour}, Policy: config.CapacityPolicyPreempt},
+		},
+		Jobs: []config.Job{
+			testJob("job-a", "4.19", "e2e", 3*time.Hour),
+			testJob("job-b", "4.19", "upgrade", 2*time.Hour),
+		},
+	})
+}
+
+func TestHoldersPreempted(t *testing.T) {
+	m := maintenanceModel(t)
+	for clock, want := range map[string]string{"01:00": "job-a,job-b", "02:00": "job-b", "05:00": ""} {
+		at, _ := time.Parse("15:04", clock)
+		moveTo(t, m, testStart.Add(at.Sub(at.Truncate(24*time.Hour))))
+
+		holders, _ := m.Holders()
+		if got := strings.Join(names(holders), ","); got != want {
+			t.Errorf("at %s: holders = %s, want %s", clock, got, want)
+		}
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	m := testModel(t)
+	m.SetSize(20, 24) // 15 chart columns of 30m
+
+	m.Update(KeyEnd)
+	if got, want := m.CursorTime(), testStart.Add(11*time.Hour+30*time.Minute); !got.Equal(want) {
+		t.Errorf("cursor after End = %v, want %v", got, want)
+	}
+	if m.offset != m.columns()-m.chartWidth() {
+		t.Errorf("offset after End = %d, want the last screen at %d", m.offset, m.columns()-m.chartWidth())
+	}
+
+	moveTo(t, m, testStart.Add(2*time.Hour))
+	m.Update(KeyZoomIn)
+	if m.columnDuration() != 15*time.Minute || !m.CursorTime().Equal(testStart.Add(2*time.Hour)) {
+		t.Errorf("zoom in: %v per column with the cursor at %v, want 15m at 02:00", m.columnDuration(), m.CursorTime())
+	}
+	for i := 0; i < len(zoomLevels); i++ {
+		m.Update(KeyZoomOut)
+	}
+	if m.columnDuration() != 4*time.Hour || !m.CursorTime().Equal(testStart) {
+		t.Errorf("zoom out: %v per column with the cursor at %v, want 4h at 00:00", m.columnDuration(), m.CursorTime())
+	}
+
+	if !m.Update(KeyQuit) {
+		t.Error("Update(KeyQuit) = false, want true")
+	}
+}
+
+func TestRender(t *testing.T) {
+	m := testModel(t)
+	moveTo(t, m, testStart.Add(2*time.Hour))
+
+	screen := ansiSequence.ReplaceAllString(m.Render(), "")
+	lines := strings.Split(screen, "\n")
+	if len(lines) != 24 {
+		t.Errorf("Render() has %d lines, want 24", len(lines))
+	}
+	for _, want := range []string{
+		"test.yaml   30m/column   version: all   scenario: all",
+		"Mon 2024-01-01 02:00  leases: 2/2  waiting: 1",
+		"Holding (2)",
+		"Mon 01:00  job-a",
+		"Waiting (1)",
+		"Mon 01:00  job-c",
+		"Mon 00:00",
+	} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("Render() does not contain %q:\n%s", want, screen)
+		}
+	}
+	for i, line := range lines {
+		if width := len([]rune(line)); width > 80 {
+			t.Errorf("line %d is %d wide, want at most 80: %q", i, width, line)
+		}
+	}
+}
+
+func TestRenderCapacitySchedule(t *testing.T) {
+	m := maintenanceModel(t)
+	for clock, want := range map[string]string{
+		"01:00": "leases: 2/2",
+		"01:30": "leases: 1/1",
+		"02:00": "leases: 1/1",
+		"02:30": "leases: 1/2",
+	} {
+		at, _ := time.Parse("15:04", clock)
+		moveTo(t, m, testStart.Add(at.Sub(at.Truncate(24*time.Hour))))
+
+		if screen := ansiSequence.ReplaceAllString(m.Render(), ""); !strings.Contains(screen, want) {
+			t.Errorf("at %s: Render() does not contain %q:\n%s", clock, want, screen)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	got := ParseKeys([]byte("l\x1b[D\x1b[5~+x\x1b[1;5Cvq"))
+	want := []Key{KeyRight, KeyLeft, KeyPageUp, KeyZoomIn, KeyVersion, KeyQuit}
+	if len(got) != len(want) {
+		t.Fatalf("ParseKeys() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ParseKeys()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}